@@ -28,6 +28,10 @@ import (
 type Decoder struct {
 	doc       *Document
 	pageIndex uint32
+	buf       []byte
+	opts      DecoderOptions
+	config    streamConfig
+	probe     ProbeReport
 }
 
 // NewDecoder 创建解码器
@@ -38,205 +42,219 @@ func NewDecoder(r io.Reader) (*Decoder, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(data) > 8 && data[0] == 'C' && data[1] == 'W' && data[2] == 'S' {
-		zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
-		if err != nil {
-			return nil, err
-		}
-		defer zr.Close()
-		decompressed, err := io.ReadAll(zr)
-		if err != nil {
-			return nil, err
-		}
-		data = decompressed
-		if len(data) > 0 {
-			nbits := int(data[0] >> 3)
-			rectBits := 5 + nbits*4
-			rectBytes := (rectBits + 7) / 8
-			startOffset := rectBytes + 4
-			if len(data) > startOffset {
-				data = data[startOffset:]
-				for len(data) >= 2 {
-					tagCodeAndLen := int(data[0]) | (int(data[1]) << 8)
-					tagCode := tagCodeAndLen >> 6
-					tagLen := tagCodeAndLen & 0x3F
-					headerLen := 2
-					if tagLen == 0x3F {
-						if len(data) >= 6 {
-							tagLen = int(data[2]) | (int(data[3]) << 8) | (int(data[4]) << 16) | (int(data[5]) << 24)
-							headerLen = 6
-						} else {
-							break
-						}
-					}
-					if tagCode == 0 {
-						break
-					}
-					if tagCode == 6 || tagCode == 21 || tagCode == 35 || tagCode == 90 {
-						skipBytes := 2
-						if tagCode == 35 || tagCode == 90 {
-							skipBytes = 6
-						}
-						payloadOffset := headerLen + skipBytes
-						if len(data) > payloadOffset {
-							data = data[payloadOffset:]
-							break
-						}
-					}
-					nextOffset := headerLen + tagLen
-					if len(data) >= nextOffset {
-						data = data[nextOffset:]
-					} else {
-						break
-					}
-				}
-			}
-		}
-		jbig2Signature := []byte{0x97, 0x4A, 0x42, 0x32, 0x0D, 0x0A, 0x1A, 0x0A}
-		idx := bytes.Index(data, jbig2Signature)
-		if idx != -1 {
-			data = data[idx:]
-		}
-	}
-	data, randomAccess, littleEndian, orgMode, grouped := probeConfigs(data)
-	if data == nil {
-		return nil, errors.New("no valid jbig2 configuration found")
-	}
-	doc := NewDocument(data, nil, randomAccess, littleEndian)
-	doc.OrgMode = orgMode
-	doc.Grouped = grouped
-	return &Decoder{doc: doc, pageIndex: 0}, nil
+	return newDecoderFromData(data, DecoderOptions{})
 }
 
 // NewDecoderWithGlobals 创建带全局段的解码器
 // 入参: r 读取器, globals 全局段数据
 // 返回: *Decoder 解码器, error 错误信息
 func NewDecoderWithGlobals(r io.Reader, globals []byte) (*Decoder, error) {
+	var parsed *Globals
+	if len(globals) > 0 {
+		var err error
+		if parsed, err = ParseGlobals(globals); err != nil {
+			return nil, err
+		}
+	}
+	return NewDecoderWithParsedGlobals(r, parsed)
+}
+
+// NewDecoderWithParsedGlobals 创建引用已解析全局段的解码器
+// 入参: r 读取器, globals 已解析的全局段, 可为nil
+// 返回: *Decoder 解码器, error 错误信息
+func NewDecoderWithParsedGlobals(r io.Reader, globals *Globals) (*Decoder, error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	if len(data) > 8 && data[0] == 'C' && data[1] == 'W' && data[2] == 'S' {
-		zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
-		if err != nil {
-			return nil, err
-		}
-		defer zr.Close()
-		decompressed, err := io.ReadAll(zr)
-		if err != nil {
-			return nil, err
-		}
-		data = decompressed
-		if len(data) > 0 {
-			nbits := int(data[0] >> 3)
-			rectBits := 5 + nbits*4
-			rectBytes := (rectBits + 7) / 8
-			startOffset := rectBytes + 4
-			if len(data) > startOffset {
-				data = data[startOffset:]
-				for len(data) >= 2 {
-					tagCodeAndLen := int(data[0]) | (int(data[1]) << 8)
-					tagCode := tagCodeAndLen >> 6
-					tagLen := tagCodeAndLen & 0x3F
-					headerLen := 2
-					if tagLen == 0x3F {
-						if len(data) >= 6 {
-							tagLen = int(data[2]) | (int(data[3]) << 8) | (int(data[4]) << 16) | (int(data[5]) << 24)
-							headerLen = 6
-						} else {
-							break
-						}
-					}
-					if tagCode == 0 {
+	return newDecoderFromData(data, DecoderOptions{Globals: globals})
+}
+
+// unwrapSWF 从以CWS开始的压缩SWF文件中取出JBIG2数据
+// 解压后跳过SWF文件头与标签, 定位到第一个图像标签的数据, 再定位到JBIG2文件头标识
+// 入参: data 数据
+// 返回: []byte JBIG2数据, 不是压缩SWF文件时原样返回, error 错误信息
+func unwrapSWF(data []byte) ([]byte, error) {
+	if len(data) <= 8 || data[0] != 'C' || data[1] != 'W' || data[2] != 'S' {
+		return data, nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	data = decompressed
+	if len(data) > 0 {
+		nbits := int(data[0] >> 3)
+		rectBits := 5 + nbits*4
+		rectBytes := (rectBits + 7) / 8
+		startOffset := rectBytes + 4
+		if len(data) > startOffset {
+			data = data[startOffset:]
+			for len(data) >= 2 {
+				tagCodeAndLen := int(data[0]) | (int(data[1]) << 8)
+				tagCode := tagCodeAndLen >> 6
+				tagLen := tagCodeAndLen & 0x3F
+				headerLen := 2
+				if tagLen == 0x3F {
+					if len(data) >= 6 {
+						tagLen = int(data[2]) | (int(data[3]) << 8) | (int(data[4]) << 16) | (int(data[5]) << 24)
+						headerLen = 6
+					} else {
 						break
 					}
-					if tagCode == 6 || tagCode == 21 || tagCode == 35 || tagCode == 90 {
-						skipBytes := 2
-						if tagCode == 35 || tagCode == 90 {
-							skipBytes = 6
-						}
-						payloadOffset := headerLen + skipBytes
-						if len(data) > payloadOffset {
-							data = data[payloadOffset:]
-							break
-						}
+				}
+				if tagCode == 0 {
+					break
+				}
+				if tagCode == 6 || tagCode == 21 || tagCode == 35 || tagCode == 90 {
+					skipBytes := 2
+					if tagCode == 35 || tagCode == 90 {
+						skipBytes = 6
 					}
-					nextOffset := headerLen + tagLen
-					if len(data) >= nextOffset {
-						data = data[nextOffset:]
-					} else {
+					payloadOffset := headerLen + skipBytes
+					if len(data) > payloadOffset {
+						data = data[payloadOffset:]
 						break
 					}
 				}
-			}
-		}
-	}
-	probedData, randomAccess, littleEndian, orgMode, grouped := probeConfigs(data)
-	if probedData == nil {
-		if len(globals) > 0 {
-			probedData = data
-			randomAccess = false
-			littleEndian = false
-			orgMode = 0
-			grouped = false
-			if len(data) >= 4 {
-				if data[0] != 0 && data[1] == 0 && data[2] == 0 && data[3] == 0 {
-					littleEndian = true
+				nextOffset := headerLen + tagLen
+				if len(data) >= nextOffset {
+					data = data[nextOffset:]
+				} else {
+					break
 				}
 			}
-		} else {
-			return nil, errors.New("no valid jbig2 configuration found")
 		}
-	} else {
-		data = probedData
 	}
-	doc := NewDocument(data, globals, randomAccess, littleEndian)
-	doc.OrgMode = orgMode
-	doc.Grouped = grouped
-	for {
-		res := doc.globalContext.DecodeSequential()
-		if res == ResultEndReached {
-			break
-		}
-		if res == ResultFailure {
-			return nil, errors.New("failed to parse global segments")
-		}
-		if res == ResultPageCompleted {
-			continue
+	if idx := bytes.Index(data, kFileSignature); idx != -1 {
+		data = data[idx:]
+	}
+	return data, nil
+}
+
+// newDecoderFromData 使用已读入的数据创建解码器
+// 入参: data 数据, opts 解码器选项
+// 返回: *Decoder 解码器, error 错误信息
+func newDecoderFromData(data []byte, opts DecoderOptions) (*Decoder, error) {
+	// 严格模式下不猜测SWF封装, 以CWS开始的数据按JBIG2数据解码
+	if !opts.Strict {
+		var err error
+		if data, err = unwrapSWF(data); err != nil {
+			return nil, err
 		}
 	}
-	return &Decoder{doc: doc, pageIndex: 0}, nil
+	cfg, report, err := resolveConfig(data, &opts)
+	if err != nil {
+		return nil, err
+	}
+	doc := NewDocument(data[cfg.offset:], nil, cfg.randomAccess, cfg.littleEndian)
+	doc.OrgMode = cfg.orgMode
+	doc.Grouped = cfg.grouped
+	doc.baseOffset = uint32(cfg.offset)
+	if opts.Globals != nil {
+		doc.globalContext = opts.Globals.doc
+	}
+	dec := &Decoder{doc: doc, opts: opts, config: cfg, probe: report}
+	doc.setOptions(&dec.opts)
+	return dec, nil
+}
+
+// Reset 重置解码器以解码新的数据流
+// 保留创建时引用的全局段、解码器选项与区域并发设置, 并复用读取缓冲区与上一页的位图缓冲区
+// 之前返回的图像不受影响, 重置失败后需再次成功重置才能继续使用
+// 入参: r 读取器
+// 返回: error 错误信息
+func (d *Decoder) Reset(r io.Reader) error {
+	regionWorkers := 0
+	if d.doc != nil {
+		regionWorkers = d.doc.regionWorkers
+		d.doc.page.release()
+		d.doc = nil
+	}
+	buf := bytes.NewBuffer(d.buf[:0])
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	d.buf = buf.Bytes()
+	nd, err := newDecoderFromData(d.buf, d.opts)
+	if err != nil {
+		return err
+	}
+	nd.buf = d.buf
+	nd.doc.regionWorkers = regionWorkers
+	*d = *nd
+	d.doc.setOptions(&d.opts)
+	return nil
 }
 
 // Decode 解码下一页
 // 返回: image.Image 图像, error 错误信息
 func (d *Decoder) Decode() (image.Image, error) {
+	if err := d.decodePage(); err != nil {
+		return nil, err
+	}
+	return d.doc.pageImage(), nil
+}
+
+// decodePage 解码下一页
+// 完成后页面位图保留在文档中, 在下一次解码前有效
+// 开始新的页面时清除上一页的警告, 数据流结束时保留最后一页的警告并追加之后产生的警告
+// 返回: error 错误信息
+func (d *Decoder) decodePage() (err error) {
 	if d.doc == nil {
-		return nil, errors.New("decoder not initialized")
+		return errors.New("decoder not initialized")
+	}
+	prev := d.doc.warnings
+	d.doc.warnings = nil
+	defer func() {
+		if err == io.EOF {
+			d.doc.warnings = append(prev, d.doc.warnings...)
+		}
+	}()
+	if d.opts.PageCount > 0 && int(d.pageIndex) >= d.opts.PageCount {
+		return io.EOF
 	}
 	for {
 		res := d.doc.DecodeSequential()
 		if res == ResultEndReached {
-			if d.doc.inPage && d.doc.page != nil {
+			if d.doc.inPage && d.doc.hasPage() {
+				if d.doc.recovering() {
+					d.doc.warnUnterminatedPage()
+				}
 				d.doc.inPage = false
 				d.pageIndex++
-				img := d.doc.page.ToGoImage()
 				d.doc.ReleasePageSegments(d.pageIndex)
-				return img, nil
+				if d.doc.tiledPage != nil {
+					return d.doc.colourErr()
+				}
+				return nil
 			}
-			return nil, io.EOF
+			return io.EOF
 		}
 		if res == ResultPageCompleted {
-			if d.doc.page == nil {
-				return nil, errors.New("page completed but no image found")
+			if !d.doc.hasPage() {
+				return errors.New("page completed but no image found")
 			}
 			d.pageIndex++
-			img := d.doc.page.ToGoImage()
 			d.doc.ReleasePageSegments(d.pageIndex)
-			return img, nil
+			if d.doc.tiledPage != nil {
+				return d.doc.colourErr()
+			}
+			return nil
 		}
 		if res == ResultFailure {
-			return nil, errors.New("decoding failed")
+			if d.doc.profileErr != nil {
+				return d.doc.profileErr
+			}
+			if d.doc.extErr != nil {
+				return d.doc.extErr
+			}
+			return errors.New("decoding failed")
 		}
 	}
 }
@@ -258,6 +276,15 @@ func (d *Decoder) DecodeAll() ([]image.Image, error) {
 	return images, nil
 }
 
+// PageInfo 获取最近解码页面的页面信息
+// 返回: *PageInfo 页面信息
+func (d *Decoder) PageInfo() *PageInfo {
+	if d.doc == nil {
+		return nil
+	}
+	return d.doc.currentPageInfo()
+}
+
 // GetDocument 获取文档对象
 // 返回: *Document 文档对象
 func (d *Decoder) GetDocument() *Document {
@@ -303,13 +330,14 @@ func DecodeConfig(r io.Reader) (image.Config, error) {
 	return image.Config{}, errors.New("page information not found")
 }
 
-// probeConfigs 探测JBIG2文件的配置
+// probeStream 按候选配置评分探测JBIG2文件的配置
 // 入参: data 数据
-// 返回: probed 探测后的数据, randomAccess 是否随机访问, littleEndian 是否小端序, orgMode 组织模式, grouped 是否分组
-func probeConfigs(data []byte) (probed []byte, randomAccess bool, littleEndian bool, orgMode int, grouped bool) {
+// 返回: ProbeReport 各候选配置的得分与选中的候选
+func probeStream(data []byte) ProbeReport {
+	report := ProbeReport{Chosen: -1}
 	jbig2Signature := []byte{0x97, 0x4A, 0x42, 0x32, 0x0D, 0x0A, 0x1A, 0x0A}
 	if len(data) < 8 || !bytes.HasPrefix(data, jbig2Signature) {
-		return nil, false, false, 0, false
+		return report
 	}
 	type Config struct {
 		Offset       int
@@ -318,7 +346,6 @@ func probeConfigs(data []byte) (probed []byte, randomAccess bool, littleEndian b
 		OrgMode      int
 		Grouped      bool
 	}
-	var validConfig *Config
 	bestScore := -1
 	candidates := []Config{
 		{9, true, false, 0, false},
@@ -329,6 +356,14 @@ func probeConfigs(data []byte) (probed []byte, randomAccess bool, littleEndian b
 		{9, false, true, 0, false},
 	}
 	for _, cfg := range candidates {
+		report.Candidates = append(report.Candidates, ProbeCandidate{
+			Offset:       cfg.Offset,
+			RandomAccess: cfg.RandomAccess,
+			LittleEndian: cfg.LittleEndian,
+			OrgMode:      cfg.OrgMode,
+			Skipped:      true,
+		})
+		candidate := &report.Candidates[len(report.Candidates)-1]
 		if len(data) <= cfg.Offset+5 {
 			continue
 		}
@@ -423,15 +458,15 @@ func probeConfigs(data []byte) (probed []byte, randomAccess bool, littleEndian b
 				score += 40
 			}
 		}
+		candidate.Grouped = candidateGrouped
+		candidate.Score = score
+		candidate.Skipped = false
 		if score > bestScore {
 			bestScore = score
-			validConfig = &Config{cfg.Offset, cfg.RandomAccess, cfg.LittleEndian, cfg.OrgMode, candidateGrouped}
+			report.Chosen = len(report.Candidates) - 1
 		}
 	}
-	if validConfig == nil {
-		return nil, false, false, 0, false
-	}
-	return data[validConfig.Offset:], validConfig.RandomAccess, validConfig.LittleEndian, validConfig.OrgMode, validConfig.Grouped
+	return report
 }
 
 func init() {
@@ -14,13 +14,17 @@
 
 package jbig2
 
-import "errors"
+import (
+	"errors"
+	"math/bits"
+)
 
 // defaultAValue 默认A值
 const defaultAValue = 0x8000
 
 // kQeTable Qe表
-var kQeTable = []ArithQe{
+// 状态转移只会产生表内索引, 因此解码时无需再做越界检查
+var kQeTable = [47]ArithQe{
 	{0x5601, 1, 1, true}, {0x3401, 2, 6, false}, {0x1801, 3, 9, false},
 	{0x0AC1, 4, 12, false}, {0x0521, 5, 29, false}, {0x0221, 38, 33, false},
 	{0x5601, 7, 6, true}, {0x5401, 8, 14, false}, {0x4801, 9, 14, false},
@@ -113,13 +117,14 @@ type ArithDecoder struct {
 	a        uint32
 	ct       uint32
 	complete bool
+	pad      bool
 }
 
 // NewArithDecoder 创建新的算术解码器
 // 入参: stream 位流
 // 返回: *ArithDecoder 解码器对象
 func NewArithDecoder(stream *BitStream) *ArithDecoder {
-	ad := &ArithDecoder{stream: stream, a: defaultAValue}
+	ad := &ArithDecoder{stream: stream, a: defaultAValue, pad: stream.padArith}
 	ad.b = stream.GetCurByteArith()
 	ad.c = (uint32(ad.b) ^ 0xff) << 16
 	ad.byteIn()
@@ -132,89 +137,126 @@ func NewArithDecoder(stream *BitStream) *ArithDecoder {
 // 入参: cx 上下文
 // 返回: int 结果
 func (ad *ArithDecoder) Decode(cx *ArithCtx) int {
-	if int(cx.I()) >= len(kQeTable) {
-		return 0
-	}
-	qe := kQeTable[cx.I()]
-	ad.a -= uint32(qe.Qe)
-	if (ad.c >> 16) < ad.a {
-		if (ad.a & defaultAValue) != 0 {
-			return cx.MPS()
+	qe := &kQeTable[cx.i]
+	qeVal := uint32(qe.Qe)
+	a := ad.a - qeVal
+	c := ad.c
+	var d int
+	if (c >> 16) < a {
+		if (a & defaultAValue) != 0 {
+			ad.a = a
+			if cx.mps {
+				return 1
+			}
+			return 0
 		}
-		var d int
-		if ad.a < uint32(qe.Qe) {
-			d = cx.DecodeNLPS(qe)
+		if a < qeVal {
+			d = cx.DecodeNLPS(*qe)
 		} else {
-			d = cx.DecodeNMPS(qe)
+			d = cx.DecodeNMPS(*qe)
 		}
-		ad.readValueA()
-		return d
-	}
-	ad.c -= ad.a << 16
-	var d int
-	if ad.a < uint32(qe.Qe) {
-		d = cx.DecodeNMPS(qe)
 	} else {
-		d = cx.DecodeNLPS(qe)
+		c -= a << 16
+		if a < qeVal {
+			d = cx.DecodeNMPS(*qe)
+		} else {
+			d = cx.DecodeNLPS(*qe)
+		}
+		a = qeVal
+	}
+	// 多数情况下CT足够, 按前导零一次完成移位
+	n := uint32(bits.LeadingZeros16(uint16(a)))
+	if n <= ad.ct {
+		ad.a = a << n
+		ad.c = c << n
+		ad.ct -= n
+		return d
 	}
-	ad.a = uint32(qe.Qe)
-	ad.readValueA()
+	ad.c = c
+	ad.renormalize(a)
 	return d
 }
 
 // IsComplete 是否完成
+// 位流启用填充时越过末尾的数据按0xFF继续解码, 不视为完成
 // 返回: bool 是否完成
 func (ad *ArithDecoder) IsComplete() bool {
-	return ad.complete
+	return ad.complete && !ad.pad
+}
+
+// renormalize 重归一化A与C寄存器
+// 移位跨越字节边界时使用, 在CT耗尽处读入字节
+// 入参: a 待归一化的A值
+func (ad *ArithDecoder) renormalize(a uint32) {
+	c, ct := ad.c, ad.ct
+	for {
+		if ct == 0 {
+			ad.c = c
+			ad.byteIn()
+			c, ct = ad.c, ad.ct
+		}
+		a <<= 1
+		c <<= 1
+		ct--
+		if (a & defaultAValue) != 0 {
+			break
+		}
+	}
+	ad.a, ad.c, ad.ct = a, c, ct
 }
 
 // byteIn 读入字节
+// 直接访问位流数据并处理0xFF填充字节
 func (ad *ArithDecoder) byteIn() {
+	bs := ad.stream
+	size := uint32(len(bs.data))
+	idx := bs.byteIdx
 	if ad.b == 0xff {
-		b1 := ad.stream.GetNextByteArith()
+		b1 := uint8(0xff)
+		if uint64(idx)+1 < uint64(size) {
+			b1 = bs.data[idx+1]
+		}
 		if b1 > 0x8f {
 			ad.ct = 8
 		} else {
-			ad.stream.IncByteIdx()
+			idx++
 			ad.b = b1
-			ad.c = ad.c + 0xfe00 - (uint32(ad.b) << 9)
+			ad.c += 0xfe00 - (uint32(b1) << 9)
 			ad.ct = 7
 		}
 	} else {
-		ad.stream.IncByteIdx()
-		ad.b = ad.stream.GetCurByteArith()
-		ad.c = ad.c + 0xff00 - (uint32(ad.b) << 8)
+		if idx < size {
+			idx++
+		}
+		ad.b = 0xff
+		if idx < size {
+			ad.b = bs.data[idx]
+		}
+		ad.c += 0xff00 - (uint32(ad.b) << 8)
 		ad.ct = 8
 	}
-	if !ad.stream.IsInBounds() {
+	bs.byteIdx = idx
+	bs.bitIdx = 0
+	if idx >= size {
 		ad.complete = true
-	}
-}
-
-// readValueA 读取A值
-func (ad *ArithDecoder) readValueA() {
-	for {
-		if ad.ct == 0 {
-			ad.byteIn()
-		}
-		ad.a <<= 1
-		ad.c <<= 1
-		ad.ct--
-		if (ad.a & defaultAValue) != 0 {
-			break
-		}
+		bs.exhausted = true
 	}
 }
 
 // ArithIntDecoder 算术整数解码器
 type ArithIntDecoder struct {
-	iax []ArithCtx
+	iax [512]ArithCtx
 }
 
 // NewArithIntDecoder 创建新的算术整数解码器
 // 返回: *ArithIntDecoder 解码器对象
 func NewArithIntDecoder() *ArithIntDecoder {
-	return &ArithIntDecoder{iax: make([]ArithCtx, 512)}
+	return &ArithIntDecoder{}
+}
+
+// Reset 重置全部上下文以便复用
+func (aid *ArithIntDecoder) Reset() {
+	aid.iax = [512]ArithCtx{}
 }
 
 // Decode 解码
@@ -224,7 +266,16 @@ func (aid *ArithIntDecoder) Decode(decoder *ArithDecoder) (int32, bool) {
 	prev := 1
 	s := decoder.Decode(&aid.iax[prev])
 	prev = (prev << 1) | s
-	idx := aid.recursiveDecode(decoder, &prev, 0)
+	kDepthEnd := len(kArithIntDecodeData) - 1
+	idx := 0
+	for idx < kDepthEnd {
+		d := decoder.Decode(&aid.iax[prev])
+		prev = (prev << 1) | d
+		if d == 0 {
+			break
+		}
+		idx++
+	}
 	nTemp := 0
 	for i := 0; i < kArithIntDecodeData[idx].nNeedBits; i++ {
 		d := decoder.Decode(&aid.iax[prev])
@@ -244,23 +295,6 @@ func (aid *ArithIntDecoder) Decode(decoder *ArithDecoder) (int32, bool) {
 	return val, true
 }
 
-// recursiveDecode 递归解码
-// 入参: decoder 算术解码器, prev 上一个值, depth 深度
-// 返回: int 解码结果
-func (aid *ArithIntDecoder) recursiveDecode(decoder *ArithDecoder, prev *int, depth int) int {
-	kDepthEnd := len(kArithIntDecodeData) - 1
-	if depth == kDepthEnd {
-		return kDepthEnd
-	}
-	cx := &aid.iax[*prev]
-	d := decoder.Decode(cx)
-	*prev = (*prev << 1) | d
-	if d == 0 {
-		return depth
-	}
-	return aid.recursiveDecode(decoder, prev, depth+1)
-}
-
 // ArithIaidDecoder IAID解码器
 type ArithIaidDecoder struct {
 	iaid         []ArithCtx
@@ -274,6 +308,19 @@ func NewArithIaidDecoder(sbsymCodeLen uint8) *ArithIaidDecoder {
 	return &ArithIaidDecoder{iaid: make([]ArithCtx, 1<<sbsymCodeLen), sbsymCodeLen: sbsymCodeLen}
 }
 
+// Reset 重置上下文以便复用, 容量足够时不重新分配
+// 入参: sbsymCodeLen 符号编码长度
+func (aid *ArithIaidDecoder) Reset(sbsymCodeLen uint8) {
+	n := 1 << sbsymCodeLen
+	if cap(aid.iaid) < n {
+		aid.iaid = make([]ArithCtx, n)
+	} else {
+		aid.iaid = aid.iaid[:n]
+		clear(aid.iaid)
+	}
+	aid.sbsymCodeLen = sbsymCodeLen
+}
+
 // Decode 解码
 // 入参: decoder 算术解码器
 // 返回: uint32 结果, error 错误信息
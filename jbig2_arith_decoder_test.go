@@ -0,0 +1,208 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// kArithTestEncoded T.88 H.2中的算术编码测试序列
+var kArithTestEncoded = []byte{
+	0x84, 0xC7, 0x3B, 0xFC, 0xE1, 0xA1, 0x43, 0x04, 0x02, 0x20, 0x00, 0x00, 0x41, 0x0D, 0xBB, 0x86,
+	0xF4, 0x31, 0x7F, 0xFF, 0x88, 0xFF, 0x37, 0x47, 0x1A, 0xDB, 0x6A, 0xDF, 0xFF, 0xAC,
+}
+
+// kArithTestDecoded T.88 H.2中测试序列对应的原始数据
+var kArithTestDecoded = []byte{
+	0x00, 0x02, 0x00, 0x51, 0x00, 0x00, 0x00, 0xC0, 0x03, 0x52, 0x87, 0x2A, 0xAA, 0xAA, 0xAA, 0xAA,
+	0x82, 0xC0, 0x20, 0x00, 0xFC, 0xD7, 0x9E, 0xF6, 0xBF, 0x7F, 0xED, 0x90, 0x4F, 0x46, 0xA3, 0xBF,
+}
+
+// arithTestData 生成固定的伪随机数据
+// 入参: n 字节数
+// 返回: []byte 数据
+func arithTestData(n int) []byte {
+	b := make([]byte, n)
+	x := uint32(2463534242)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x >> 24)
+	}
+	return b
+}
+
+func TestArithDecodeT88(t *testing.T) {
+	decoder := NewArithDecoder(NewBitStream(kArithTestEncoded, 0))
+	var cx ArithCtx
+	got := make([]byte, len(kArithTestDecoded))
+	for i := range got {
+		for k := 0; k < 8; k++ {
+			got[i] |= byte(decoder.Decode(&cx)) << uint(7-k)
+		}
+	}
+	if !bytes.Equal(got, kArithTestDecoded) {
+		t.Errorf("got % x, want % x", got, kArithTestDecoded)
+	}
+}
+
+func TestArithEncodeT88(t *testing.T) {
+	e := newTestEncoder()
+	var cx ArithCtx
+	for _, b := range kArithTestDecoded {
+		for k := 0; k < 8; k++ {
+			e.encode(&cx, int(b>>uint(7-k))&1)
+		}
+	}
+	if got := e.flush(); !bytes.Equal(got, kArithTestEncoded) {
+		t.Errorf("got % x, want % x", got, kArithTestEncoded)
+	}
+}
+
+// TestArithGolden 固定数据的解码结果, 期望值由逐位实现的解码器得到
+func TestArithGolden(t *testing.T) {
+	const oob = -1 << 31
+	decoder := NewArithDecoder(NewBitStream(arithTestData(4096), 0))
+	cx := make([]ArithCtx, 16)
+	h := fnv.New64a()
+	prev := 0
+	for i := 0; i < 8192; i++ {
+		bit := decoder.Decode(&cx[prev])
+		prev = (prev<<1 | bit) & 15
+		h.Write([]byte{byte(bit)})
+	}
+	if got := h.Sum64(); got != 0x7a3bb67c3fb92f03 {
+		t.Errorf("bit hash %#x, want 0x7a3bb67c3fb92f03", got)
+	}
+	var ints []int32
+	iax, iay := NewArithIntDecoder(), NewArithIntDecoder()
+	for i := 0; i < 24; i++ {
+		d := iax
+		if i%3 == 2 {
+			d = iay
+		}
+		v, ok := d.Decode(decoder)
+		if !ok {
+			v = oob
+		}
+		ints = append(ints, v)
+	}
+	wantInts := []int32{17, oob, -2, oob, -3, -3, oob, oob, -1, -3, oob, 54,
+		oob, -3, -1, -16, 12, 70, 15, -3, -18, 68, 14, 326}
+	if !slices.Equal(ints, wantInts) {
+		t.Errorf("integers %v, want %v", ints, wantInts)
+	}
+	var ids []uint32
+	iaid := NewArithIaidDecoder(5)
+	for i := 0; i < 24; i++ {
+		v, err := iaid.Decode(decoder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, v)
+	}
+	wantIDs := []uint32{21, 17, 21, 19, 22, 17, 8, 0, 2, 0, 10, 23, 2, 21, 17, 17, 11, 9, 17, 12, 9, 17, 21, 12}
+	if !slices.Equal(ids, wantIDs) {
+		t.Errorf("symbol IDs %v, want %v", ids, wantIDs)
+	}
+}
+
+func BenchmarkArithDecode(b *testing.B) {
+	data := arithTestData(1 << 16)
+	cx := make([]ArithCtx, 16)
+	b.SetBytes(int64(len(data)))
+	for n := 0; n < b.N; n++ {
+		clear(cx)
+		decoder := NewArithDecoder(NewBitStream(data, 0))
+		prev := 0
+		for i := 0; i < len(data)*8; i++ {
+			prev = (prev<<1 | decoder.Decode(&cx[prev])) & 15
+		}
+	}
+}
+
+func BenchmarkArithIntDecode(b *testing.B) {
+	data := arithTestData(1 << 16)
+	b.Run("int", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			decoder := NewArithDecoder(NewBitStream(data, 0))
+			iax := NewArithIntDecoder()
+			for i := 0; i < 4096; i++ {
+				iax.Decode(decoder)
+			}
+		}
+	})
+	b.Run("iaid", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			decoder := NewArithDecoder(NewBitStream(data, 0))
+			iaid := NewArithIaidDecoder(9)
+			for i := 0; i < 4096; i++ {
+				if _, err := iaid.Decode(decoder); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkCorpus 解码JBIG2_CORPUS目录中的全部文件
+// 目录中的每个文件为一个独立的JBIG2数据流, 同名且扩展名为.globals的文件作为其全局段
+// 未设置JBIG2_CORPUS时跳过, 可分别以通用区域为主与文本区域为主的语料目录运行
+func BenchmarkCorpus(b *testing.B) {
+	dir := os.Getenv("JBIG2_CORPUS")
+	if dir == "" {
+		b.Skip("JBIG2_CORPUS not set")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, ".globals") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var globals *Globals
+		if g, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+".globals")); err == nil {
+			if globals, err = ParseGlobals(g); err != nil {
+				b.Fatalf("%s: %v", name, err)
+			}
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(data), globals)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := dec.DecodeAll(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -52,6 +52,7 @@ type RegionInfo struct {
 	X      int32
 	Y      int32
 	Flags  uint8
+	Colour uint16
 }
 
 // HuffmanCode 霍夫曼编码
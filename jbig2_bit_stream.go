@@ -23,6 +23,8 @@ type BitStream struct {
 	bitIdx       uint32
 	key          uint64
 	littleEndian bool
+	padArith     bool
+	exhausted    bool
 }
 
 // NewBitStream 创建位流
@@ -59,6 +61,11 @@ func (b *BitStream) ReadNBits(bits uint32) (uint32, error) {
 	} else {
 		bitsToRead = lengthInBits - bitPos
 	}
+	if bits <= 32 {
+		val, _ := b.PeekNBits(bits)
+		b.SkipBits(bitsToRead)
+		return val >> (bits - bitsToRead), nil
+	}
 	var result uint32
 	for i := uint32(0); i < bitsToRead; i++ {
 		result = (result << 1) | uint32((b.data[b.byteIdx]>>(7-b.bitIdx))&0x01)
@@ -67,6 +74,33 @@ func (b *BitStream) ReadNBits(bits uint32) (uint32, error) {
 	return result, nil
 }
 
+// PeekNBits 预读指定位数而不移动位置
+// 入参: bits 位数(不超过32)
+// 返回: uint32 结果(数据不足时低位补0), uint32 实际可用位数
+func (b *BitStream) PeekNBits(bits uint32) (uint32, uint32) {
+	bitPos := b.GetBitPos()
+	lengthInBits := b.lengthInBits()
+	if bitPos >= lengthInBits || bits == 0 {
+		return 0, 0
+	}
+	avail := min(bits, lengthInBits-bitPos)
+	var window uint64
+	for n := uint32(0); n < 5; n++ {
+		window <<= 8
+		if idx := uint64(b.byteIdx) + uint64(n); idx < uint64(len(b.data)) {
+			window |= uint64(b.data[idx])
+		}
+	}
+	val := (window >> (40 - b.bitIdx - bits)) & (uint64(1)<<bits - 1)
+	return uint32(val), avail
+}
+
+// SkipBits 跳过指定位数
+// 入参: bits 位数
+func (b *BitStream) SkipBits(bits uint32) {
+	b.SetBitPos(b.GetBitPos() + bits)
+}
+
 // ReadNBitsInt32 读取指定位数的有符号整数
 // 入参: bits 位数
 // 返回: int32 结果, error 错误信息
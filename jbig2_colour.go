@@ -0,0 +1,138 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrColourUnsupported 页面使用了颜色扩展区域, 但解码方式只能输出双色像素
+// 分块、缩放、窗口与按行解码均不保存颜色, 只有Decode、DecodeAll与DecodePagesParallel返回调色板图像
+var ErrColourUnsupported = errors.New("colour pages are not supported by this decoding mode")
+
+// kColourExtFlag 区域段信息标志中的颜色扩展位(COLEXTFLAG)
+const kColourExtFlag = 0x08
+
+// kMaxPaletteColours 调色板最大颜色数
+const kMaxPaletteColours = 256
+
+// kDefaultForeground 未指定颜色的区域使用的前景颜色索引
+const kDefaultForeground = 1
+
+// kDefaultPalette 调用方未给出调色板时使用的双色调色板, 0为背景白色, 1为前景黑色
+var kDefaultPalette = color.Palette{color.Gray{Y: 255}, color.Gray{Y: 0}}
+
+// paintColour 将区域中置位的像素在页面颜色平面中标记为区域的前景颜色
+// 颜色平面只覆盖颜色扩展区域的外接矩形, 在组合颜色扩展区域时按需扩大, 平面之外与创建平面之前置位的像素为默认前景颜色
+// 入参: ri 区域信息, x 页面缓冲区中的列, y 页面缓冲区中的行, img 区域图像
+func (d *Document) paintColour(ri *RegionInfo, x, y int32, img *Image) {
+	area := image.Rect(int(x), int(y), int(x)+int(img.width), int(y)+int(img.height))
+	area = area.Intersect(image.Rect(0, 0, int(d.page.width), int(d.page.height)))
+	index := uint8(kDefaultForeground)
+	if ri.Flags&kColourExtFlag != 0 {
+		index = uint8(min(ri.Colour, kMaxPaletteColours-1))
+		d.growColourPlane(area)
+	}
+	area = area.Intersect(d.colourRect)
+	if area.Empty() {
+		return
+	}
+	w, stride := d.colourRect.Dx(), int(img.stride)
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		row := img.data[(py-int(y))*stride:]
+		plane := d.colourPlane[(py-d.colourRect.Min.Y)*w:]
+		for px := area.Min.X; px < area.Max.X; px++ {
+			if sx := px - int(x); row[sx>>3]&(0x80>>(sx&7)) != 0 {
+				plane[px-d.colourRect.Min.X] = index
+			}
+		}
+	}
+}
+
+// growColourPlane 扩大颜色平面使其覆盖指定矩形, 新增部分为默认前景颜色
+// 入参: r 页面缓冲区中的矩形
+func (d *Document) growColourPlane(r image.Rectangle) {
+	if r.Empty() || r.In(d.colourRect) {
+		return
+	}
+	old, oldRect := d.colourPlane, d.colourRect
+	d.colourRect = oldRect.Union(r)
+	d.colourPlane = make([]uint8, d.colourRect.Dx()*d.colourRect.Dy())
+	for i := range d.colourPlane {
+		d.colourPlane[i] = kDefaultForeground
+	}
+	w, ow := d.colourRect.Dx(), oldRect.Dx()
+	for y := oldRect.Min.Y; y < oldRect.Max.Y; y++ {
+		n := (y-d.colourRect.Min.Y)*w + oldRect.Min.X - d.colourRect.Min.X
+		copy(d.colourPlane[n:n+ow], old[(y-oldRect.Min.Y)*ow:])
+	}
+}
+
+// colourPage 将使用颜色扩展区域的页面转换为调色板图像
+// 颜色索引按DecoderOptions.Palette解释, 未给出时使用双色调色板
+// 返回: image.Image 调色板图像, 页面未使用颜色时为nil
+func (d *Document) colourPage() image.Image {
+	if !d.colourUsed {
+		return nil
+	}
+	palette := kDefaultPalette
+	if d.opts != nil && d.opts.Palette != nil {
+		palette = d.opts.Palette
+	}
+	return d.page.ToPaletted(d.colourPlane, d.colourRect, palette)
+}
+
+// colourErr 检查当前页面能否以双色像素输出
+// 返回: error 页面使用颜色扩展区域时为ErrColourUnsupported
+func (d *Document) colourErr() error {
+	if d.colourUsed {
+		return ErrColourUnsupported
+	}
+	return nil
+}
+
+// ToPaletted 转换为调色板图像
+// 未置位的像素为索引0, 置位的像素取颜色平面中的索引, 位于平面之外或索引超出调色板时取默认前景颜色
+// 入参: plane 按行排列的颜色索引平面, bounds 颜色平面覆盖的矩形, palette 调色板
+// 返回: *image.Paletted 调色板图像
+func (i *Image) ToPaletted(plane []uint8, bounds image.Rectangle, palette color.Palette) *image.Paletted {
+	if i == nil {
+		return nil
+	}
+	if len(palette) <= kDefaultForeground {
+		palette = append(append(color.Palette(nil), palette...), kDefaultPalette[len(palette):]...)
+	}
+	w, h := int(i.width), int(i.height)
+	img := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+	for y := 0; y < h; y++ {
+		out := img.Pix[y*img.Stride : y*img.Stride+w]
+		for x := range out {
+			if i.GetPixel(int32(x), int32(y)) == 0 {
+				continue
+			}
+			index := uint8(kDefaultForeground)
+			if image.Pt(x, y).In(bounds) {
+				n := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+				if n < len(plane) && int(plane[n]) < len(palette) {
+					index = plane[n]
+				}
+			}
+			out[x] = index
+		}
+	}
+	return img
+}
@@ -0,0 +1,224 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// testColourRegion 构造颜色扩展的通用区域段数据
+// 入参: img 图像, x y 位置, colour 前景颜色索引
+// 返回: []byte 段数据
+func testColourRegion(img *Image, x, y uint32, colour uint16) []byte {
+	b := testGenericRegion(img, x, y, ComposeOr, 0, false)
+	b[16] |= kColourExtFlag
+	return append(b[:17:17], append(binary.BigEndian.AppendUint16(nil, colour), b[17:]...)...)
+}
+
+// testPalette 构造调色板
+// 入参: n 颜色数
+// 返回: color.Palette 调色板
+func testPalette(n int) color.Palette {
+	p := make(color.Palette, n)
+	for i := range p {
+		p[i] = color.RGBA{R: byte(i * 40), G: byte(255 - i*40), B: byte(i), A: 0xFF}
+	}
+	return p
+}
+
+// testColourStream 构造一个普通区域之后跟随两个不相交颜色扩展区域的页面
+// 返回: []byte 数据流, []uint8 逐像素的期望颜色索引
+func testColourStream() ([]byte, []uint8) {
+	r := rand.New(rand.NewSource(47))
+	base := randomImage(r, 64, 40, 16)
+	a := randomImage(r, 24, 16, 6)
+	b := randomImage(r, 24, 16, 6)
+	s := newTestStream(1)
+	s.segment(48, 1, nil, testPageInfo(64, 40, 0))
+	s.segment(38, 1, nil, testGenericRegion(base, 0, 0, ComposeOr, 0, false))
+	s.segment(38, 1, nil, testColourRegion(a, 4, 2, 2))
+	s.segment(38, 1, nil, testColourRegion(b, 32, 20, 3))
+	s.segment(49, 1, nil, nil)
+	want := make([]uint8, 64*40)
+	for y := int32(0); y < 40; y++ {
+		for x := int32(0); x < 64; x++ {
+			switch {
+			case x >= 32 && y >= 20 && x < 56 && y < 36 && b.GetPixel(x-32, y-20) != 0:
+				want[y*64+x] = 3
+			case x >= 4 && y >= 2 && x < 28 && y < 18 && a.GetPixel(x-4, y-2) != 0:
+				want[y*64+x] = 2
+			case base.GetPixel(x, y) != 0:
+				want[y*64+x] = 1
+			}
+		}
+	}
+	return s.out, want
+}
+
+func TestColourRegions(t *testing.T) {
+	data, want := testColourStream()
+	for _, palette := range []color.Palette{nil, testPalette(4)} {
+		dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{Palette: palette})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, ok := img.(*image.Paletted)
+		if !ok {
+			t.Fatalf("got %T, want *image.Paletted", img)
+		}
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 64; x++ {
+				index := want[y*64+x]
+				// 双色调色板中超出范围的索引取默认前景颜色
+				if palette == nil && index > kDefaultForeground {
+					index = kDefaultForeground
+				}
+				if got := p.ColorIndexAt(x, y); got != index {
+					t.Fatalf("palette %d: index at (%d,%d) = %d, want %d", len(palette), x, y, got, index)
+				}
+			}
+		}
+		if palette != nil && !slices.Equal(p.Palette, palette) {
+			t.Errorf("palette %v, want %v", p.Palette, palette)
+		}
+		// 颜色平面只覆盖两个颜色扩展区域的外接矩形
+		if rect := image.Rect(4, 2, 56, 36); dec.doc.colourRect != rect || len(dec.doc.colourPlane) != rect.Dx()*rect.Dy() {
+			t.Errorf("colour plane covers %v with %d bytes, want %v", dec.doc.colourRect, len(dec.doc.colourPlane), rect)
+		}
+	}
+	// 未使用颜色扩展区域的页面不受调色板影响
+	plain := testRenderStream()
+	dec, err := NewDecoderWithOptions(bytes.NewReader(plain), DecoderOptions{Palette: testPalette(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := goImagesEqual(img, decodeAllPages(t, plain)[0]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestColourUnsupportedModes(t *testing.T) {
+	data, _ := testColourStream()
+	discard := RowWriterFunc(func(int, []byte) error { return nil })
+	tests := []struct {
+		name   string
+		decode func(*Decoder) error
+	}{
+		{"tiled", func(d *Decoder) error {
+			_, err := d.DecodeTiled()
+			return err
+		}},
+		{"tile size", func(d *Decoder) error {
+			d.SetTileSize(16)
+			_, err := d.Decode()
+			return err
+		}},
+		{"parallel tiles", func(d *Decoder) error {
+			d.SetTileSize(16)
+			_, err := d.DecodePagesParallel(context.Background(), nil, 2)
+			return err
+		}},
+		{"scaled", func(d *Decoder) error {
+			_, err := d.DecodeScaled(32, 0)
+			return err
+		}},
+		{"render scale", func(d *Decoder) error {
+			_, err := d.DecodeWithOptions(RenderOptions{Scale: 0.5})
+			return err
+		}},
+		{"region", func(d *Decoder) error {
+			_, err := d.DecodeRegion(1, image.Rect(0, 0, 20, 20))
+			return err
+		}},
+		{"rows", func(d *Decoder) error {
+			return d.DecodeRows(discard, RowPacked)
+		}},
+	}
+	for _, tt := range tests {
+		dec, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tt.decode(dec); !errors.Is(err, ErrColourUnsupported) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, ErrColourUnsupported)
+		}
+	}
+}
+
+func TestColourRowsStriped(t *testing.T) {
+	// 第一个条带不含颜色, 颜色扩展区域位于第二个条带, 在其条带结束段输出之前返回错误
+	r := rand.New(rand.NewSource(48))
+	s := newTestStream(1)
+	info := testPageInfo(48, 0xFFFFFFFF, 0)
+	binary.BigEndian.PutUint16(info[len(info)-2:], 0x8000|16)
+	s.segment(48, 1, nil, info)
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 48, 16, 6), 0, 0, ComposeOr, 0, false))
+	s.segment(50, 1, nil, binary.BigEndian.AppendUint32(nil, 15))
+	s.segment(38, 1, nil, testColourRegion(randomImage(r, 48, 16, 6), 0, 16, 2))
+	s.segment(50, 1, nil, binary.BigEndian.AppendUint32(nil, 31))
+	s.segment(49, 1, nil, nil)
+	dec, err := NewDecoder(bytes.NewReader(s.out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := 0
+	err = dec.DecodeRows(RowWriterFunc(func(int, []byte) error {
+		rows++
+		return nil
+	}), RowGray)
+	if !errors.Is(err, ErrColourUnsupported) || rows != 16 {
+		t.Errorf("error %v after %d rows, want %v after 16 rows", err, rows, ErrColourUnsupported)
+	}
+}
+
+func TestColourSnapshot(t *testing.T) {
+	data, _ := testColourStream()
+	palette := testPalette(4)
+	dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{Palette: palette})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := dec.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreDecoder(data, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := restored.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := img.(*image.Paletted); !ok || !slices.Equal(p.Palette, palette) {
+		t.Errorf("restored decoder lost the palette option: %T", img)
+	}
+}
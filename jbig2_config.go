@@ -0,0 +1,290 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"io"
+	"log/slog"
+)
+
+// Organization 数据流组织方式
+type Organization int
+
+const (
+	// OrganizationAuto 由文件头或探测确定
+	OrganizationAuto Organization = iota
+	// OrganizationSequential 顺序组织, 段头与段数据交替出现
+	OrganizationSequential
+	// OrganizationRandomAccess 随机访问组织, 全部段头在前, 段数据在后
+	OrganizationRandomAccess
+	// OrganizationEmbedded 嵌入式组织, 不含文件头的顺序段, 如PDF中的数据流
+	OrganizationEmbedded
+)
+
+// HeaderMode 文件头是否存在
+type HeaderMode int
+
+const (
+	// HeaderAuto 数据以文件头标识开始时视为存在
+	HeaderAuto HeaderMode = iota
+	// HeaderPresent 文件头必须存在
+	HeaderPresent
+	// HeaderAbsent 不含文件头, 数据从第一个段头开始
+	HeaderAbsent
+)
+
+// DecoderOptions 解码器选项
+type DecoderOptions struct {
+	// Globals 已解析的全局段, 可为nil
+	Globals *Globals
+	// Recover 启用错误恢复模式
+	// 截断的算术数据以0xFF填充并保留已解码的行, 跳过解码失败或引用缺失段的段, 容忍缺失的页面结束段
+	Recover bool
+	// Logger 恢复模式下记录警告的日志记录器, 可为nil
+	Logger *slog.Logger
+	// OnWarning 恢复模式下接收警告的回调, 可为nil
+	OnWarning func(Warning)
+	// Organization 数据流组织方式
+	Organization Organization
+	// ByteOrder 段头整数的字节序, nil表示未指定
+	ByteOrder binary.ByteOrder
+	// Header 文件头是否存在
+	Header HeaderMode
+	// PageCount 已知页数, 大于0时解码该数量的页面后结束
+	PageCount int
+	// Strict 严格模式, 禁用全部启发式探测与回退猜测
+	// 未给出的配置按文件头与标准默认值确定, 选项与文件头矛盾时返回错误
+	Strict bool
+	// EnforceProfiles 强制检查配置段声明的配置
+	// 段使用声明的配置不允许的特性或声明了未知配置时解码失败, 未声明配置时不加限制
+	EnforceProfiles bool
+	// ProfileFeatures 配置编号到允许特性的映射, 补充或替代内置配置定义
+	ProfileFeatures map[uint32]Feature
+	// Palette 颜色扩展区域的颜色索引对应的调色板, nil表示双色调色板
+	// 索引0为背景颜色, 索引1为未指定颜色的区域使用的前景颜色, 超出调色板的索引取索引1
+	// 颜色调色板段(类型54)的数据不被解析, 调色板只能由调用方给出
+	Palette color.Palette
+	// Extensions 本解码器的扩展段处理函数, 键为不含必需与依赖标志位的扩展类型
+	// 优先于RegisterExtension注册的处理函数; ParseGlobals解析全局段时没有解码器选项, 只使用后者
+	Extensions map[uint32]ExtensionHandler
+}
+
+// NewDecoderWithOptions 按选项创建解码器
+// 选项给出组织方式、字节序或文件头, 或启用严格模式时不进行启发式探测
+// 入参: r 读取器, opts 解码器选项
+// 返回: *Decoder 解码器, error 错误信息
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) (*Decoder, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return newDecoderFromData(data, opts)
+}
+
+// ProbeCandidate 启发式探测评估的候选配置
+type ProbeCandidate struct {
+	// Offset 第一个段头的偏移量
+	Offset int
+	// RandomAccess 段头是否按随机访问方式省略段编号与页面关联
+	RandomAccess bool
+	// LittleEndian 是否小端序
+	LittleEndian bool
+	// OrgMode 组织模式, 为1时随机访问段头仍包含段编号与页面关联
+	OrgMode int
+	// Grouped 段头是否集中在段数据之前
+	Grouped bool
+	// Score 得分, 得分最高的候选被选中
+	Score int
+	// Skipped 数据不足或与文件头不符, 未参与评分
+	Skipped bool
+}
+
+// ProbeReport 数据流配置的确定过程
+type ProbeReport struct {
+	// Explicit 配置由选项与文件头确定, 未进行启发式探测
+	Explicit bool
+	// Candidates 启发式探测评估的候选配置
+	Candidates []ProbeCandidate
+	// Chosen 选中的候选序号, 未选中时为-1
+	Chosen int
+	// Fallback 探测失败后按带全局段的嵌入式数据流处理
+	Fallback bool
+	// Organization 最终使用的组织方式
+	Organization Organization
+	// ByteOrder 最终使用的字节序
+	ByteOrder binary.ByteOrder
+	// Offset 第一个段头在输入数据中的偏移量
+	Offset int
+}
+
+// streamConfig 数据流配置
+type streamConfig struct {
+	offset       int
+	randomAccess bool
+	littleEndian bool
+	orgMode      int
+	grouped      bool
+	pageCount    uint32
+	hasPageCount bool
+}
+
+// ProbeReport 获取数据流配置的确定过程
+// 返回: ProbeReport 探测报告
+func (d *Decoder) ProbeReport() ProbeReport {
+	return d.probe
+}
+
+// PageCount 获取页数
+// 选项给出的页数优先, 其次为文件头中的页数
+// 返回: int 页数, bool 页数是否已知
+func (d *Decoder) PageCount() (int, bool) {
+	if d.opts.PageCount > 0 {
+		return d.opts.PageCount, true
+	}
+	if d.config.hasPageCount {
+		return int(d.config.pageCount), true
+	}
+	return 0, false
+}
+
+// explicitOptions 选项是否明确给出了数据流配置
+// 返回: bool 是否明确给出
+func (o *DecoderOptions) explicitOptions() bool {
+	return o.Strict || o.Organization != OrganizationAuto || o.ByteOrder != nil || o.Header != HeaderAuto
+}
+
+// resolveConfig 确定数据流配置
+// 明确给出配置或严格模式时按选项与文件头确定, 否则启发式探测
+// 入参: data 数据, opts 解码器选项
+// 返回: streamConfig 数据流配置, ProbeReport 探测报告, error 错误信息
+func resolveConfig(data []byte, opts *DecoderOptions) (streamConfig, ProbeReport, error) {
+	if opts.explicitOptions() {
+		cfg, org, err := explicitConfig(data, opts)
+		report := ProbeReport{Explicit: true, Chosen: -1, Organization: org, ByteOrder: byteOrder(cfg.littleEndian), Offset: cfg.offset}
+		return cfg, report, err
+	}
+	report := probeStream(data)
+	var cfg streamConfig
+	if report.Chosen >= 0 {
+		c := report.Candidates[report.Chosen]
+		cfg = streamConfig{offset: c.Offset, randomAccess: c.RandomAccess, littleEndian: c.LittleEndian, orgMode: c.OrgMode, grouped: c.Grouped}
+		cfg.pageCount, cfg.hasPageCount = headerPageCount(data)
+		report.Organization = OrganizationSequential
+		if c.Grouped {
+			report.Organization = OrganizationRandomAccess
+		}
+	} else if opts.Globals != nil {
+		report.Fallback = true
+		report.Organization = OrganizationEmbedded
+		if len(data) >= 4 && data[0] != 0 && data[1] == 0 && data[2] == 0 && data[3] == 0 {
+			cfg.littleEndian = true
+		}
+	} else {
+		return cfg, report, errors.New("no valid jbig2 configuration found")
+	}
+	report.ByteOrder = byteOrder(cfg.littleEndian)
+	report.Offset = cfg.offset
+	return cfg, report, nil
+}
+
+// explicitConfig 按选项与文件头确定数据流配置
+// 选项优先于文件头, 严格模式下两者矛盾时返回错误, 未给出的字节序按标准取大端序
+// 入参: data 数据, opts 解码器选项
+// 返回: streamConfig 数据流配置, Organization 组织方式, error 错误信息
+func explicitConfig(data []byte, opts *DecoderOptions) (streamConfig, Organization, error) {
+	cfg := streamConfig{littleEndian: isLittleEndian(opts.ByteOrder)}
+	org := opts.Organization
+	hasHeader := bytes.HasPrefix(data, kFileSignature)
+	switch opts.Header {
+	case HeaderPresent:
+		if !hasHeader {
+			return cfg, org, errors.New("file header not found")
+		}
+		if org == OrganizationEmbedded {
+			return cfg, org, errors.New("embedded organisation has no file header")
+		}
+	case HeaderAbsent:
+		hasHeader = false
+	case HeaderAuto:
+		if hasHeader && org == OrganizationEmbedded {
+			if opts.Strict {
+				return cfg, org, errors.New("file header found in embedded stream")
+			}
+			hasHeader = false
+		}
+	}
+	if hasHeader {
+		if len(data) <= len(kFileSignature) {
+			return cfg, org, errors.New("truncated file header")
+		}
+		flags := data[len(kFileSignature)]
+		headerOrg := OrganizationRandomAccess
+		if flags&0x01 != 0 {
+			headerOrg = OrganizationSequential
+		}
+		if org == OrganizationAuto {
+			org = headerOrg
+		} else if org != headerOrg && opts.Strict {
+			return cfg, org, errors.New("organisation does not match file header")
+		}
+		cfg.offset = len(kFileSignature) + 1
+		if flags&0x02 == 0 {
+			cfg.offset += 4
+			if len(data) < cfg.offset {
+				return cfg, org, errors.New("truncated file header")
+			}
+			cfg.pageCount, cfg.hasPageCount = binary.BigEndian.Uint32(data[cfg.offset-4:]), true
+		}
+	} else if org == OrganizationAuto {
+		org = OrganizationEmbedded
+	}
+	if opts.Strict && opts.PageCount > 0 && cfg.hasPageCount && uint64(opts.PageCount) != uint64(cfg.pageCount) {
+		return cfg, org, errors.New("page count does not match file header")
+	}
+	cfg.grouped = org == OrganizationRandomAccess
+	return cfg, org, nil
+}
+
+// headerPageCount 读取文件头中的页数
+// 入参: data 数据
+// 返回: uint32 页数, bool 文件头是否包含页数
+func headerPageCount(data []byte) (uint32, bool) {
+	n := len(kFileSignature)
+	if len(data) < n+5 || !bytes.HasPrefix(data, kFileSignature) || data[n]&0x02 != 0 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(data[n+1:]), true
+}
+
+// isLittleEndian 字节序是否为小端序, 按字节序的实际行为判断, 本机字节序同样适用
+// 入参: order 字节序, 可为nil
+// 返回: bool 是否小端序
+func isLittleEndian(order binary.ByteOrder) bool {
+	return order != nil && order.Uint16([]byte{1, 0}) == 1
+}
+
+// byteOrder 将小端序标志转换为字节序
+// 入参: littleEndian 是否小端序
+// 返回: binary.ByteOrder 字节序
+func byteOrder(littleEndian bool) binary.ByteOrder {
+	if littleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
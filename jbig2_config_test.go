@@ -0,0 +1,106 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+)
+
+func TestExplicitByteOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order binary.ByteOrder
+		want  bool
+	}{
+		{"unspecified", nil, false},
+		{"big", binary.BigEndian, false},
+		{"little", binary.LittleEndian, true},
+		{"native", binary.NativeEndian, binary.NativeEndian.Uint16([]byte{1, 0}) == 1},
+	}
+	for _, tt := range tests {
+		cfg, _, err := explicitConfig(nil, &DecoderOptions{ByteOrder: tt.order})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if cfg.littleEndian != tt.want {
+			t.Errorf("%s: little endian %v, want %v", tt.name, cfg.littleEndian, tt.want)
+		}
+	}
+}
+
+func TestStrictSkipsSWFUnwrap(t *testing.T) {
+	// 段编号0x43575300的段头以"CWS"开始
+	s := &testStream{num: 0x43575300}
+	s.segment(48, 1, nil, testPageInfo(16, 8, 0))
+	s.segment(49, 1, nil, nil)
+	opts := DecoderOptions{Strict: true, Organization: OrganizationEmbedded}
+	dec, err := NewDecoderWithOptions(bytes.NewReader(s.out), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Errorf("page size %dx%d, want 16x8", b.Dx(), b.Dy())
+	}
+	if _, err := NewDecoderWithOptions(bytes.NewReader(s.out), DecoderOptions{Organization: OrganizationEmbedded}); err == nil {
+		t.Error("expected SWF unwrapping to reject the stream without Strict")
+	}
+}
+
+func TestSWFUnwrap(t *testing.T) {
+	data := testRenderStream()
+	// 矩形字段位数为0时占1字节, 其后为帧率与帧数, 再后为长格式的DefineBits标签
+	var body bytes.Buffer
+	body.Write([]byte{0, 0, 0, 0, 0})
+	body.Write(binary.LittleEndian.AppendUint16(nil, 6<<6|0x3F))
+	body.Write(binary.LittleEndian.AppendUint32(nil, uint32(2+len(data))))
+	body.Write([]byte{1, 0})
+	body.Write(data)
+	var swf bytes.Buffer
+	swf.WriteString("CWS\x0a")
+	swf.Write(binary.LittleEndian.AppendUint32(nil, uint32(8+body.Len())))
+	zw := zlib.NewWriter(&swf)
+	zw.Write(body.Bytes())
+	zw.Close()
+
+	want := decodeAllPages(t, data)
+	for _, newDecoder := range []func() (*Decoder, error){
+		func() (*Decoder, error) { return NewDecoder(bytes.NewReader(swf.Bytes())) },
+		func() (*Decoder, error) { return NewDecoderWithOptions(bytes.NewReader(swf.Bytes()), DecoderOptions{}) },
+	} {
+		dec, err := newDecoder()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := goImagesEqual(img, want[0]); err != nil {
+			t.Error(err)
+		}
+	}
+	if dec, err := NewDecoderWithOptions(bytes.NewReader(swf.Bytes()), DecoderOptions{Strict: true}); err == nil {
+		if _, err := dec.Decode(); err == nil {
+			t.Error("strict mode decoded SWF-wrapped data")
+		}
+	}
+}
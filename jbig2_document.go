@@ -14,6 +14,11 @@
 
 package jbig2
 
+import (
+	"fmt"
+	"image"
+)
+
 // Result 解析结果
 type Result int
 
@@ -35,9 +40,9 @@ type Document struct {
 	stream          *BitStream
 	globalContext   *Document
 	segmentList     []*Segment
+	deferredRelease []*Segment
 	page            *Image
 	pageInfoList    []*PageInfo
-	symbolDictCache map[uint64]*SymbolDict
 	segment         *Segment
 	offset          uint32
 	inPage          bool
@@ -45,6 +50,33 @@ type Document struct {
 	pauseStep       int
 	randomAccess    bool
 	isGlobal        bool
+	regionWorkers   int
+	deferCompose    bool
+	pendingCompose  []pendingCompose
+	viewport        *image.Rectangle
+	pageOrigin      image.Point
+	pageClipped     bool
+	pageHeight      uint32
+	tiledPage       *TiledImage
+	tileSize        int
+	rowSink         RowWriter
+	rowFormat       RowFormat
+	rowErr          error
+	opts            *DecoderOptions
+	warnings        []Warning
+	baseOffset      uint32
+	groupedParsed   bool
+	groupedQueue    []*Segment
+	groupedOffset   uint32
+	colourPlane     []uint8
+	colourRect      image.Rectangle
+	colourUsed      bool
+	profiles        []uint32
+	profileErr      error
+	globalsChecked  bool
+	comments        []Comment
+	pageComments    []Comment
+	extErr          error
 	Grouped         bool
 	OrgMode         int
 }
@@ -55,21 +87,22 @@ func (d *Document) GetSegments() []*Segment {
 	return d.segmentList
 }
 
-// GetGlobalContext 获取全局上下文
-// 返回: *Document 全局上下文
-func (d *Document) GetGlobalContext() *Document {
-	return d.globalContext
-}
-
 // PageInfo 页面信息
 type PageInfo struct {
-	Width             uint32
-	Height            uint32
-	ResolutionX       uint32
-	ResolutionY       uint32
-	DefaultPixelValue bool
-	IsStriped         bool
-	MaxStripeSize     uint16
+	Width                  uint32
+	Height                 uint32
+	ResolutionX            uint32
+	ResolutionY            uint32
+	EventuallyLossless     bool
+	MightContainRefinement bool
+	DefaultPixelValue      bool
+	DefaultComposeOp       ComposeOp
+	// RequiresAuxBuffers 页面需要辅助缓冲区, 仅作记录
+	// 中间区域与细化参考总是解码到独立的缓冲区, 该标志不影响输出
+	RequiresAuxBuffers  bool
+	ComposeOpOverridden bool
+	IsStriped           bool
+	MaxStripeSize       uint16
 }
 
 // NewDocument 创建文档对象
@@ -79,20 +112,25 @@ func NewDocument(data []byte, globalData []byte, randomAccess bool, littleEndian
 	stream := NewBitStream(data, 0)
 	stream.SetLittleEndian(littleEndian)
 	doc := &Document{
-		stream:          stream,
-		symbolDictCache: make(map[uint64]*SymbolDict),
-		randomAccess:    randomAccess,
+		stream:       stream,
+		randomAccess: randomAccess,
 	}
 	if len(globalData) > 0 {
-		doc.globalContext = &Document{
-			stream:          NewBitStream(globalData, 0),
-			isGlobal:        true,
-			symbolDictCache: doc.symbolDictCache,
-		}
+		doc.globalContext = newGlobalDocument(globalData)
 	}
 	return doc
 }
 
+// newGlobalDocument 创建全局段文档对象
+// 入参: data 全局段数据
+// 返回: *Document 文档对象
+func newGlobalDocument(data []byte) *Document {
+	return &Document{
+		stream:   NewBitStream(data, 0),
+		isGlobal: true,
+	}
+}
+
 // ParseSegmentHeader 解析段头
 // 入参: segment 段对象
 // 返回: Result 结果
@@ -129,8 +167,15 @@ func (d *Document) ParseSegmentHeader(segment *Segment) Result {
 			return ResultFailure
 		}
 		retentionBits := segment.ReferredToSegmentCount + 1
-		bytesToSkip := (retentionBits + 7) / 8
-		d.stream.AddOffset(uint32(bytesToSkip))
+		retention := make([]byte, (retentionBits+7)/8)
+		for i := range retention {
+			if val, err := d.stream.Read1Byte(); err != nil {
+				return ResultFailure
+			} else {
+				retention[i] = val
+			}
+		}
+		segment.setRetentionFlags(retention)
 	} else {
 		if val, err := d.stream.Read1Byte(); err != nil {
 			return ResultFailure
@@ -138,6 +183,7 @@ func (d *Document) ParseSegmentHeader(segment *Segment) Result {
 			cTemp = val
 		}
 		segment.ReferredToSegmentCount = int32(cTemp >> 5)
+		segment.setRetentionFlags([]byte{cTemp & 0x1F})
 	}
 	cSSize := 1
 	if segment.Number > 65536 {
@@ -203,27 +249,41 @@ func (d *Document) ParseSegmentHeader(segment *Segment) Result {
 	return ResultSuccess
 }
 
-// FindSegmentByNumber 查找段
+// FindSegmentByNumber 在当前文档中查找段
+// 全局段可被多个解码器共享, 不通过该方法返回
 // 入参: number 段编号
 // 返回: *Segment 段对象
 func (d *Document) FindSegmentByNumber(number uint32) *Segment {
+	return d.findLocalSegment(number)
+}
+
+// findSegment 依次在全局上下文与当前文档中查找段
+// 入参: number 段编号
+// 返回: *Segment 段对象
+func (d *Document) findSegment(number uint32) *Segment {
 	if d.globalContext != nil {
-		if seg := d.globalContext.FindSegmentByNumber(number); seg != nil {
-			return seg
-		}
-	}
-	for _, seg := range d.segmentList {
-		if seg.Number == number {
+		if seg := d.globalContext.findSegment(number); seg != nil {
 			return seg
 		}
 	}
-	return nil
+	return d.findLocalSegment(number)
 }
 
 // ParseSegmentData 解析段数据
 // 入参: segment 段对象
 // 返回: Result 结果
 func (d *Document) ParseSegmentData(segment *Segment) Result {
+	if err := d.checkProfile(segment); err != nil {
+		d.profileErr = err
+		return ResultFailure
+	}
+	return d.parseSegmentContent(segment)
+}
+
+// parseSegmentContent 按段类型解析段数据, 不检查声明的配置
+// 入参: segment 段对象
+// 返回: Result 结果
+func (d *Document) parseSegmentContent(segment *Segment) Result {
 	switch segment.Flags.Type {
 	case 0:
 		return d.parseSymbolDict(segment)
@@ -255,15 +315,15 @@ func (d *Document) ParseSegmentData(segment *Segment) Result {
 		d.inPage = false
 		return ResultPageCompleted
 	case 50:
-		d.stream.AddOffset(segment.DataLength)
+		return d.parseEndOfStripe(segment)
 	case 51:
 		return ResultEndReached
 	case 52:
-		d.stream.AddOffset(segment.DataLength)
+		return d.parseProfiles(segment)
 	case 53:
 		return d.parseTable(segment)
 	case 62:
-		d.stream.AddOffset(segment.DataLength)
+		return d.parseExtension(segment)
 	default:
 		d.stream.AddOffset(segment.DataLength)
 	}
@@ -273,23 +333,54 @@ func (d *Document) ParseSegmentData(segment *Segment) Result {
 // DecodeSequential 顺序解码
 // 返回: Result 结果
 func (d *Document) DecodeSequential() Result {
-	if d.stream.GetByteLeft() <= 0 {
-		return ResultEndReached
-	}
 	if d.Grouped {
 		return d.decodeGrouped()
 	}
+	if d.stream.GetByteLeft() <= 0 {
+		return ResultEndReached
+	}
 	for d.stream.GetByteLeft() > 0 {
 		if d.segment == nil {
 			d.segment = NewSegment()
 			ret := d.ParseSegmentHeader(d.segment)
 			if ret != ResultSuccess {
 				d.segment = nil
-				break
+				if !d.recovering() {
+					return ResultFailure
+				}
+				// 恢复模式下段头不完整视为数据流结束, 未结束的页面按缺少页面结束段处理
+				d.stream.SetOffset(d.stream.GetLength())
+				return ResultEndReached
 			}
 			d.offset = d.stream.GetOffset()
 		}
+		if d.regionWorkers > 1 && d.inPage && d.isBatchableRegion(d.segment) {
+			ret := d.decodeRegionBatch()
+			if ret == ResultSuccess {
+				continue
+			}
+			if !d.recovering() {
+				d.segment = nil
+				return ret
+			}
+			// 恢复模式下逐段解码以定位失败的段
+			d.stream.SetOffset(d.offset)
+		}
+		if d.recovering() {
+			if ret, handled := d.recoverBefore(); handled {
+				if ret != ResultSuccess {
+					return ret
+				}
+				continue
+			}
+		}
 		ret := d.ParseSegmentData(d.segment)
+		if d.recovering() {
+			var handled bool
+			if ret, handled = d.recoverAfter(ret); handled {
+				continue
+			}
+		}
 		if ret == ResultEndReached {
 			d.segmentList = append(d.segmentList, d.segment)
 			d.segment = nil
@@ -304,54 +395,108 @@ func (d *Document) DecodeSequential() Result {
 			d.segment = nil
 			return ret
 		}
-		if d.segment.DataLength != 0xFFFFFFFF {
-			newOffset := int64(d.offset) + int64(d.segment.DataLength)
-			if uint32(newOffset) <= d.stream.GetLength() {
-				d.stream.SetOffset(uint32(newOffset))
-			} else {
-				d.stream.SetOffset(d.stream.GetLength())
-			}
+		d.finishSegment()
+	}
+	return ResultSuccess
+}
+
+// finishSegment 完成当前段: 应用保留规则, 定位到下一段段头并记录该段
+func (d *Document) finishSegment() {
+	d.applyRetention(d.segment)
+	if d.segment.DataLength != 0xFFFFFFFF {
+		newOffset := int64(d.offset) + int64(d.segment.DataLength)
+		if uint32(newOffset) <= d.stream.GetLength() {
+			d.stream.SetOffset(uint32(newOffset))
 		} else {
-			d.stream.AddOffset(4)
+			d.stream.SetOffset(d.stream.GetLength())
 		}
-		d.segmentList = append(d.segmentList, d.segment)
-		d.segment = nil
+	} else {
+		d.stream.AddOffset(4)
 	}
-	return ResultSuccess
+	d.segmentList = append(d.segmentList, d.segment)
+	d.segment = nil
 }
 
 // decodeGrouped 分组解码
+// 首次调用时读取全部段头, 之后按段头顺序解析段数据, 每完成一页返回一次
 // 返回: Result 结果
 func (d *Document) decodeGrouped() Result {
-	for d.stream.GetByteLeft() > 0 {
-		seg := NewSegment()
-		ret := d.ParseSegmentHeader(seg)
-		if ret != ResultSuccess {
-			break
-		}
-		d.segmentList = append(d.segmentList, seg)
-		if seg.Flags.Type == 51 {
-			break
+	if !d.groupedParsed {
+		d.groupedParsed = true
+		for d.stream.GetByteLeft() > 0 {
+			seg := NewSegment()
+			ret := d.ParseSegmentHeader(seg)
+			if ret != ResultSuccess {
+				break
+			}
+			d.segmentList = append(d.segmentList, seg)
+			d.groupedQueue = append(d.groupedQueue, seg)
+			if seg.Flags.Type == 51 {
+				break
+			}
 		}
+		d.groupedOffset = d.stream.GetOffset()
 	}
-	currentDataOffset := d.stream.GetOffset()
-	for _, seg := range d.segmentList {
-		if seg.DataLength == 0 {
-			continue
-		}
+	for len(d.groupedQueue) > 0 {
+		seg := d.groupedQueue[0]
+		d.groupedQueue[0] = nil
+		d.groupedQueue = d.groupedQueue[1:]
+		currentDataOffset := d.groupedOffset
 		d.stream.SetOffset(currentDataOffset)
 		d.segment = seg
 		d.offset = currentDataOffset
+		if d.recovering() {
+			if refNum, ok := d.missingReference(seg); ok {
+				d.warn(seg.Number, currentDataOffset, fmt.Errorf("%w: %d", ErrMissingSegment, refNum))
+				d.releaseSegment(seg)
+				if !d.skipGroupedData(seg) {
+					break
+				}
+				continue
+			}
+		}
 		ret := d.ParseSegmentData(seg)
+		d.segment = nil
 		if ret == ResultFailure {
-			return ResultFailure
+			if !d.recovering() || d.profileErr != nil {
+				return ResultFailure
+			}
+			d.warn(seg.Number, currentDataOffset, d.failureCause())
+			d.releaseSegment(seg)
+			if !d.skipGroupedData(seg) {
+				break
+			}
+			continue
+		}
+		if d.recovering() && d.stream.exhausted {
+			d.stream.exhausted = false
+			d.warn(seg.Number, currentDataOffset, ErrTruncatedData)
+		}
+		d.applyRetention(seg)
+		if !d.skipGroupedData(seg) {
+			break
+		}
+		if ret == ResultPageCompleted {
+			return ResultPageCompleted
 		}
-		if seg.DataLength != 0xFFFFFFFF {
-			currentDataOffset += seg.DataLength
-			d.stream.SetOffset(currentDataOffset)
+		if ret == ResultEndReached {
+			break
 		}
 	}
-	return ResultSuccess
+	d.groupedQueue = nil
+	return ResultEndReached
+}
+
+// skipGroupedData 定位到分组解码中下一段的段数据
+// 入参: seg 当前段
+// 返回: bool 下一段位置是否已知, 数据长度未知的段之后无法继续
+func (d *Document) skipGroupedData(seg *Segment) bool {
+	if seg.DataLength == 0xFFFFFFFF {
+		return false
+	}
+	d.groupedOffset += seg.DataLength
+	d.stream.SetOffset(min(d.groupedOffset, d.stream.GetLength()))
+	return true
 }
 
 // parseSymbolDict 解析符号字典段
@@ -367,10 +512,11 @@ func (d *Document) parseSymbolDict(segment *Segment) Result {
 	sdd := NewSDDProc()
 	sdd.SDHUFF = (flags & 0x0001) != 0
 	sdd.SDREFAGG = ((flags >> 1) & 0x0001) != 0
-	sdd.SDMMR = ((flags >> 10) & 0x01) != 0
-	if !sdd.SDHUFF {
-		sdd.SDTEMPLATE = uint8((flags >> 2) & 0x0003)
-		sdd.SDRTEMPLATE = ((flags >> 4) & 0x0001) != 0
+	sdd.SDRTEMPLATE = ((flags >> 12) & 0x0001) != 0
+	if sdd.SDHUFF {
+		sdd.SDMMR = ((flags >> 10) & 0x01) != 0
+	} else {
+		sdd.SDTEMPLATE = uint8((flags >> 10) & 0x0003)
 		dwTemp := 2
 		if sdd.SDTEMPLATE == 0 {
 			dwTemp = 8
@@ -405,7 +551,7 @@ func (d *Document) parseSymbolDict(segment *Segment) Result {
 	var inputSymbols []*Image
 	if segment.ReferredToSegmentCount > 0 {
 		for _, refNum := range segment.ReferredToSegmentNumbers {
-			seg := d.FindSegmentByNumber(refNum)
+			seg := d.findSegment(refNum)
 			if seg == nil {
 				return ResultFailure
 			}
@@ -426,7 +572,7 @@ func (d *Document) parseSymbolDict(segment *Segment) Result {
 		}
 		tableSegments := make([]*Segment, 0)
 		for _, refNum := range segment.ReferredToSegmentNumbers {
-			seg := d.FindSegmentByNumber(refNum)
+			seg := d.findSegment(refNum)
 			if seg != nil && seg.Flags.Type == 53 {
 				tableSegments = append(tableSegments, seg)
 			}
@@ -487,34 +633,43 @@ func (d *Document) parseSymbolDict(segment *Segment) Result {
 		} else {
 			gbContextSize = 8192
 		}
-		if sdd.SDREFAGG {
-			if sdd.SDRTEMPLATE {
-				grContextSize = 1024
-			} else {
-				grContextSize = 8192
-			}
+	}
+	if sdd.SDREFAGG {
+		if sdd.SDRTEMPLATE {
+			grContextSize = 1024
+		} else {
+			grContextSize = 8192
 		}
 	}
 	var gbContexts, grContexts []ArithCtx
-	retainContexts := (flags & 0x0100) != 0
-	if retainContexts && len(segment.ReferredToSegmentNumbers) > 0 {
-		refSeg := d.FindSegmentByNumber(segment.ReferredToSegmentNumbers[0])
-		if refSeg != nil {
-			if len(refSeg.GBContexts) == gbContextSize {
-				gbContexts = make([]ArithCtx, gbContextSize)
-				copy(gbContexts, refSeg.GBContexts)
-			}
-			if len(refSeg.GRContexts) == grContextSize {
-				grContexts = make([]ArithCtx, grContextSize)
-				copy(grContexts, refSeg.GRContexts)
+	// 通用区域上下文只在算术编码时存在, 细化上下文只在细化聚合时存在, 两者大小为0时不继承也不保留
+	// 霍夫曼编码且细化聚合的字典仍需继承与保留细化上下文
+	contextUsed := (flags&0x0100) != 0 && (!sdd.SDHUFF || sdd.SDREFAGG)
+	contextRetained := (flags&0x0200) != 0 && (!sdd.SDHUFF || sdd.SDREFAGG)
+	if contextUsed {
+		var lastDict *SymbolDict
+		for _, refNum := range segment.ReferredToSegmentNumbers {
+			seg := d.findSegment(refNum)
+			if seg != nil && seg.Flags.Type == 0 && seg.SymbolDict != nil {
+				lastDict = seg.SymbolDict
 			}
 		}
+		if lastDict == nil {
+			return ResultFailure
+		}
+		if len(lastDict.GbContexts()) != gbContextSize || len(lastDict.GrContexts()) != grContextSize {
+			return ResultFailure
+		}
+		gbContexts = getContexts(gbContextSize)
+		copy(gbContexts, lastDict.GbContexts())
+		grContexts = getContexts(grContextSize)
+		copy(grContexts, lastDict.GrContexts())
 	}
 	if gbContexts == nil {
-		gbContexts = make([]ArithCtx, gbContextSize)
+		gbContexts = getContexts(gbContextSize)
 	}
 	if grContexts == nil {
-		grContexts = make([]ArithCtx, grContextSize)
+		grContexts = getContexts(grContextSize)
 	}
 	var err error
 	if sdd.SDHUFF {
@@ -527,8 +682,17 @@ func (d *Document) parseSymbolDict(segment *Segment) Result {
 		d.stream.AddOffset(2)
 	}
 	if err != nil {
+		putContexts(gbContexts)
+		putContexts(grContexts)
 		return ResultFailure
 	}
+	if contextRetained {
+		segment.SymbolDict.SetGbContexts(gbContexts)
+		segment.SymbolDict.SetGrContexts(grContexts)
+	} else {
+		putContexts(gbContexts)
+		putContexts(grContexts)
+	}
 	segment.ResultType = JBig2SymbolDictPointer
 	return ResultSuccess
 }
@@ -562,9 +726,94 @@ func (d *Document) ParseRegionInfo(ri *RegionInfo) Result {
 	} else {
 		ri.Flags = val
 	}
+	if ri.Flags&kColourExtFlag != 0 {
+		if val, err := d.stream.ReadShortInteger(); err != nil {
+			return ResultFailure
+		} else {
+			ri.Colour = val
+		}
+	}
 	return ResultSuccess
 }
 
+// currentPageInfo 获取当前页面信息
+// 返回: *PageInfo 页面信息
+func (d *Document) currentPageInfo() *PageInfo {
+	if len(d.pageInfoList) == 0 {
+		return nil
+	}
+	return d.pageInfoList[len(d.pageInfoList)-1]
+}
+
+// regionComposeOp 获取区域的外部组合操作
+// 入参: ri 区域信息
+// 返回: ComposeOp 组合操作
+func (d *Document) regionComposeOp(ri *RegionInfo) ComposeOp {
+	if pi := d.currentPageInfo(); pi != nil && !pi.ComposeOpOverridden {
+		return pi.DefaultComposeOp
+	}
+	if (ri.Flags & 0x07) == 4 {
+		return ComposeReplace
+	}
+	return ComposeOp(ri.Flags & 0x03)
+}
+
+// composeRegion 将区域图像组合到页面
+// 组合完成后区域图像的缓冲区归还到池中, 调用方不得再使用img
+// 入参: ri 区域信息, x 轴坐标, y 轴坐标, img 区域图像
+func (d *Document) composeRegion(ri *RegionInfo, x, y int32, img *Image) {
+	if d.deferCompose {
+		d.pendingCompose = append(d.pendingCompose, pendingCompose{ri: *ri, x: x, y: y, img: img})
+		return
+	}
+	defer img.release()
+	if (d.page == nil && d.tiledPage == nil) || img == nil {
+		return
+	}
+	pi := d.currentPageInfo()
+	growable := pi != nil && (d.rowSink != nil || (!d.bufSpecified && pi.IsStriped))
+	bottom := uint32(ri.Y) + uint32(ri.Height)
+	if growable && !d.bufSpecified && bottom > d.pageHeight {
+		d.pageHeight = bottom
+	}
+	if ri.Flags&kColourExtFlag != 0 {
+		d.colourUsed = true
+	}
+	if d.tiledPage != nil {
+		d.tiledPage.Expand(d.pageHeight)
+		d.tiledPage.ComposeFrom(int64(x), int64(y), img, d.regionComposeOp(ri))
+		return
+	}
+	if growable {
+		need := int64(min(bottom, d.pageHeight))
+		if d.pageClipped {
+			need = min(need, int64(d.viewport.Max.Y))
+		}
+		need -= int64(d.pageOrigin.Y)
+		if need > int64(d.page.Height()) {
+			d.page.Expand(int32(need), pi.DefaultPixelValue)
+		}
+	}
+	if d.pageClipped {
+		rect := image.Rect(int(x), int(y), int(x)+int(img.Width()), int(y)+int(img.Height()))
+		if !rect.Overlaps(*d.viewport) {
+			return
+		}
+	}
+	x -= int32(d.pageOrigin.X)
+	y -= int32(d.pageOrigin.Y)
+	d.page.ComposeFrom(x, y, img, d.regionComposeOp(ri))
+	if d.colourUsed {
+		d.paintColour(ri, x, y, img)
+	}
+}
+
+// GetPageInfoList 获取页面信息列表
+// 返回: []*PageInfo 页面信息列表
+func (d *Document) GetPageInfoList() []*PageInfo {
+	return d.pageInfoList
+}
+
 // GetHuffmanTable 获取霍夫曼表
 // 入参: idx 索引
 // 返回: *HuffmanTable 霍夫曼表
@@ -588,38 +837,14 @@ func (d *Document) DecodeSymbolIDHuffmanTable(SBNUMSYMS uint32) []HuffmanCode {
 	if err := HuffmanAssignCode(huffmanCodes); err != nil {
 		return nil
 	}
+	runCodes := compileHuffmanCodes(huffmanCodes)
 	SBSYMCODES := make([]HuffmanCode, SBNUMSYMS)
 	i := int32(0)
-	loopSyms := 0
 	for i < int32(SBNUMSYMS) {
-		loopSyms++
-		if loopSyms > int(SBNUMSYMS)*10 {
+		j, err := runCodes.decode(d.stream)
+		if err != nil {
 			return nil
 		}
-		var j int
-		var nSafeVal int32
-		nBits := 0
-		loopInner := 0
-		for {
-			loopInner++
-			if loopInner > 1000 {
-				return nil
-			}
-			bit, err := d.stream.Read1Bit()
-			if err != nil {
-				return nil
-			}
-			nSafeVal = (nSafeVal << 1) | int32(bit)
-			nBits++
-			for j = 0; j < kRunCodesSize; j++ {
-				if int32(nBits) == huffmanCodes[j].Codelen && nSafeVal == huffmanCodes[j].Code {
-					break
-				}
-			}
-			if j < kRunCodesSize {
-				break
-			}
-		}
 		runcode := int32(j)
 		var run int32
 		if runcode < 32 {
@@ -674,6 +899,7 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 	if d.ParseRegionInfo(&ri) != ResultSuccess {
 		return ResultFailure
 	}
+	segment.RegionInfo = ri
 	var flags uint16
 	if val, err := d.stream.ReadShortInteger(); err != nil {
 		return ResultFailure
@@ -720,14 +946,14 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 	}
 	if segment.ReferredToSegmentCount > 0 {
 		for _, refNum := range segment.ReferredToSegmentNumbers {
-			if d.FindSegmentByNumber(refNum) == nil {
+			if d.findSegment(refNum) == nil {
 				return ResultFailure
 			}
 		}
 	}
 	dwNumSyms := uint32(0)
 	for _, refNum := range segment.ReferredToSegmentNumbers {
-		seg := d.FindSegmentByNumber(refNum)
+		seg := d.findSegment(refNum)
 		if seg != nil && seg.Flags.Type == 0 && seg.SymbolDict != nil {
 			dwNumSyms += uint32(seg.SymbolDict.NumImages())
 		}
@@ -736,7 +962,7 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 	SBSYMS := make([]*Image, pTRD.SBNUMSYMS)
 	dwNumSyms = 0
 	for _, refNum := range segment.ReferredToSegmentNumbers {
-		seg := d.FindSegmentByNumber(refNum)
+		seg := d.findSegment(refNum)
 		if seg != nil && seg.Flags.Type == 0 && seg.SymbolDict != nil {
 			dict := seg.SymbolDict
 			for j := 0; j < dict.NumImages(); j++ {
@@ -782,7 +1008,7 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 		tableIdx := 0
 		tableSegments := make([]*Segment, 0)
 		for _, refNum := range segment.ReferredToSegmentNumbers {
-			seg := d.FindSegmentByNumber(refNum)
+			seg := d.findSegment(refNum)
 			if seg != nil && seg.Flags.Type == 53 {
 				tableSegments = append(tableSegments, seg)
 			}
@@ -854,19 +1080,14 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 			pTRD.SBHUFFRSIZE = getUserTable()
 		}
 	}
-	getComposeOp := func(ri *RegionInfo) ComposeOp {
-		if (ri.Flags & 0x07) == 4 {
-			return ComposeReplace
-		}
-		return ComposeOp(ri.Flags & 0x03)
-	}
 	grContexts := make([]ArithCtx, 0)
 	if pTRD.SBREFINE {
 		size := 8192
 		if pTRD.SBRTEMPLATE {
 			size = 1024
 		}
-		grContexts = make([]ArithCtx, size)
+		grContexts = getContexts(size)
+		defer putContexts(grContexts)
 	}
 	segment.ResultType = JBig2ImagePointer
 	var err error
@@ -880,7 +1101,9 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 	} else {
 		arithDecoder := NewArithDecoder(d.stream)
 		var img *Image
-		img, err = pTRD.DecodeArith(arithDecoder, grContexts, nil)
+		ids := getIntDecoderState(pTRD.SBSYMCODELEN)
+		img, err = pTRD.DecodeArith(arithDecoder, grContexts, ids)
+		putIntDecoderState(ids)
 		if err == nil {
 			segment.Image = img
 			d.stream.AlignByte()
@@ -891,18 +1114,7 @@ func (d *Document) parseTextRegion(segment *Segment) Result {
 		return ResultFailure
 	}
 	if segment.Flags.Type != 4 {
-		if !d.bufSpecified {
-			if len(d.pageInfoList) > 0 {
-				pi := d.pageInfoList[len(d.pageInfoList)-1]
-				if pi.IsStriped {
-					newHeight := uint32(ri.Y) + uint32(ri.Height)
-					if newHeight > uint32(d.page.Height()) {
-						d.page.Expand(int32(newHeight), pi.DefaultPixelValue)
-					}
-				}
-			}
-		}
-		d.page.ComposeFrom(ri.X, ri.Y, segment.Image, getComposeOp(&ri))
+		d.composeRegion(&ri, ri.X, ri.Y, segment.Image)
 		segment.Image = nil
 	}
 	return ResultSuccess
@@ -954,9 +1166,10 @@ func (d *Document) parsePatternDict(segment *Segment) Result {
 		} else if pPDD.HDTEMPLATE == 1 {
 			size = 8192
 		}
-		gbContexts := make([]ArithCtx, size)
+		gbContexts := getContexts(size)
 		arithDecoder := NewArithDecoder(d.stream)
 		segment.PatternDict, err = pPDD.DecodeArith(arithDecoder, gbContexts)
+		putContexts(gbContexts)
 		if err != nil {
 			return ResultFailure
 		}
@@ -976,6 +1189,7 @@ func (d *Document) parseHalftoneRegion(segment *Segment) Result {
 	if d.ParseRegionInfo(&ri) != ResultSuccess {
 		return ResultFailure
 	}
+	segment.RegionInfo = ri
 	if val, err := d.stream.Read1Byte(); err != nil {
 		return ResultFailure
 	} else {
@@ -1021,7 +1235,7 @@ func (d *Document) parseHalftoneRegion(segment *Segment) Result {
 	if segment.ReferredToSegmentCount != 1 {
 		return ResultFailure
 	}
-	seg := d.FindSegmentByNumber(segment.ReferredToSegmentNumbers[0])
+	seg := d.findSegment(segment.ReferredToSegmentNumbers[0])
 	if seg == nil || seg.Flags.Type != 16 || seg.PatternDict == nil {
 		return ResultFailure
 	}
@@ -1044,9 +1258,10 @@ func (d *Document) parseHalftoneRegion(segment *Segment) Result {
 		d.stream.AlignByte()
 	} else {
 		size := GetHuffContextSize(pHRD.HTEMPLATE)
-		gbContexts := make([]ArithCtx, size)
+		gbContexts := getContexts(size)
 		arithDecoder := NewArithDecoder(d.stream)
 		segment.Image, err = pHRD.DecodeArith(arithDecoder, gbContexts)
+		putContexts(gbContexts)
 		if err != nil {
 			return ResultFailure
 		}
@@ -1054,22 +1269,7 @@ func (d *Document) parseHalftoneRegion(segment *Segment) Result {
 		d.stream.AddOffset(2)
 	}
 	if segment.Flags.Type != 20 {
-		if !d.bufSpecified {
-			if len(d.pageInfoList) > 0 {
-				pi := d.pageInfoList[len(d.pageInfoList)-1]
-				if pi.IsStriped {
-					newHeight := uint32(ri.Y) + uint32(ri.Height)
-					if newHeight > uint32(d.page.Height()) {
-						d.page.Expand(int32(newHeight), pi.DefaultPixelValue)
-					}
-				}
-			}
-		}
-		op := ComposeOp(ri.Flags & 0x03)
-		if (ri.Flags & 0x07) == 4 {
-			op = ComposeReplace
-		}
-		d.page.ComposeFrom(ri.X, ri.Y, segment.Image, op)
+		d.composeRegion(&ri, ri.X, ri.Y, segment.Image)
 		segment.Image = nil
 	}
 	return ResultSuccess
@@ -1079,13 +1279,44 @@ func (d *Document) parseHalftoneRegion(segment *Segment) Result {
 // 入参: segment 段对象
 // 返回: Result 解析结果
 func (d *Document) parseGenericRegion(segment *Segment) Result {
+	pGRD, ri, ret := d.parseGenericRegionHeader(segment)
+	if ret != ResultSuccess {
+		return ret
+	}
+	if pGRD.MMR {
+		res := pGRD.StartDecodeMMR(&segment.Image, d.stream)
+		if res != JBig2SegmentParseComplete {
+			return ResultFailure
+		}
+		d.stream.AlignByte()
+	} else {
+		gbContexts := getContexts(pGRD.ContextSize())
+		arithDecoder := NewArithDecoder(d.stream)
+		var err error
+		segment.Image, err = pGRD.DecodeArith(arithDecoder, gbContexts)
+		putContexts(gbContexts)
+		if err != nil {
+			return ResultFailure
+		}
+		d.stream.AlignByte()
+		d.stream.AddOffset(2)
+	}
+	d.composeGenericRegion(segment, &ri)
+	return ResultSuccess
+}
+
+// parseGenericRegionHeader 解析通用区域段的区域信息与解码参数
+// 入参: segment 段对象
+// 返回: *GRDProc 解码过程, RegionInfo 区域信息, Result 解析结果
+func (d *Document) parseGenericRegionHeader(segment *Segment) (*GRDProc, RegionInfo, Result) {
 	var ri RegionInfo
 	var flags byte
 	if d.ParseRegionInfo(&ri) != ResultSuccess {
-		return ResultFailure
+		return nil, ri, ResultFailure
 	}
+	segment.RegionInfo = ri
 	if val, err := d.stream.Read1Byte(); err != nil {
-		return ResultFailure
+		return nil, ri, ResultFailure
 	} else {
 		flags = val
 	}
@@ -1095,19 +1326,29 @@ func (d *Document) parseGenericRegion(segment *Segment) Result {
 	pGRD.MMR = (flags & 0x01) != 0
 	pGRD.GBTEMPLATE = (flags >> 1) & 0x03
 	pGRD.TPGDON = ((flags >> 3) & 0x01) != 0
+	pGRD.EXTTEMPLATE = !pGRD.MMR && pGRD.GBTEMPLATE == 0 && ((flags>>4)&0x01) != 0
 	if !pGRD.MMR {
 		if pGRD.GBTEMPLATE == 0 {
 			for i := 0; i < 8; i++ {
 				if val, err := d.stream.Read1Byte(); err != nil {
-					return ResultFailure
+					return nil, ri, ResultFailure
 				} else {
 					pGRD.GBAT[i] = int8(val)
 				}
 			}
+			if pGRD.EXTTEMPLATE {
+				for i := 0; i < 16; i++ {
+					if val, err := d.stream.Read1Byte(); err != nil {
+						return nil, ri, ResultFailure
+					} else {
+						pGRD.GBATEXT[i] = int8(val)
+					}
+				}
+			}
 		} else {
 			for i := 0; i < 2; i++ {
 				if val, err := d.stream.Read1Byte(); err != nil {
-					return ResultFailure
+					return nil, ri, ResultFailure
 				} else {
 					pGRD.GBAT[i] = int8(val)
 				}
@@ -1116,45 +1357,17 @@ func (d *Document) parseGenericRegion(segment *Segment) Result {
 	}
 	pGRD.USESKIP = false
 	segment.ResultType = JBig2ImagePointer
-	if pGRD.MMR {
-		res := pGRD.StartDecodeMMR(&segment.Image, d.stream)
-		if res != JBig2SegmentParseComplete {
-			return ResultFailure
-		}
-		d.stream.AlignByte()
-	} else {
-		size := GetHuffContextSize(pGRD.GBTEMPLATE)
-		gbContexts := make([]ArithCtx, size)
-		arithDecoder := NewArithDecoder(d.stream)
-		var err error
-		segment.Image, err = pGRD.DecodeArith(arithDecoder, gbContexts)
-		if err != nil {
-			return ResultFailure
-		}
-		d.stream.AlignByte()
-		d.stream.AddOffset(2)
-	}
+	return pGRD, ri, ResultSuccess
+}
+
+// composeGenericRegion 将解码完成的直接通用区域合成到页面
+// 中间区域的图像保留在段中供后续引用
+// 入参: segment 段对象, ri 区域信息
+func (d *Document) composeGenericRegion(segment *Segment, ri *RegionInfo) {
 	if segment.Flags.Type != 36 {
-		if !d.bufSpecified {
-			if len(d.pageInfoList) > 0 {
-				pi := d.pageInfoList[len(d.pageInfoList)-1]
-				if pi.IsStriped {
-					newHeight := uint32(ri.Y) + uint32(ri.Height)
-					if newHeight > uint32(d.page.Height()) {
-						d.page.Expand(int32(newHeight), pi.DefaultPixelValue)
-					}
-				}
-			}
-		}
-		op := ComposeOp(ri.Flags & 0x03)
-		if (ri.Flags & 0x07) == 4 {
-			op = ComposeReplace
-		}
-		rect := pGRD.GetReplaceRect()
-		d.page.ComposeFrom(ri.X+rect.Left, ri.Y+rect.Top, segment.Image, op)
+		d.composeRegion(ri, ri.X, ri.Y, segment.Image)
 		segment.Image = nil
 	}
-	return ResultSuccess
 }
 
 // GetHuffContextSize 获取上下文大小
@@ -1178,6 +1391,7 @@ func (d *Document) parseGenericRefinementRegion(segment *Segment) Result {
 	if d.ParseRegionInfo(&ri) != ResultSuccess {
 		return ResultFailure
 	}
+	segment.RegionInfo = ri
 	if val, err := d.stream.Read1Byte(); err != nil {
 		return ResultFailure
 	} else {
@@ -1197,64 +1411,81 @@ func (d *Document) parseGenericRefinementRegion(segment *Segment) Result {
 			}
 		}
 	}
-	var pageSubImage *Image
+	var refSeg *Segment
 	if segment.ReferredToSegmentCount > 0 {
-		var pSeg *Segment
 		for _, refNum := range segment.ReferredToSegmentNumbers {
-			pSeg = d.FindSegmentByNumber(refNum)
+			pSeg := d.findSegment(refNum)
 			if pSeg == nil {
 				return ResultFailure
 			}
-			if pSeg.Flags.Type == 4 || pSeg.Flags.Type == 20 || pSeg.Flags.Type == 36 || pSeg.Flags.Type == 40 {
-				break
+			if isIntermediateRegion(pSeg.Flags.Type) {
+				if refSeg != nil {
+					return ResultFailure
+				}
+				refSeg = pSeg
 			}
 		}
-		if pSeg != nil && pSeg.Image != nil {
-			pGRRD.GRREFERENCE = pSeg.Image
-		} else {
+		if refSeg == nil || refSeg.Image == nil {
 			return ResultFailure
 		}
+		pGRRD.GRREFERENCE = refSeg.Image
+		pGRRD.GRREFERENCEDX = refSeg.RegionInfo.X - ri.X
+		pGRRD.GRREFERENCEDY = refSeg.RegionInfo.Y - ri.Y
 	} else {
-		pageSubImage = d.page.SubImage(ri.X, ri.Y, ri.Width, ri.Height)
-		pGRRD.GRREFERENCE = pageSubImage
+		if d.tiledPage != nil {
+			pGRRD.GRREFERENCE = d.tiledPage.SubImage(ri.X, ri.Y, ri.Width, ri.Height)
+		} else if d.page == nil {
+			return ResultFailure
+		} else if d.rowSink != nil && ri.Y < int32(d.pageOrigin.Y) {
+			d.rowErr = ErrRefinedRowsFlushed
+			return ResultFailure
+		} else {
+			pGRRD.GRREFERENCE = d.page.SubImage(ri.X-int32(d.pageOrigin.X), ri.Y-int32(d.pageOrigin.Y), ri.Width, ri.Height)
+		}
+		if pGRRD.GRREFERENCE == nil {
+			return ResultFailure
+		}
+		pGRRD.GRREFERENCEDX = 0
+		pGRRD.GRREFERENCEDY = 0
 	}
-	pGRRD.GRREFERENCEDX = 0
-	pGRRD.GRREFERENCEDY = 0
 	size := 8192
 	if pGRRD.GRTEMPLATE {
 		size = 1024
 	}
-	grContexts := make([]ArithCtx, size)
+	grContexts := getContexts(size)
 	arithDecoder := NewArithDecoder(d.stream)
 	segment.ResultType = JBig2ImagePointer
 	var err error
 	segment.Image, err = pGRRD.Decode(arithDecoder, grContexts)
+	putContexts(grContexts)
 	if err != nil {
 		return ResultFailure
 	}
 	d.stream.AlignByte()
 	d.stream.AddOffset(2)
-	if segment.Flags.Type != 40 {
-		if !d.bufSpecified {
-			if len(d.pageInfoList) > 0 {
-				pi := d.pageInfoList[len(d.pageInfoList)-1]
-				if pi.IsStriped {
-					newHeight := uint32(ri.Y) + uint32(ri.Height)
-					if newHeight > uint32(d.page.Height()) {
-						d.page.Expand(int32(newHeight), pi.DefaultPixelValue)
-					}
-				}
-			}
+	if refSeg != nil {
+		// 全局段可能被其他页面或解码器再次引用, 只释放本地段
+		if d.findLocalSegment(refSeg.Number) == refSeg {
+			refSeg.Image.release()
+			refSeg.Image = nil
 		}
-		op := ComposeOp(ri.Flags & 0x03)
-		if (ri.Flags & 0x07) == 4 {
-			op = ComposeReplace
-		}
-		d.page.ComposeFrom(ri.X, ri.Y, segment.Image, op)
+	} else {
+		pGRRD.GRREFERENCE.release()
+	}
+	if segment.Flags.Type != 40 {
+		d.composeRegion(&ri, ri.X, ri.Y, segment.Image)
+		segment.Image = nil
 	}
 	return ResultSuccess
 }
 
+// isIntermediateRegion 是否为中间区域段
+// 入参: segType 段类型
+// 返回: bool 是否为中间区域段
+func isIntermediateRegion(segType uint8) bool {
+	return segType == 4 || segType == 20 || segType == 36 || segType == 40
+}
+
 // parsePageInfo 解析页面信息段
 // 入参: segment 段对象
 // 返回: Result 解析结果
@@ -1292,18 +1523,50 @@ func (d *Document) parsePageInfo(segment *Segment) Result {
 	} else {
 		striping = val
 	}
-	pi.DefaultPixelValue = (flags & 4) != 0
+	pi.EventuallyLossless = (flags & 0x01) != 0
+	pi.MightContainRefinement = (flags & 0x02) != 0
+	pi.DefaultPixelValue = (flags & 0x04) != 0
+	pi.DefaultComposeOp = ComposeOp((flags >> 3) & 0x03)
+	pi.RequiresAuxBuffers = (flags & 0x20) != 0
+	pi.ComposeOpOverridden = (flags & 0x40) != 0
 	pi.IsStriped = (striping & 0x8000) != 0
 	pi.MaxStripeSize = striping & 0x7FFF
 	height := pi.Height
 	if height == 0xFFFFFFFF {
 		height = uint32(pi.MaxStripeSize)
 	}
-	d.page = NewImage(int32(pi.Width), int32(height))
-	if d.page == nil {
-		return ResultFailure
+	d.page.release()
+	d.page = nil
+	d.tiledPage = nil
+	d.pageHeight = height
+	d.pageOrigin = image.Point{}
+	d.pageClipped = d.viewport != nil && !pi.MightContainRefinement
+	bufWidth, bufHeight := int32(pi.Width), int32(height)
+	if d.pageClipped {
+		d.pageOrigin = image.Pt(max(d.viewport.Min.X, 0), max(d.viewport.Min.Y, 0))
+		bufWidth, bufHeight = d.clipPageExtent(pi.Width, height)
+	} else if d.rowSink != nil {
+		if pi.IsStriped && pi.MaxStripeSize > 0 {
+			bufHeight = int32(min(height, uint32(pi.MaxStripeSize)))
+		}
+	} else if d.tileSize > 0 || !fitsImage(pi.Width, height) {
+		if pi.Width == 0 || height == 0 {
+			return ResultFailure
+		}
+		d.tiledPage = NewTiledImage(pi.Width, height, d.tileSize, pi.DefaultPixelValue)
 	}
-	d.page.Fill(pi.DefaultPixelValue)
+	if d.tiledPage == nil {
+		d.page = NewImage(bufWidth, bufHeight)
+		if d.page == nil {
+			return ResultFailure
+		}
+		d.page.Fill(pi.DefaultPixelValue)
+	}
+	d.bufSpecified = pi.Height != 0xFFFFFFFF
+	d.colourPlane = nil
+	d.colourRect = image.Rectangle{}
+	d.colourUsed = false
+	d.pageComments = nil
 	d.pageInfoList = append(d.pageInfoList, pi)
 	d.inPage = true
 	return ResultSuccess
@@ -1326,16 +1589,17 @@ func (d *Document) parseTable(segment *Segment) Result {
 // ReleasePageSegments 释放页面段数据
 // 入参: pageNumber 页面编号
 func (d *Document) ReleasePageSegments(pageNumber uint32) {
+	for _, seg := range d.deferredRelease {
+		d.releaseSegment(seg)
+	}
+	d.deferredRelease = nil
 	n := 0
 	for _, seg := range d.segmentList {
 		if seg.PageAssociation != pageNumber {
 			d.segmentList[n] = seg
 			n++
 		} else {
-			seg.Image = nil
-			seg.PatternDict = nil
-			seg.SymbolDict = nil
-			seg.HuffmanTable = nil
+			seg.releaseData()
 		}
 	}
 	for i := n; i < len(d.segmentList); i++ {
@@ -1343,3 +1607,52 @@ func (d *Document) ReleasePageSegments(pageNumber uint32) {
 	}
 	d.segmentList = d.segmentList[:n]
 }
+
+// applyRetention 根据段保留标志释放不再需要的被引用段
+// 保留标志见T.88 7.2.4: 被引用段对应的保留位为0时释放该段; 部分编码器把保留位全部写为0,
+// 因此只有本段保留位(第0位)为1、表明其保留位有意义的被引用段才会被释放
+// 延迟不保留标志见T.88 7.2.3段头标志第7位, 标准未规定释放时机, 本实现将这类段推迟到当前页面结束时释放,
+// 使同一页面中之后的段仍可引用
+// 入参: segment 已处理完成的段
+func (d *Document) applyRetention(segment *Segment) {
+	for i, refNum := range segment.ReferredToSegmentNumbers {
+		if i >= len(segment.ReferredRetain) || segment.ReferredRetain[i] {
+			continue
+		}
+		refSeg := d.findLocalSegment(refNum)
+		if refSeg == nil || !refSeg.Retain {
+			continue
+		}
+		if refSeg.Flags.DeferredNonRetain {
+			d.deferredRelease = append(d.deferredRelease, refSeg)
+			continue
+		}
+		d.releaseSegment(refSeg)
+	}
+}
+
+// findLocalSegment 在当前文档中查找段, 不查找全局上下文
+// 入参: number 段编号
+// 返回: *Segment 段对象
+func (d *Document) findLocalSegment(number uint32) *Segment {
+	for _, seg := range d.segmentList {
+		if seg.Number == number {
+			return seg
+		}
+	}
+	return nil
+}
+
+// releaseSegment 释放段数据并从段列表中移除
+// 入参: segment 段对象
+func (d *Document) releaseSegment(segment *Segment) {
+	segment.releaseData()
+	for i, seg := range d.segmentList {
+		if seg == segment {
+			copy(d.segmentList[i:], d.segmentList[i+1:])
+			d.segmentList[len(d.segmentList)-1] = nil
+			d.segmentList = d.segmentList[:len(d.segmentList)-1]
+			return
+		}
+	}
+}
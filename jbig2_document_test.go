@@ -0,0 +1,224 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDefaultComposeOp(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a := randomImage(r, 48, 32, 12)
+	b := randomImage(r, 48, 32, 12)
+	tests := []struct {
+		name  string
+		flags byte
+		op    ComposeOp
+	}{
+		{"default", byte(ComposeXor) << 3, ComposeXor},
+		{"overridden", byte(ComposeXor)<<3 | 0x40, ComposeOr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStream(1)
+			s.segment(48, 1, nil, testPageInfo(64, 40, tt.flags))
+			s.segment(38, 1, nil, testGenericRegion(a, 0, 0, ComposeOr, 0, false))
+			s.segment(38, 1, nil, testGenericRegion(b, 16, 8, ComposeOr, 0, false))
+			want := NewImage(64, 40)
+			want.Fill(false)
+			a.ComposeTo(want, 0, 0, ComposeOr)
+			b.ComposeTo(want, 16, 8, tt.op)
+			got, err := Decode(bytes.NewReader(s.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pageEqual(got, want) {
+				t.Errorf("page not composed with %v", tt.op)
+			}
+		})
+	}
+}
+
+func TestRefinementKeepsGlobalReference(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	ref := randomImage(r, 40, 24, 10)
+	// 全局段数据中不能出现区域段, 直接构造全局上下文中的中间区域
+	global := NewSegment()
+	global.Flags.Type = 36
+	global.Image = ref.Duplicate()
+	global.RegionInfo = RegionInfo{Width: 40, Height: 24}
+	globals := &Globals{doc: newGlobalDocument(nil)}
+	globals.doc.segmentList = append(globals.doc.segmentList, global)
+	s := newTestStream(2)
+	s.num = 1
+	var want []*Image
+	for page := uint32(1); page <= 2; page++ {
+		img := ref.Duplicate()
+		for i := 0; i < 10; i++ {
+			img.SetPixel(r.Int31n(40), r.Int31n(24), r.Intn(2))
+		}
+		want = append(want, img)
+		s.segment(48, page, nil, testPageInfo(40, 24, 0))
+		s.segment(42, page, []uint32{0}, testRefinementRegion(img, ref, 0, 0, false, false))
+		s.segment(49, page, nil, nil)
+	}
+	dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(s.out), globals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range want {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		if !pageEqual(got, w) {
+			t.Errorf("page %d differs", i+1)
+		}
+	}
+	if !imageEqual(global.Image, ref) {
+		t.Error("global reference modified")
+	}
+}
+
+func TestSymbolDictTemplates(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for template := 0; template < 4; template++ {
+		syms := []*Image{randomImage(r, 8, 10, 3), randomImage(r, 5, 10, 3), randomImage(r, 9, 6, 3)}
+		data, order := testSymbolDict(syms, template)
+		want := NewImage(40, 20)
+		want.Fill(false)
+		var ps []testPlacement
+		for i, sym := range order {
+			p := testPlacement{id: uint32(i), x: int32(2 + 12*i), y: int32(1 + i)}
+			ps = append(ps, p)
+			sym.ComposeTo(want, p.x, p.y, ComposeOr)
+		}
+		s := newTestStream(1)
+		dict := s.segment(0, 1, nil, data)
+		s.segment(48, 1, nil, testPageInfo(40, 20, 0))
+		s.segment(6, 1, []uint32{dict}, testTextRegion(40, 20, len(order), ps))
+		got, err := Decode(bytes.NewReader(s.out))
+		if err != nil {
+			t.Fatalf("template %d: %v", template, err)
+		}
+		if !pageEqual(got, want) {
+			t.Errorf("template %d: symbols differ", template)
+		}
+	}
+}
+
+func TestHuffmanRefAggContexts(t *testing.T) {
+	r := rand.New(rand.NewSource(28))
+	base := []*Image{randomImage(r, 12, 14, 4), randomImage(r, 10, 14, 4)}
+	baseData, inputs := testSymbolDict(base, 1)
+	// 新符号由输入符号翻转少量像素得到, 两个字典的细化上下文连续编码
+	refine := func(ref *Image) *Image {
+		img := NewImage(12, 14)
+		img.Fill(false)
+		ref.ComposeTo(img, 0, 0, ComposeOr)
+		for i := 0; i < 6; i++ {
+			img.SetPixel(r.Int31n(12), r.Int31n(14), r.Intn(2))
+		}
+		return img
+	}
+	cx := make([]ArithCtx, 1024)
+	first := []*Image{refine(inputs[0]), refine(inputs[1])}
+	firstData := testHuffmanRefAggDict(inputs, first, []int{0, 1}, 0x0200, cx)
+	exported := append(append([]*Image(nil), inputs...), first...)
+	second := []*Image{refine(exported[2]), refine(exported[0]), refine(exported[3])}
+	secondData := testHuffmanRefAggDict(exported, second, []int{2, 0, 3}, 0x0300, cx)
+	all := append(append([]*Image(nil), exported...), second...)
+	want := NewImage(120, 20)
+	want.Fill(false)
+	var ps []testPlacement
+	for i, sym := range all {
+		p := testPlacement{id: uint32(i), x: int32(2 + 16*i), y: 2}
+		ps = append(ps, p)
+		sym.ComposeTo(want, p.x, p.y, ComposeOr)
+	}
+	s := newTestStream(1)
+	d0 := s.segment(0, 1, nil, baseData)
+	d1 := s.segment(0, 1, []uint32{d0}, firstData)
+	d2 := s.segment(0, 1, []uint32{d1}, secondData)
+	s.segment(48, 1, nil, testPageInfo(120, 20, 0))
+	s.segment(6, 1, []uint32{d2}, testTextRegion(120, 20, len(all), ps))
+	got, err := Decode(bytes.NewReader(s.out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pageEqual(got, want) {
+		t.Error("symbols refined with inherited contexts differ")
+	}
+}
+
+func TestSegmentRetention(t *testing.T) {
+	r := rand.New(rand.NewSource(29))
+	var syms []*Image
+	var dicts [][]byte
+	for i := 0; i < 3; i++ {
+		data, order := testSymbolDict([]*Image{randomImage(r, 10, 12, 4)}, 1)
+		dicts = append(dicts, data)
+		syms = append(syms, order[0])
+	}
+	s := newTestStream(2)
+	// 保留的字典、本段未置保留位的字典与延迟释放的字典
+	kept := s.segmentRetain(0, 0, nil, 0x01, dicts[0])
+	plain := s.segmentRetain(0, 0, nil, 0x00, dicts[1])
+	deferred := s.segmentRetain(0x80, 0, nil, 0x01, dicts[2])
+	all := []testPlacement{{0, 2, 2}, {1, 16, 2}, {2, 30, 2}}
+	s.segment(48, 1, nil, testPageInfo(48, 16, 0))
+	// 只保留第一个字典, 释放其余两个
+	s.segmentRetain(6, 1, []uint32{kept, plain, deferred}, 0x02, testTextRegion(48, 16, 3, all))
+	// 延迟释放的字典在本页结束前仍可引用
+	s.segment(6, 1, []uint32{deferred}, testTextRegion(48, 16, 1, []testPlacement{{0, 30, 2}}))
+	s.segment(49, 1, nil, nil)
+	s.segment(48, 2, nil, testPageInfo(48, 16, 0))
+	s.segmentRetain(6, 2, []uint32{kept}, 0x00, testTextRegion(48, 16, 1, []testPlacement{{0, 2, 2}}))
+	s.segment(49, 2, nil, nil)
+
+	dec, err := NewDecoder(bytes.NewReader(s.out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewImage(48, 16)
+	want.Fill(false)
+	for i, p := range all {
+		syms[i].ComposeTo(want, p.x, p.y, ComposeOr)
+	}
+	got, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pageEqual(got, want) {
+		t.Error("page 1 differs")
+	}
+	present := func(n uint32) bool { return dec.doc.findLocalSegment(n) != nil }
+	if !present(kept) || !present(plain) || present(deferred) {
+		t.Errorf("after page 1: kept %v, plain %v, deferred %v", present(kept), present(plain), present(deferred))
+	}
+	want.Fill(false)
+	syms[0].ComposeTo(want, 2, 2, ComposeOr)
+	if got, err = dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if !pageEqual(got, want) {
+		t.Error("page 2 differs")
+	}
+	if present(kept) || !present(plain) {
+		t.Errorf("after page 2: kept %v, plain %v", present(kept), present(plain))
+	}
+}
@@ -0,0 +1,667 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"encoding/binary"
+	"image"
+	"math/rand"
+	"sort"
+)
+
+// testEncoder 测试用MQ算术编码器, 按T.88附录E实现
+type testEncoder struct {
+	a, c uint32
+	ct   int
+	out  []byte
+	bp   int
+}
+
+// newTestEncoder 创建测试用算术编码器
+// 返回: *testEncoder 编码器
+func newTestEncoder() *testEncoder {
+	return &testEncoder{a: 0x8000, ct: 12, out: []byte{0}}
+}
+
+// byteOut 输出字节并处理进位与0xFF填充
+func (e *testEncoder) byteOut() {
+	if e.out[e.bp] == 0xFF {
+		e.bp++
+		e.out = append(e.out, byte(e.c>>20))
+		e.c &= 0xFFFFF
+		e.ct = 7
+		return
+	}
+	if e.c >= 0x8000000 {
+		e.out[e.bp]++
+		if e.out[e.bp] == 0xFF {
+			e.c &= 0x7FFFFFF
+			e.bp++
+			e.out = append(e.out, byte(e.c>>20))
+			e.c &= 0xFFFFF
+			e.ct = 7
+			return
+		}
+	}
+	e.bp++
+	e.out = append(e.out, byte(e.c>>19))
+	e.c &= 0x7FFFF
+	e.ct = 8
+}
+
+// renormalize 重归一化
+func (e *testEncoder) renormalize() {
+	for {
+		e.a <<= 1
+		e.c <<= 1
+		e.ct--
+		if e.ct == 0 {
+			e.byteOut()
+		}
+		if e.a&0x8000 != 0 {
+			return
+		}
+	}
+}
+
+// encode 编码一位
+// 入参: cx 上下文, d 位值
+func (e *testEncoder) encode(cx *ArithCtx, d int) {
+	qe := kQeTable[cx.i]
+	q := uint32(qe.Qe)
+	e.a -= q
+	if (d != 0) == cx.mps {
+		if e.a&0x8000 != 0 {
+			e.c += q
+			return
+		}
+		if e.a < q {
+			e.a = q
+		} else {
+			e.c += q
+		}
+		cx.i = qe.NMPS
+	} else {
+		if e.a < q {
+			e.c += q
+		} else {
+			e.a = q
+		}
+		if qe.Switch {
+			cx.mps = !cx.mps
+		}
+		cx.i = qe.NLPS
+	}
+	e.renormalize()
+}
+
+// flush 结束编码并返回以0xFFAC结尾的数据
+// 返回: []byte 编码数据
+func (e *testEncoder) flush() []byte {
+	temp := e.c + e.a
+	e.c |= 0xFFFF
+	if e.c >= temp {
+		e.c -= 0x8000
+	}
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	if e.out[e.bp] != 0xFF {
+		e.out = append(e.out, 0xFF)
+	}
+	e.out = append(e.out, 0xAC)
+	return e.out[1:]
+}
+
+// encodeInt 按T.88附录A.3编码整数
+// 入参: cx 整数编码上下文, v 值, oob 是否编码OOB
+func (e *testEncoder) encodeInt(cx *[512]ArithCtx, v int32, oob bool) {
+	prev := 1
+	bit := func(b int) {
+		e.encode(&cx[prev], b)
+		if prev < 256 {
+			prev = prev<<1 | b
+		} else {
+			prev = (prev<<1|b)&511 | 256
+		}
+	}
+	if oob {
+		for _, b := range []int{1, 0, 0, 0} {
+			bit(b)
+		}
+		return
+	}
+	sign := 0
+	if v < 0 {
+		sign, v = 1, -v
+	}
+	ranges := []struct {
+		low    int32
+		prefix []int
+		bits   int
+	}{
+		{0, []int{0}, 2}, {4, []int{1, 0}, 4}, {20, []int{1, 1, 0}, 6},
+		{84, []int{1, 1, 1, 0}, 8}, {340, []int{1, 1, 1, 1, 0}, 12}, {4436, []int{1, 1, 1, 1, 1}, 32},
+	}
+	r := ranges[0]
+	for _, c := range ranges {
+		if v >= c.low {
+			r = c
+		}
+	}
+	bit(sign)
+	for _, b := range r.prefix {
+		bit(b)
+	}
+	for i := r.bits - 1; i >= 0; i-- {
+		bit(int(uint32(v-r.low)>>uint(i)) & 1)
+	}
+}
+
+// encodeIaid 按T.88附录A.3编码符号编号
+// 入参: cx 上下文, codeLen 编码位数, v 符号编号
+func (e *testEncoder) encodeIaid(cx []ArithCtx, codeLen uint, v uint32) {
+	prev := 1
+	for i := int(codeLen) - 1; i >= 0; i-- {
+		b := int(v>>uint(i)) & 1
+		e.encode(&cx[prev], b)
+		prev = prev<<1 | b
+	}
+}
+
+// kTestNominalAT 各通用区域模板的名义AT像素
+var kTestNominalAT = [4][]int8{{3, -1, -3, -1, 2, -2, -2, -2}, {3, -1}, {2, -1}, {2, -1}}
+
+// kTestTPGDContext 各通用区域模板的典型预测上下文
+var kTestTPGDContext = [4]uint32{0x9b25, 0x0795, 0x00e5, 0x0195}
+
+// pixelRow 从位置x开始向左取n个像素, 首个像素位于最低位
+// 入参: img 图像, x 起始横坐标, y 纵坐标, n 像素数
+// 返回: uint32 像素位
+func pixelRow(img *Image, x, y int32, n int) uint32 {
+	var v uint32
+	for k := 0; k < n; k++ {
+		v |= uint32(img.GetPixel(x-int32(k), y)) << uint(k)
+	}
+	return v
+}
+
+// genericContext 计算名义AT像素下通用区域的上下文
+// 入参: img 已编码部分的图像, template 模板号, w 横坐标, h 纵坐标
+// 返回: uint32 上下文
+func genericContext(img *Image, template int, w, h int32) uint32 {
+	at := kTestNominalAT[template]
+	atPixel := func(i int) uint32 {
+		return uint32(img.GetPixel(w+int32(at[2*i]), h+int32(at[2*i+1])))
+	}
+	if template == 3 {
+		return pixelRow(img, w-1, h, 4) | atPixel(0)<<4 | pixelRow(img, w+1, h-1, 5)<<5
+	}
+	mod2, div2 := int32(template%2), int32(template/2)
+	n1 := [3]int{3, 4, 3}[template]
+	n2 := [3]int{5, 5, 4}[template]
+	n3 := [3]int{4, 3, 2}[template]
+	shift := uint(4 - template)
+	ctx := pixelRow(img, w-1, h, n3)
+	ctx |= atPixel(0) << shift
+	ctx |= pixelRow(img, w+2-div2, h-1, n2) << (shift + 1)
+	ctx |= pixelRow(img, w+1+mod2, h-2, n1) << [3]uint{12, 9, 7}[template]
+	if template == 0 {
+		ctx |= atPixel(1)<<10 | atPixel(2)<<11 | atPixel(3)<<15
+	}
+	return ctx
+}
+
+// encodeGeneric 以名义AT像素算术编码通用区域
+// 入参: cx 上下文, img 图像, template 模板号, tpgdon 是否典型预测
+func (e *testEncoder) encodeGeneric(cx []ArithCtx, img *Image, template int, tpgdon bool) {
+	e.encodeGenericContext(cx, img, kTestTPGDContext[template], tpgdon, func(out *Image, w, h int32) uint32 {
+		return genericContext(out, template, w, h)
+	})
+}
+
+// encodeGenericContext 以给定的上下文计算算术编码通用区域
+// 入参: cx 上下文, img 图像, tpgd 典型预测上下文, tpgdon 是否典型预测, context 由已编码部分计算上下文
+func (e *testEncoder) encodeGenericContext(cx []ArithCtx, img *Image, tpgd uint32, tpgdon bool, context func(out *Image, w, h int32) uint32) {
+	out := NewImage(img.Width(), img.Height())
+	out.Fill(false)
+	ltp := 0
+	for h := int32(0); h < img.Height(); h++ {
+		if tpgdon {
+			sltp := 1
+			for w := int32(0); w < img.Width(); w++ {
+				if img.GetPixel(w, h) != img.GetPixel(w, h-1) {
+					sltp = 0
+					break
+				}
+			}
+			e.encode(&cx[tpgd], sltp^ltp)
+			ltp = sltp
+			if ltp == 1 {
+				out.CopyLine(h, h-1)
+				continue
+			}
+		}
+		for w := int32(0); w < img.Width(); w++ {
+			v := img.GetPixel(w, h)
+			e.encode(&cx[context(out, w, h)], v)
+			out.SetPixel(w, h, v)
+		}
+	}
+}
+
+// refinementContext 计算名义AT像素下细化区域的上下文
+// 入参: out 已编码部分的图像, ref 参考图像, template1 是否模板1, dx dy 参考偏移, w 横坐标, h 纵坐标
+// 返回: uint32 上下文
+func refinementContext(out, ref *Image, template1 bool, dx, dy, w, h int32) uint32 {
+	rx, ry := w-dx, h-dy
+	if template1 {
+		ctx := pixelRow(ref, rx+1, ry+1, 2)
+		ctx |= pixelRow(ref, rx+1, ry, 3) << 2
+		ctx |= uint32(ref.GetPixel(rx, ry-1)) << 5
+		ctx |= uint32(out.GetPixel(w-1, h)) << 6
+		ctx |= pixelRow(out, w+1, h-1, 3) << 7
+		return ctx
+	}
+	ctx := pixelRow(ref, rx+1, ry+1, 3)
+	ctx |= pixelRow(ref, rx+1, ry, 3) << 3
+	ctx |= pixelRow(ref, rx+1, ry-1, 2) << 6
+	ctx |= uint32(ref.GetPixel(rx-1, ry-1)) << 8
+	ctx |= uint32(out.GetPixel(w-1, h)) << 9
+	ctx |= pixelRow(out, w+1, h-1, 2) << 10
+	ctx |= uint32(out.GetPixel(w-1, h-1)) << 12
+	return ctx
+}
+
+// uniformNeighbourhood 参考图像中以(x, y)为中心的3x3邻域是否一致
+// 入参: ref 参考图像, x 横坐标, y 纵坐标
+// 返回: int 一致时的像素值, 不一致时为-1
+func uniformNeighbourhood(ref *Image, x, y int32) int {
+	v := ref.GetPixel(x, y)
+	for j := int32(-1); j <= 1; j++ {
+		for i := int32(-1); i <= 1; i++ {
+			if ref.GetPixel(x+i, y+j) != v {
+				return -1
+			}
+		}
+	}
+	return v
+}
+
+// encodeRefinement 以名义AT像素算术编码细化区域
+// 入参: cx 上下文, img 图像, ref 参考图像, template1 是否模板1, dx dy 参考偏移, tpgron 是否典型预测
+func (e *testEncoder) encodeRefinement(cx []ArithCtx, img, ref *Image, template1 bool, dx, dy int32, tpgron bool) {
+	out := NewImage(img.Width(), img.Height())
+	out.Fill(false)
+	ltpContext := uint32(0x0010)
+	if template1 {
+		ltpContext = 0x0008
+	}
+	ltp := 0
+	for h := int32(0); h < img.Height(); h++ {
+		if tpgron {
+			sltp := 1
+			for w := int32(0); w < img.Width(); w++ {
+				if v := uniformNeighbourhood(ref, w-dx, h-dy); v >= 0 && v != img.GetPixel(w, h) {
+					sltp = 0
+					break
+				}
+			}
+			e.encode(&cx[ltpContext], sltp^ltp)
+			ltp = sltp
+		}
+		for w := int32(0); w < img.Width(); w++ {
+			v := img.GetPixel(w, h)
+			if ltp == 0 || uniformNeighbourhood(ref, w-dx, h-dy) < 0 {
+				e.encode(&cx[refinementContext(out, ref, template1, dx, dy, w, h)], v)
+			}
+			out.SetPixel(w, h, v)
+		}
+	}
+}
+
+// testStream 测试用顺序组织数据流
+type testStream struct {
+	out []byte
+	num uint32
+}
+
+// newTestStream 创建带文件头的顺序组织数据流
+// 入参: pages 页数
+// 返回: *testStream 数据流
+func newTestStream(pages uint32) *testStream {
+	s := &testStream{out: append([]byte(nil), kFileSignature...)}
+	s.out = append(s.out, 0x01)
+	s.out = binary.BigEndian.AppendUint32(s.out, pages)
+	return s
+}
+
+// segment 追加段, 段编号依次分配
+// 入参: typ 段类型, page 页面关联, refs 引用的段, data 段数据
+// 返回: uint32 段编号
+func (s *testStream) segment(typ byte, page uint32, refs []uint32, data []byte) uint32 {
+	return s.segmentRetain(typ, page, refs, 0, data)
+}
+
+// segmentRetain 追加带保留标志的段, 引用不超过4个段
+// 入参: typ 段标志字节, page 页面关联, refs 引用的段, retention 保留标志(第0位为本段, 其后依次为各被引用段), data 段数据
+// 返回: uint32 段编号
+func (s *testStream) segmentRetain(typ byte, page uint32, refs []uint32, retention byte, data []byte) uint32 {
+	n := s.num
+	s.num++
+	s.out = binary.BigEndian.AppendUint32(s.out, n)
+	s.out = append(s.out, typ, byte(len(refs)<<5)|retention&0x1F)
+	for _, r := range refs {
+		s.out = append(s.out, byte(r))
+	}
+	s.out = append(s.out, byte(page))
+	s.out = binary.BigEndian.AppendUint32(s.out, uint32(len(data)))
+	s.out = append(s.out, data...)
+	return n
+}
+
+// testPageInfo 构造页面信息段数据
+// 入参: w 宽度, h 高度, flags 页面标志
+// 返回: []byte 段数据
+func testPageInfo(w, h uint32, flags byte) []byte {
+	var b []byte
+	for _, v := range []uint32{w, h, 0, 0} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return append(b, flags, 0, 0)
+}
+
+// testRegionInfo 构造区域信息
+// 入参: w 宽度, h 高度, x y 位置, op 组合操作
+// 返回: []byte 区域信息
+func testRegionInfo(w, h, x, y uint32, op ComposeOp) []byte {
+	var b []byte
+	for _, v := range []uint32{w, h, x, y} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return append(b, byte(op))
+}
+
+// testGenericRegion 构造以名义AT像素算术编码的通用区域段数据
+// 入参: img 图像, x y 位置, op 组合操作, template 模板号, tpgdon 是否典型预测
+// 返回: []byte 段数据
+func testGenericRegion(img *Image, x, y uint32, op ComposeOp, template int, tpgdon bool) []byte {
+	b := testRegionInfo(uint32(img.Width()), uint32(img.Height()), x, y, op)
+	flags := byte(template << 1)
+	if tpgdon {
+		flags |= 0x08
+	}
+	b = append(b, flags)
+	for _, a := range kTestNominalAT[template] {
+		b = append(b, byte(a))
+	}
+	e := newTestEncoder()
+	e.encodeGeneric(make([]ArithCtx, GetHuffContextSize(uint8(template))), img, template, tpgdon)
+	return append(b, e.flush()...)
+}
+
+// testRefinementRegion 构造以名义AT像素算术编码的细化区域段数据, 参考为所引用的区域
+// 入参: img 图像, ref 参考图像, x y 位置, template1 是否模板1, tpgron 是否典型预测
+// 返回: []byte 段数据
+func testRefinementRegion(img, ref *Image, x, y uint32, template1, tpgron bool) []byte {
+	b := testRegionInfo(uint32(img.Width()), uint32(img.Height()), x, y, ComposeOr)
+	var flags byte
+	if template1 {
+		flags |= 0x01
+	}
+	if tpgron {
+		flags |= 0x02
+	}
+	b = append(b, flags)
+	if !template1 {
+		b = append(b, 0xFF, 0xFF, 0xFF, 0xFF)
+	}
+	e := newTestEncoder()
+	e.encodeRefinement(make([]ArithCtx, 1<<13), img, ref, template1, 0, 0, tpgron)
+	return append(b, e.flush()...)
+}
+
+// testSymbolDict 构造算术编码的符号字典段数据, 导出全部新符号
+// 符号按高度分组, 各符号以字典模板及名义AT像素编码
+// 入参: syms 符号, template 字典模板号
+// 返回: []byte 段数据, []*Image 导出顺序的符号
+func testSymbolDict(syms []*Image, template int) ([]byte, []*Image) {
+	order := append([]*Image(nil), syms...)
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Height() < order[j].Height() })
+	e := newTestEncoder()
+	cx := make([]ArithCtx, GetHuffContextSize(uint8(template)))
+	var iadh, iadw, iaex [512]ArithCtx
+	prevH := int32(0)
+	for i := 0; i < len(order); {
+		h := order[i].Height()
+		e.encodeInt(&iadh, h-prevH, false)
+		prevH = h
+		prevW := int32(0)
+		for ; i < len(order) && order[i].Height() == h; i++ {
+			e.encodeInt(&iadw, order[i].Width()-prevW, false)
+			prevW = order[i].Width()
+			e.encodeGeneric(cx, order[i], template, false)
+		}
+		e.encodeInt(&iadw, 0, true)
+	}
+	e.encodeInt(&iaex, 0, false)
+	e.encodeInt(&iaex, int32(len(order)), false)
+	b := binary.BigEndian.AppendUint16(nil, uint16(template)<<10)
+	for _, a := range kTestNominalAT[template] {
+		b = append(b, byte(a))
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(order)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(order)))
+	return append(b, e.flush()...), order
+}
+
+// testPlacement 文本区域中的符号实例
+type testPlacement struct {
+	id   uint32
+	x, y int32
+}
+
+// testTextRegion 构造算术编码的文本区域段数据, 每个实例单独成条, 参考角为左上角
+// 入参: w h 区域大小, numSyms 可用符号数, ps 符号实例
+// 返回: []byte 段数据
+func testTextRegion(w, h uint32, numSyms int, ps []testPlacement) []byte {
+	ps = append([]testPlacement(nil), ps...)
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].y < ps[j].y })
+	codeLen := uint(0)
+	for 1<<codeLen < numSyms {
+		codeLen++
+	}
+	e := newTestEncoder()
+	var iadt, iafs, iads [512]ArithCtx
+	iaid := make([]ArithCtx, 1<<(codeLen+1))
+	e.encodeInt(&iadt, 0, false)
+	stripT, firstS := int32(0), int32(0)
+	for _, p := range ps {
+		e.encodeInt(&iadt, p.y-stripT, false)
+		stripT = p.y
+		e.encodeInt(&iafs, p.x-firstS, false)
+		firstS = p.x
+		e.encodeIaid(iaid, codeLen, p.id)
+		e.encodeInt(&iads, 0, true)
+	}
+	b := testRegionInfo(w, h, 0, 0, ComposeOr)
+	b = binary.BigEndian.AppendUint16(b, 1<<4)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ps)))
+	return append(b, e.flush()...)
+}
+
+// testBits 测试用位写入器, 按高位在前的顺序写入
+type testBits struct {
+	bits []byte
+}
+
+// write 写入数值的低n位
+// 入参: v 数值, n 位数
+func (b *testBits) write(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, byte(v>>uint(i)&1))
+	}
+}
+
+// huffman 以霍夫曼表编码数值, 只使用普通范围行与上界范围行
+// 入参: table 霍夫曼表, v 数值
+func (b *testBits) huffman(table *HuffmanTable, v int32) {
+	for i, code := range table.CODES {
+		if code.Codelen == 0 || table.isLowerRange(i) || (table.HTOOB && i == len(table.CODES)-1) {
+			continue
+		}
+		low, n := int64(table.RANGELOW[i]), table.RANGELEN[i]
+		if int64(v) >= low && int64(v)-low < int64(1)<<uint(n) {
+			b.write(uint32(code.Code), int(code.Codelen))
+			b.write(uint32(int64(v)-low), int(n))
+			return
+		}
+	}
+	panic("value outside huffman table")
+}
+
+// oob 以霍夫曼表编码OOB
+// 入参: table 霍夫曼表
+func (b *testBits) oob(table *HuffmanTable) {
+	code := table.CODES[len(table.CODES)-1]
+	b.write(uint32(code.Code), int(code.Codelen))
+}
+
+// raw 字节对齐后写入字节
+// 入参: data 数据
+func (b *testBits) raw(data []byte) {
+	b.align()
+	for _, v := range data {
+		b.write(uint32(v), 8)
+	}
+}
+
+// align 以0补齐到字节边界
+func (b *testBits) align() {
+	for len(b.bits)%8 != 0 {
+		b.bits = append(b.bits, 0)
+	}
+}
+
+// bytes 字节对齐后返回写入的数据
+// 返回: []byte 数据
+func (b *testBits) bytes() []byte {
+	b.align()
+	out := make([]byte, len(b.bits)/8)
+	for i, v := range b.bits {
+		out[i/8] |= v << uint(7-i%8)
+	}
+	return out
+}
+
+// testHuffmanRefAggDict 构造霍夫曼编码且细化聚合的符号字典段数据
+// 新符号大小相同, 各以单个实例细化一个输入符号, 细化使用模板1, 全部输入与新符号依次导出
+// 入参: inputs 输入符号, syms 新符号, refs 各新符号细化的输入符号序号, flags 附加的上下文使用与保留标志, cx 细化上下文, 编码后保持延续
+// 返回: []byte 段数据
+func testHuffmanRefAggDict(inputs, syms []*Image, refs []int, flags uint16, cx []ArithCtx) []byte {
+	var bits testBits
+	bits.huffman(NewStandardTable(4), syms[0].Height())
+	prevW := int32(0)
+	for i, sym := range syms {
+		bits.huffman(NewStandardTable(2), sym.Width()-prevW)
+		prevW = sym.Width()
+		bits.huffman(NewStandardTable(1), 1)
+		codeLen := 1
+		for 1<<codeLen < len(inputs)+i {
+			codeLen++
+		}
+		bits.write(uint32(refs[i]), codeLen)
+		bits.huffman(NewStandardTable(15), 0)
+		bits.huffman(NewStandardTable(15), 0)
+		e := newTestEncoder()
+		e.encodeRefinement(cx, sym, inputs[refs[i]], true, 0, 0, false)
+		data := e.flush()
+		bits.huffman(NewStandardTable(1), int32(len(data)))
+		bits.raw(data)
+	}
+	bits.oob(NewStandardTable(2))
+	bits.huffman(NewStandardTable(1), 0)
+	bits.huffman(NewStandardTable(1), int32(len(inputs)+len(syms)))
+	b := binary.BigEndian.AppendUint16(nil, flags|0x1000|0x0002|0x0001)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(inputs)+len(syms)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(syms)))
+	return append(b, bits.bytes()...)
+}
+
+// randomImage 生成类似文字的随机图像
+// 入参: r 随机数源, w h 大小, blobs 色块数
+// 返回: *Image 图像
+func randomImage(r *rand.Rand, w, h int32, blobs int) *Image {
+	img := NewImage(w, h)
+	img.Fill(false)
+	for i := 0; i < blobs; i++ {
+		x0, y0 := r.Int31n(w), r.Int31n(h)
+		bw, bh := 1+r.Int31n(12), 1+r.Int31n(16)
+		for y := y0; y < y0+bh; y++ {
+			for x := x0; x < x0+bw; x++ {
+				if r.Intn(5) != 0 {
+					img.SetPixel(x, y, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// randomBytes 生成随机字节
+// 入参: r 随机数源, n 字节数
+// 返回: []byte 数据
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+// imageEqual 比较两个图像的像素
+// 入参: a b 图像
+// 返回: bool 是否一致
+func imageEqual(a, b *Image) bool {
+	if a.Width() != b.Width() || a.Height() != b.Height() {
+		return false
+	}
+	for y := int32(0); y < a.Height(); y++ {
+		for x := int32(0); x < a.Width(); x++ {
+			if a.GetPixel(x, y) != b.GetPixel(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pageEqual 比较解码得到的页面与期望的位图, 黑色像素对应置位
+// 入参: img 解码得到的页面, want 期望的位图
+// 返回: bool 是否一致
+func pageEqual(img image.Image, want *Image) bool {
+	bounds := img.Bounds()
+	if bounds.Dx() != int(want.Width()) || bounds.Dy() != int(want.Height()) {
+		return false
+	}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, _, _, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			if (r < 0x8000) != (want.GetPixel(int32(x), int32(y)) != 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf16"
+)
+
+const (
+	// ExtensionCommentASCII ASCII注释扩展
+	ExtensionCommentASCII uint32 = 0x20000000
+	// ExtensionCommentUCS2 UCS-2注释扩展
+	ExtensionCommentUCS2 uint32 = 0x20000002
+)
+
+const (
+	// kExtensionNecessary 扩展类型中的必需标志, 不理解该扩展时无法正确解码
+	kExtensionNecessary uint32 = 0x80000000
+	// kExtensionDependent 扩展类型中的依赖标志, 扩展依赖其引用的段
+	kExtensionDependent uint32 = 0x40000000
+)
+
+// ErrUnknownExtension 标记为必需的扩展段没有可用的处理函数
+var ErrUnknownExtension = errors.New("unknown necessary extension")
+
+// Comment 注释扩展中的名称与值
+type Comment struct {
+	// Name 名称
+	Name string
+	// Value 值
+	Value string
+}
+
+// Extension 扩展段
+type Extension struct {
+	// Type 扩展类型, 不含必需与依赖标志位
+	Type uint32
+	// Necessary 不理解该扩展时无法正确解码
+	Necessary bool
+	// Dependent 扩展依赖其引用的段
+	Dependent bool
+	// Segment 段编号
+	Segment uint32
+	// Page 页面关联, 0表示属于整个文档
+	Page uint32
+	// Data 扩展类型之后的扩展数据
+	Data []byte
+	// Comments 注释扩展解析出的名称与值, 其他扩展为nil
+	Comments []Comment
+}
+
+// ExtensionHandler 扩展段处理函数
+// 返回错误时该段解码失败, 并行解码时可能被并发调用
+type ExtensionHandler func(ext Extension) error
+
+var (
+	extensionMu       sync.RWMutex
+	extensionHandlers = make(map[uint32]ExtensionHandler)
+)
+
+// RegisterExtension 注册进程内全部解码器共享的扩展段处理函数
+// 扩展类型不含必需与依赖标志位, 同一类型重复注册时替换原处理函数, handler为nil时取消注册
+// 注释扩展总是被解析为元数据, 注册的处理函数在解析之后调用
+// 只作用于单个解码器的处理函数通过DecoderOptions.Extensions给出
+// 入参: typ 扩展类型, handler 处理函数
+func RegisterExtension(typ uint32, handler ExtensionHandler) {
+	typ &^= kExtensionNecessary | kExtensionDependent
+	extensionMu.Lock()
+	defer extensionMu.Unlock()
+	if handler == nil {
+		delete(extensionHandlers, typ)
+		return
+	}
+	extensionHandlers[typ] = handler
+}
+
+// extensionHandler 查找扩展段处理函数
+// 解码器选项中的处理函数优先于RegisterExtension注册的处理函数
+// 入参: typ 扩展类型
+// 返回: ExtensionHandler 处理函数, 未注册时为nil
+func (d *Document) extensionHandler(typ uint32) ExtensionHandler {
+	if d.opts != nil {
+		if handler := d.opts.Extensions[typ]; handler != nil {
+			return handler
+		}
+	}
+	extensionMu.RLock()
+	defer extensionMu.RUnlock()
+	return extensionHandlers[typ]
+}
+
+// Comments 获取数据流与全局段中属于整个文档的注释
+// 注释段在解码到达时才被解析
+// 返回: []Comment 注释列表
+func (d *Decoder) Comments() []Comment {
+	if d.doc == nil {
+		return nil
+	}
+	var comments []Comment
+	if d.doc.globalContext != nil {
+		comments = append(comments, d.doc.globalContext.comments...)
+	}
+	return append(comments, d.doc.comments...)
+}
+
+// PageComments 获取最近一次解码的页面的注释
+// 返回: []Comment 注释列表
+func (d *Decoder) PageComments() []Comment {
+	if d.doc == nil {
+		return nil
+	}
+	return d.doc.pageComments
+}
+
+// parseExtension 解析扩展段
+// 注释扩展记录为文档或页面的注释, 之后调用解码器选项或RegisterExtension给出的处理函数
+// 未知扩展标记为必需时解码失败, 否则跳过
+// 入参: segment 段对象
+// 返回: Result 解析结果
+func (d *Document) parseExtension(segment *Segment) Result {
+	if segment.DataLength < 4 || segment.DataLength == 0xFFFFFFFF {
+		return ResultFailure
+	}
+	var typ uint32
+	if val, err := d.stream.ReadInteger(); err != nil {
+		return ResultFailure
+	} else {
+		typ = val
+	}
+	start := d.stream.GetOffset()
+	size := segment.DataLength - 4
+	if uint64(start)+uint64(size) > uint64(d.stream.GetLength()) {
+		return ResultFailure
+	}
+	ext := Extension{
+		Type:      typ &^ (kExtensionNecessary | kExtensionDependent),
+		Necessary: typ&kExtensionNecessary != 0,
+		Dependent: typ&kExtensionDependent != 0,
+		Segment:   segment.Number,
+		Page:      segment.PageAssociation,
+		Data:      bytes.Clone(d.stream.data[start : start+size]),
+	}
+	d.stream.AddOffset(size)
+	comment := true
+	switch ext.Type {
+	case ExtensionCommentASCII:
+		ext.Comments = parseComments(ext.Data, 1, func(b []byte) string {
+			return string(b)
+		})
+	case ExtensionCommentUCS2:
+		order := byteOrder(d.stream.littleEndian)
+		ext.Comments = parseComments(ext.Data, 2, func(b []byte) string {
+			units := make([]uint16, len(b)/2)
+			for i := range units {
+				units[i] = order.Uint16(b[i*2:])
+			}
+			return string(utf16.Decode(units))
+		})
+	default:
+		comment = false
+	}
+	if comment {
+		if ext.Page != 0 {
+			d.pageComments = append(d.pageComments, ext.Comments...)
+		} else {
+			d.comments = append(d.comments, ext.Comments...)
+		}
+	}
+	handler := d.extensionHandler(ext.Type)
+	if handler == nil {
+		if ext.Necessary && !comment {
+			d.extErr = fmt.Errorf("%w: 0x%08x in segment %d", ErrUnknownExtension, ext.Type, ext.Segment)
+			return ResultFailure
+		}
+		return ResultSuccess
+	}
+	if err := handler(ext); err != nil {
+		d.extErr = fmt.Errorf("extension 0x%08x in segment %d: %w", ext.Type, ext.Segment, err)
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// parseComments 解析注释扩展中以空字符结束的名称与值
+// 空名称表示列表结束, 不完整的末尾名称与值被忽略
+// 入参: data 扩展数据, width 字符宽度, decode 字符解码函数
+// 返回: []Comment 注释列表
+func parseComments(data []byte, width int, decode func([]byte) string) []Comment {
+	var comments []Comment
+	next := func() ([]byte, bool) {
+		for i := 0; i+width <= len(data); i += width {
+			if data[i] == 0 && (width == 1 || data[i+1] == 0) {
+				s := data[:i]
+				data = data[i+width:]
+				return s, true
+			}
+		}
+		return nil, false
+	}
+	for {
+		name, ok := next()
+		if !ok || len(name) == 0 {
+			break
+		}
+		value, ok := next()
+		if !ok {
+			break
+		}
+		comments = append(comments, Comment{Name: decode(name), Value: decode(value)})
+	}
+	return comments
+}
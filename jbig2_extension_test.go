@@ -0,0 +1,208 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"slices"
+	"testing"
+	"unicode/utf16"
+)
+
+// testComments 构造注释扩展段数据
+// 入参: ucs2 是否UCS-2注释, pairs 依次为名称与值
+// 返回: []byte 段数据
+func testComments(ucs2 bool, pairs ...string) []byte {
+	if !ucs2 {
+		b := binary.BigEndian.AppendUint32(nil, ExtensionCommentASCII)
+		for _, s := range pairs {
+			b = append(append(b, s...), 0)
+		}
+		return append(b, 0)
+	}
+	b := binary.BigEndian.AppendUint32(nil, ExtensionCommentUCS2)
+	for _, s := range pairs {
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = binary.BigEndian.AppendUint16(b, u)
+		}
+		b = append(b, 0, 0)
+	}
+	return append(b, 0, 0)
+}
+
+// testExtension 构造扩展段数据
+// 入参: typ 扩展类型(含标志位), data 扩展数据
+// 返回: []byte 段数据
+func testExtension(typ uint32, data []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, typ), data...)
+}
+
+// testExtensionStream 构造含一个扩展段的单页数据流
+// 入参: ext 扩展段数据
+// 返回: []byte 数据流, uint32 扩展段的段编号
+func testExtensionStream(ext []byte) ([]byte, uint32) {
+	r := rand.New(rand.NewSource(50))
+	s := newTestStream(1)
+	s.segment(48, 1, nil, testPageInfo(32, 16, 0))
+	num := s.segment(62, 1, nil, ext)
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 32, 16, 4), 0, 0, ComposeOr, 0, false))
+	s.segment(49, 1, nil, nil)
+	return s.out, num
+}
+
+func TestComments(t *testing.T) {
+	r := rand.New(rand.NewSource(51))
+	g := &testStream{}
+	g.segment(62, 0, nil, testComments(false, "Source", "globals"))
+	globals, err := ParseGlobals(g.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStream(2)
+	s.segment(62, 0, nil, testComments(false, "Title", "jbig2", "Producer", "test"))
+	s.segment(48, 1, nil, testPageInfo(32, 16, 0))
+	s.segment(62, 1, nil, testComments(true, "作者", "肖其顿", "Note", ""))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 32, 16, 4), 0, 0, ComposeOr, 0, false))
+	s.segment(49, 1, nil, nil)
+	s.segment(48, 2, nil, testPageInfo(32, 16, 0))
+	s.segment(62, 2, nil, testComments(false, "Page", "2"))
+	s.segment(49, 2, nil, nil)
+	s.segment(62, 0, nil, testComments(true, "Trailer", "结束"))
+	s.segment(51, 0, nil, nil)
+
+	dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(s.out), globals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := []Comment{{"Source", "globals"}, {"Title", "jbig2"}, {"Producer", "test"}}
+	pages := [][]Comment{{{"作者", "肖其顿"}, {"Note", ""}}, {{"Page", "2"}}}
+	for p, want := range pages {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("page %d: %v", p+1, err)
+		}
+		// 文档注释包含全局段中的注释, 页面注释只属于最近一次解码的页面
+		if got := dec.Comments(); !slices.Equal(got, doc) {
+			t.Errorf("page %d: Comments() = %v, want %v", p+1, got, doc)
+		}
+		if got := dec.PageComments(); !slices.Equal(got, want) {
+			t.Errorf("page %d: PageComments() = %v, want %v", p+1, got, want)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("error %v, want EOF", err)
+	}
+	// 页面之后的文档注释在到达时才被解析
+	doc = append(doc, Comment{"Trailer", "结束"})
+	if got := dec.Comments(); !slices.Equal(got, doc) {
+		t.Errorf("Comments() = %v, want %v", got, doc)
+	}
+}
+
+func TestUnknownExtension(t *testing.T) {
+	const typ = 0x00123456
+	tests := []struct {
+		name  string
+		flags uint32
+		err   error
+	}{
+		{"optional", 0, nil},
+		{"dependent", kExtensionDependent, nil},
+		{"necessary", kExtensionNecessary, ErrUnknownExtension},
+	}
+	for _, tt := range tests {
+		data, _ := testExtensionStream(testExtension(typ|tt.flags, []byte{1, 2, 3}))
+		dec, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Decode(); !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestExtensionHandlers(t *testing.T) {
+	const typ = 0x00123457
+	payload := []byte{9, 8, 7, 6}
+	data, num := testExtensionStream(testExtension(typ|kExtensionNecessary, payload))
+
+	// 各解码器只调用自身选项中的处理函数
+	var calls [2][]Extension
+	decs := make([]*Decoder, 2)
+	for i := range decs {
+		dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{
+			Extensions: map[uint32]ExtensionHandler{typ: func(ext Extension) error {
+				calls[i] = append(calls[i], ext)
+				return nil
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		decs[i] = dec
+	}
+	if _, err := decs[1].Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls[0]) != 0 || len(calls[1]) != 1 {
+		t.Fatalf("handler calls %d and %d, want 0 and 1", len(calls[0]), len(calls[1]))
+	}
+	ext := calls[1][0]
+	if ext.Type != typ || !ext.Necessary || ext.Dependent || ext.Segment != num || ext.Page != 1 || !bytes.Equal(ext.Data, payload) {
+		t.Errorf("handler got %+v", ext)
+	}
+
+	// 选项中的处理函数优先于注册的处理函数, 未给出时使用注册的处理函数
+	global := 0
+	RegisterExtension(typ|kExtensionNecessary, func(Extension) error {
+		global++
+		return nil
+	})
+	t.Cleanup(func() { RegisterExtension(typ, nil) })
+	if _, err := decs[0].Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls[0]) != 1 || global != 0 {
+		t.Errorf("option handler calls %d, registered handler calls %d, want 1 and 0", len(calls[0]), global)
+	}
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err != nil || global != 1 {
+		t.Errorf("registered handler: error %v after %d calls, want 1 call", err, global)
+	}
+}
+
+func TestExtensionHandlerError(t *testing.T) {
+	const typ = 0x00123458
+	stop := errors.New("stop")
+	for _, flags := range []uint32{0, kExtensionNecessary} {
+		data, _ := testExtensionStream(testExtension(typ|flags, nil))
+		dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{
+			Extensions: map[uint32]ExtensionHandler{typ: func(Extension) error { return stop }},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Decode(); !errors.Is(err, stop) {
+			t.Errorf("flags %#x: error = %v, want %v", flags, err, stop)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import "errors"
+
+// Globals 已解析的全局段
+// 解析完成后不再修改, 可被任意数量的解码器并发引用
+type Globals struct {
+	doc *Document
+}
+
+// ParseGlobals 解析全局段数据
+// 入参: data 全局段数据
+// 返回: *Globals 全局段, error 错误信息
+func ParseGlobals(data []byte) (*Globals, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty globals data")
+	}
+	doc := newGlobalDocument(data)
+	for {
+		offset := doc.stream.GetOffset()
+		res := doc.DecodeSequential()
+		if res == ResultEndReached {
+			break
+		}
+		if res == ResultFailure {
+			return nil, errors.New("failed to parse global segments")
+		}
+		if doc.stream.GetOffset() == offset {
+			break
+		}
+	}
+	doc.segment = nil
+	return &Globals{doc: doc}, nil
+}
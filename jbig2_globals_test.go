@@ -0,0 +1,90 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestGlobalsConcurrentDecode(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	var syms []*Image
+	for i := 0; i < 6; i++ {
+		syms = append(syms, randomImage(r, 4+r.Int31n(8), 6+r.Int31n(6), 3))
+	}
+	dict, order := testSymbolDict(syms, 0)
+	g := &testStream{}
+	g.segment(0, 0, nil, dict)
+	globals, err := ParseGlobals(g.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const pages = 8
+	streams := make([][]byte, pages)
+	wants := make([]*Image, pages)
+	for i := range streams {
+		want := NewImage(96, 48)
+		want.Fill(false)
+		var ps []testPlacement
+		for j := 0; j < 10; j++ {
+			p := testPlacement{id: uint32(r.Intn(len(order))), x: r.Int31n(80), y: r.Int31n(36)}
+			ps = append(ps, p)
+			order[p.id].ComposeTo(want, p.x, p.y, ComposeOr)
+		}
+		s := newTestStream(1)
+		s.num = 1
+		s.segment(48, 1, nil, testPageInfo(96, 48, 0))
+		s.segment(6, 1, []uint32{0}, testTextRegion(96, 48, len(order), ps))
+		streams[i], wants[i] = s.out, want
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, pages)
+	for i := range streams {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 4; n++ {
+				dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(streams[i]), globals)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				img, err := dec.Decode()
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				if !pageEqual(img, wants[i]) {
+					errs[i] = errors.New("page differs")
+					return
+				}
+				if dec.GetDocument().FindSegmentByNumber(0) != nil {
+					errs[i] = errors.New("shared global segment exposed")
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("page %d: %v", i, err)
+		}
+	}
+}
@@ -28,6 +28,8 @@ type GRDProc struct {
 	USESKIP     bool
 	SKIP        *Image
 	GBAT        [8]int8
+	EXTTEMPLATE bool
+	GBATEXT     [16]int8
 	loopIndex   uint32
 	line        []byte
 	decodeType  uint16
@@ -41,11 +43,18 @@ func NewGRDProc() *GRDProc {
 	return &GRDProc{}
 }
 
+// PauseIndicator 暂停指示器
+type PauseIndicator interface {
+	// NeedToPauseNow 是否在解码下一行之前暂停
+	NeedToPauseNow() bool
+}
+
 // ProgressiveArithDecodeState 渐进式算术解码状态
 type ProgressiveArithDecodeState struct {
 	Image        **Image
 	ArithDecoder *ArithDecoder
 	GbContexts   []ArithCtx
+	Pause        PauseIndicator
 }
 
 // StartDecodeArith 开始算术解码
@@ -129,7 +138,9 @@ func (g *GRDProc) ProgressiveDecodeArith(state *ProgressiveArithDecodeState) JBi
 	var res JBig2SegmentState
 	switch g.GBTEMPLATE {
 	case 0:
-		if g.useTemplate0Opt3() {
+		if g.EXTTEMPLATE && !g.extNominal() {
+			res = g.decodeTemplate0Ext(state)
+		} else if g.useTemplate0Opt3() {
 			res = g.decodeTemplate0Opt3(state)
 		} else {
 			res = g.decodeTemplate0Unopt(state)
@@ -165,17 +176,26 @@ func (g *GRDProc) ProgressiveDecodeArith(state *ProgressiveArithDecodeState) JBi
 func (g *GRDProc) useTemplate0Opt3() bool {
 	return g.GBAT[0] == 3 && g.GBAT[1] == -1 && g.GBAT[2] == -3 &&
 		g.GBAT[3] == -1 && g.GBAT[4] == 2 && g.GBAT[5] == -2 &&
-		g.GBAT[6] == -2 && g.GBAT[7] == -2 && !g.USESKIP
+		g.GBAT[6] == -2 && g.GBAT[7] == -2
+}
+
+// ContextSize 获取算术解码上下文数
+// 返回: int 上下文数
+func (g *GRDProc) ContextSize() int {
+	if g.EXTTEMPLATE {
+		return kExtTemplateContexts
+	}
+	return GetHuffContextSize(g.GBTEMPLATE)
 }
 
 // useTemplate1Opt3 检查是否可用模板1优化3
 // 返回: bool 是否可用
 func (g *GRDProc) useTemplate1Opt3() bool {
-	return g.GBAT[0] == 3 && g.GBAT[1] == -1 && !g.USESKIP
+	return g.GBAT[0] == 3 && g.GBAT[1] == -1
 }
 
 // useTemplate23Opt3 检查是否可用模板23优化3
 // 返回: bool 是否可用
 func (g *GRDProc) useTemplate23Opt3() bool {
-	return g.GBAT[0] == 2 && g.GBAT[1] == -1 && !g.USESKIP
+	return g.GBAT[0] == 2 && g.GBAT[1] == -1
 }
@@ -25,13 +25,30 @@ var (
 	kOptConstant11 = []uint32{0x001f, 0x001f, 0x000f}
 	// kOptConstant12 优化常量12
 	kOptConstant12 = []uint32{0x000f, 0x0007, 0x0003}
+	// kOptTemplates 名义AT像素下各模板的上下文窗口
+	kOptTemplates = [4]optTemplate{
+		{left2: 2, right2: 2, shift2: 11, left1: 3, right1: 3, shift1: 4, bits0: 4, ltpContext: 0x9b25},
+		{left2: 1, right2: 2, shift2: 9, left1: 2, right1: 3, shift1: 3, bits0: 3, ltpContext: 0x0795},
+		{left2: 1, right2: 1, shift2: 7, left1: 2, right1: 2, shift1: 2, bits0: 2, ltpContext: 0x00e5},
+		{noLine2: true, left1: 3, right1: 2, shift1: 4, bits0: 4, ltpContext: 0x0195},
+	}
 )
 
+// optTemplate 模板上下文窗口
+// 名义AT像素位于参考行的连续窗口内, 上下文由上两行窗口与当前行已解码像素拼接而成
+type optTemplate struct {
+	left2, right2, shift2 uint
+	left1, right1, shift1 uint
+	bits0                 uint
+	ltpContext            uint32
+	noLine2               bool
+}
+
 // decodeTemplate0Opt3 模板0优化3解码
 // 入参: state 解码状态
 // 返回: JBig2SegmentState 状态
 func (g *GRDProc) decodeTemplate0Opt3(state *ProgressiveArithDecodeState) JBig2SegmentState {
-	return g.decodeTemplateUnopt(state, 0)
+	return g.decodeTemplateOpt3(state, 0)
 }
 
 // decodeTemplate0Unopt 模板0非优化解码
@@ -45,7 +62,7 @@ func (g *GRDProc) decodeTemplate0Unopt(state *ProgressiveArithDecodeState) JBig2
 // 入参: state 解码状态
 // 返回: JBig2SegmentState 状态
 func (g *GRDProc) decodeTemplate1Opt3(state *ProgressiveArithDecodeState) JBig2SegmentState {
-	return g.decodeTemplateUnopt(state, 1)
+	return g.decodeTemplateOpt3(state, 1)
 }
 
 // decodeTemplate1Unopt 模板1非优化解码
@@ -59,7 +76,131 @@ func (g *GRDProc) decodeTemplate1Unopt(state *ProgressiveArithDecodeState) JBig2
 // 入参: state 解码状态, opt 选项
 // 返回: JBig2SegmentState 状态
 func (g *GRDProc) decodeTemplate23Opt3(state *ProgressiveArithDecodeState, opt int) JBig2SegmentState {
-	return g.decodeTemplateUnopt(state, opt)
+	return g.decodeTemplateOpt3(state, opt)
+}
+
+// decodeTemplateOpt3 名义AT像素下的按字节算术解码
+// 典型预测确定的行直接复制上一行, 其余行按字节解码
+// 入参: state 解码状态, template 模板号
+// 返回: JBig2SegmentState 状态
+func (g *GRDProc) decodeTemplateOpt3(state *ProgressiveArithDecodeState, template int) JBig2SegmentState {
+	if state.Image == nil || *state.Image == nil {
+		return JBig2SegmentError
+	}
+	t := &kOptTemplates[template]
+	var skipLine *Image
+	if g.USESKIP && g.SKIP != nil {
+		skipLine = NewImage(int32(g.GBW), 1)
+		defer skipLine.release()
+	}
+	for ; g.loopIndex < g.GBH; g.loopIndex++ {
+		if state.Pause != nil && state.Pause.NeedToPauseNow() {
+			return JBig2SegmentPaused
+		}
+		h := int32(g.loopIndex)
+		if g.TPGDON {
+			typical, ok := g.decodeTypicalLine(state, t.ltpContext, h)
+			if !ok {
+				return JBig2SegmentError
+			}
+			if typical {
+				continue
+			}
+		}
+		if !g.decodeLineOpt3(state, t, h, skipLine) {
+			return JBig2SegmentError
+		}
+	}
+	return JBig2SegmentParseComplete
+}
+
+// decodeTypicalLine 解码典型预测标志, 行为典型行时复制上一行
+// 入参: state 解码状态, ltpContext 典型预测上下文, h 行号
+// 返回: bool 是否为典型行, bool 是否解码成功
+func (g *GRDProc) decodeTypicalLine(state *ProgressiveArithDecodeState, ltpContext uint32, h int32) (bool, bool) {
+	decoder := state.ArithDecoder
+	if decoder.IsComplete() {
+		return false, false
+	}
+	if decoder.Decode(&state.GbContexts[ltpContext]) != 0 {
+		g.ltp ^= 1
+	}
+	if g.ltp == 0 {
+		return false, true
+	}
+	(*state.Image).CopyLine(h, h-1)
+	return true, true
+}
+
+// decodeLineOpt3 按字节解码一行
+// 参考行按字节载入滚动寄存器, 解码结果按字节直接写入输出行
+// 入参: state 解码状态, t 模板上下文窗口, h 行号, skipLine 跳过行缓冲区, 不使用跳过时为nil
+// 返回: bool 是否解码成功
+func (g *GRDProc) decodeLineOpt3(state *ProgressiveArithDecodeState, t *optTemplate, h int32, skipLine *Image) bool {
+	img := *state.Image
+	gbContexts := state.GbContexts
+	decoder := state.ArithDecoder
+	stride := int(img.stride)
+	width := int(g.GBW)
+	mask2 := uint32(1)<<(t.left2+t.right2+1) - 1
+	mask1 := uint32(1)<<(t.left1+t.right1+1) - 1
+	mask0 := uint32(1)<<t.bits0 - 1
+	y := int(h)
+	row := img.data[y*stride : (y+1)*stride]
+	var line1, line2, skip []byte
+	if y >= 1 {
+		line1 = img.data[(y-1)*stride : y*stride]
+	}
+	if y >= 2 && !t.noLine2 {
+		line2 = img.data[(y-2)*stride : (y-1)*stride]
+	}
+	if skipLine != nil {
+		skipLine.Fill(false)
+		blit(skipLine, g.SKIP, 0, -int64(h), ComposeReplace)
+		skip = skipLine.data
+	}
+	reg1 := lineByte(line1, 0)<<8 | lineByte(line1, 1)
+	reg2 := lineByte(line2, 0)<<8 | lineByte(line2, 1)
+	line0 := uint32(0)
+	for b := 0; b*8 < width; b++ {
+		if b > 0 {
+			reg1 = reg1<<8 | lineByte(line1, b+1)
+			reg2 = reg2<<8 | lineByte(line2, b+1)
+		}
+		n := min(8, width-b*8)
+		skipBits := byte(0)
+		if skip != nil {
+			skipBits = skip[b]
+		}
+		cVal := byte(0)
+		for k := 0; k < n; k++ {
+			bVal := 0
+			if skipBits&(0x80>>uint(k)) == 0 {
+				if decoder.IsComplete() {
+					row[b] = cVal
+					return false
+				}
+				CONTEXT := line0
+				CONTEXT |= ((reg1 >> (15 - uint(k) - t.right1)) & mask1) << t.shift1
+				CONTEXT |= ((reg2 >> (15 - uint(k) - t.right2)) & mask2) << t.shift2
+				bVal = decoder.Decode(&gbContexts[CONTEXT])
+			}
+			cVal |= byte(bVal) << (7 - uint(k))
+			line0 = ((line0 << 1) | uint32(bVal)) & mask0
+		}
+		row[b] = cVal
+	}
+	return true
+}
+
+// lineByte 获取行中指定字节, 越界时返回0
+// 入参: line 行数据, idx 字节索引
+// 返回: uint32 字节值
+func lineByte(line []byte, idx int) uint32 {
+	if idx >= len(line) {
+		return 0
+	}
+	return uint32(line[idx])
 }
 
 // decodeTemplateUnopt 通用算术解码
@@ -77,6 +218,9 @@ func (g *GRDProc) decodeTemplateUnopt(state *ProgressiveArithDecodeState, opt in
 	shift := uint(4 - opt)
 	shiftC9 := kOptConstant9[opt]
 	for ; g.loopIndex < g.GBH; g.loopIndex++ {
+		if state.Pause != nil && state.Pause.NeedToPauseNow() {
+			return JBig2SegmentPaused
+		}
 		h := int32(g.loopIndex)
 		if g.TPGDON {
 			if decoder.IsComplete() {
@@ -146,6 +290,9 @@ func (g *GRDProc) decodeTemplate3Unopt(state *ProgressiveArithDecodeState) JBig2
 	gbContexts := state.GbContexts
 	decoder := state.ArithDecoder
 	for ; g.loopIndex < g.GBH; g.loopIndex++ {
+		if state.Pause != nil && state.Pause.NeedToPauseNow() {
+			return JBig2SegmentPaused
+		}
 		h := int32(g.loopIndex)
 		if g.TPGDON {
 			if decoder.IsComplete() {
@@ -188,3 +335,78 @@ func (g *GRDProc) decodeTemplate3Unopt(state *ProgressiveArithDecodeState) JBig2
 	}
 	return JBig2SegmentParseComplete
 }
+
+// kExtTemplateContexts 扩展模板的上下文数
+const kExtTemplateContexts = 65536
+
+// kExtTemplateShift 扩展模板中A1至A12在上下文中的位置
+// 当前行左侧4个固定像素占低4位, 各AT像素取名义位置时上下文与模板0相同
+var kExtTemplateShift = [12]uint{4, 10, 11, 15, 5, 6, 7, 8, 9, 12, 13, 14}
+
+// kExtTemplateNominal 扩展模板A5至A12的名义位置, A1至A4与模板0相同
+var kExtTemplateNominal = [16]int8{2, -1, 1, -1, 0, -1, -1, -1, -2, -1, 1, -2, 0, -2, -1, -2}
+
+// extNominal 扩展模板的12个AT像素是否均位于名义位置
+// 此时上下文与模板0名义AT像素的上下文相同, 可使用模板0的解码过程
+// 返回: bool 是否名义位置
+func (g *GRDProc) extNominal() bool {
+	return g.useTemplate0Opt3() && g.GBATEXT == kExtTemplateNominal
+}
+
+// decodeTemplate0Ext 扩展模板(EXTTEMPLATE)算术解码
+// 上下文由当前行左侧4个固定像素与12个AT像素组成, 典型预测使用与模板0相同的上下文
+// 入参: state 解码状态
+// 返回: JBig2SegmentState 状态
+func (g *GRDProc) decodeTemplate0Ext(state *ProgressiveArithDecodeState) JBig2SegmentState {
+	if state.Image == nil || *state.Image == nil {
+		return JBig2SegmentError
+	}
+	img := *state.Image
+	gbContexts := state.GbContexts
+	decoder := state.ArithDecoder
+	var at [12][2]int32
+	for i := range at {
+		if i < 4 {
+			at[i] = [2]int32{int32(g.GBAT[2*i]), int32(g.GBAT[2*i+1])}
+		} else {
+			at[i] = [2]int32{int32(g.GBATEXT[2*i-8]), int32(g.GBATEXT[2*i-7])}
+		}
+	}
+	for ; g.loopIndex < g.GBH; g.loopIndex++ {
+		if state.Pause != nil && state.Pause.NeedToPauseNow() {
+			return JBig2SegmentPaused
+		}
+		h := int32(g.loopIndex)
+		if g.TPGDON {
+			if decoder.IsComplete() {
+				return JBig2SegmentError
+			}
+			if decoder.Decode(&gbContexts[kOptConstant1[0]]) != 0 {
+				g.ltp ^= 1
+			}
+		}
+		if g.ltp == 1 {
+			img.CopyLine(h, h-1)
+			continue
+		}
+		line := uint32(0)
+		for w := int32(0); w < int32(g.GBW); w++ {
+			bVal := 0
+			if !(g.USESKIP && g.SKIP != nil && g.SKIP.GetPixel(w, h) != 0) {
+				if decoder.IsComplete() {
+					return JBig2SegmentError
+				}
+				CONTEXT := line
+				for i, p := range at {
+					CONTEXT |= uint32(img.GetPixel(w+p[0], h+p[1])) << kExtTemplateShift[i]
+				}
+				bVal = decoder.Decode(&gbContexts[CONTEXT])
+			}
+			if bVal != 0 {
+				img.SetPixel(w, h, bVal)
+			}
+			line = ((line << 1) | uint32(bVal)) & 0x0f
+		}
+	}
+	return JBig2SegmentParseComplete
+}
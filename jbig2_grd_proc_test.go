@@ -0,0 +1,248 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// runGeneric 以指定解码函数解码通用区域
+// 入参: g 解码过程, data 算术编码数据, decode 解码函数
+// 返回: *Image 图像, JBig2SegmentState 状态
+func runGeneric(g *GRDProc, data []byte, decode func(*ProgressiveArithDecodeState) JBig2SegmentState) (*Image, JBig2SegmentState) {
+	img := NewImage(int32(g.GBW), int32(g.GBH))
+	img.Fill(false)
+	state := &ProgressiveArithDecodeState{
+		Image:        &img,
+		ArithDecoder: NewArithDecoder(NewBitStream(data, 0)),
+		GbContexts:   make([]ArithCtx, GetHuffContextSize(g.GBTEMPLATE)),
+	}
+	g.ltp = 0
+	g.loopIndex = 0
+	return img, decode(state)
+}
+
+func TestGenericOptMatchesUnopt(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	for template := 0; template < 4; template++ {
+		for _, tpgdon := range []bool{false, true} {
+			for _, useSkip := range []bool{false, true} {
+				name := fmt.Sprintf("template%d/tpgdon=%v/useskip=%v", template, tpgdon, useSkip)
+				t.Run(name, func(t *testing.T) {
+					for n := 0; n < 20; n++ {
+						g := NewGRDProc()
+						g.GBW = uint32(1 + r.Intn(150))
+						g.GBH = uint32(1 + r.Intn(40))
+						g.GBTEMPLATE = uint8(template)
+						g.TPGDON = tpgdon
+						g.USESKIP = useSkip
+						copy(g.GBAT[:], kTestNominalAT[template])
+						if useSkip {
+							g.SKIP = noiseImage(r, int32(g.GBW), int32(g.GBH))
+						}
+						data := randomBytes(r, 4096)
+						want, wantRes := runGeneric(g, data, func(s *ProgressiveArithDecodeState) JBig2SegmentState {
+							if template == 3 {
+								return g.decodeTemplate3Unopt(s)
+							}
+							return g.decodeTemplateUnopt(s, template)
+						})
+						got, gotRes := runGeneric(g, data, func(s *ProgressiveArithDecodeState) JBig2SegmentState {
+							return g.decodeTemplateOpt3(s, template)
+						})
+						if gotRes != wantRes {
+							t.Fatalf("%dx%d: state %v, want %v", g.GBW, g.GBH, gotRes, wantRes)
+						}
+						if !imageEqual(got, want) {
+							t.Fatalf("%dx%d: image differs from unoptimised decode", g.GBW, g.GBH)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestGenericRegionRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	for template := 0; template < 4; template++ {
+		for _, tpgdon := range []bool{false, true} {
+			img := randomImage(r, 77, 40, 20)
+			s := newTestStream(1)
+			s.segment(48, 1, nil, testPageInfo(77, 40, 0))
+			s.segment(38, 1, nil, testGenericRegion(img, 0, 0, ComposeOr, template, tpgdon))
+			got, err := Decode(bytes.NewReader(s.out))
+			if err != nil {
+				t.Fatalf("template %d tpgdon %v: %v", template, tpgdon, err)
+			}
+			if !pageEqual(got, img) {
+				t.Errorf("template %d tpgdon %v: page differs", template, tpgdon)
+			}
+		}
+	}
+}
+
+// kTestExtNominalAT 扩展模板A1至A12的名义位置, 与模板0名义AT像素及其固定像素重合
+var kTestExtNominalAT = [24]int8{3, -1, -3, -1, 2, -2, -2, -2, 2, -1, 1, -1, 0, -1, -1, -1, -2, -1, 1, -2, 0, -2, -1, -2}
+
+// extContext 计算扩展模板的上下文
+// 当前行左侧4个像素占低4位, A1至A4位于模板0的AT像素位置(第4、10、11、15位)
+// A5至A9取代上一行的5个固定像素(第5至9位), A10至A12取代上两行的3个固定像素(第12至14位)
+// 入参: img 已编码部分的图像, at A1至A12的位置, w 横坐标, h 纵坐标
+// 返回: uint32 上下文
+func extContext(img *Image, at [24]int8, w, h int32) uint32 {
+	shift := [12]uint{4, 10, 11, 15, 5, 6, 7, 8, 9, 12, 13, 14}
+	ctx := pixelRow(img, w-1, h, 4)
+	for i, s := range shift {
+		ctx |= uint32(img.GetPixel(w+int32(at[2*i]), h+int32(at[2*i+1]))) << s
+	}
+	return ctx
+}
+
+// testExtGenericRegion 构造以扩展模板算术编码的通用区域段数据
+// 入参: img 图像, at A1至A12的位置, tpgdon 是否典型预测
+// 返回: []byte 段数据
+func testExtGenericRegion(img *Image, at [24]int8, tpgdon bool) []byte {
+	b := testRegionInfo(uint32(img.Width()), uint32(img.Height()), 0, 0, ComposeOr)
+	flags := byte(0x10)
+	if tpgdon {
+		flags |= 0x08
+	}
+	b = append(b, flags)
+	for _, a := range at {
+		b = append(b, byte(a))
+	}
+	e := newTestEncoder()
+	e.encodeGenericContext(make([]ArithCtx, 1<<16), img, kTestTPGDContext[0], tpgdon, func(out *Image, w, h int32) uint32 {
+		return extContext(out, at, w, h)
+	})
+	return append(b, e.flush()...)
+}
+
+func TestExtContextNominal(t *testing.T) {
+	// 名义位置的扩展模板上下文与模板0相同
+	img := noiseImage(rand.New(rand.NewSource(48)), 40, 12)
+	for h := int32(0); h < img.Height(); h++ {
+		for w := int32(0); w < img.Width(); w++ {
+			if got, want := extContext(img, kTestExtNominalAT, w, h), genericContext(img, 0, w, h); got != want {
+				t.Fatalf("(%d,%d): context %#x, want %#x", w, h, got, want)
+			}
+		}
+	}
+
+	// 强制使用扩展模板解码过程时结果与模板0一致
+	r := rand.New(rand.NewSource(49))
+	for n := 0; n < 20; n++ {
+		g := NewGRDProc()
+		g.GBW = uint32(1 + r.Intn(150))
+		g.GBH = uint32(1 + r.Intn(40))
+		g.TPGDON = n%2 == 1
+		g.EXTTEMPLATE = true
+		copy(g.GBAT[:], kTestExtNominalAT[:8])
+		copy(g.GBATEXT[:], kTestExtNominalAT[8:])
+		data := randomBytes(r, 4096)
+		want, wantRes := runGeneric(g, data, func(s *ProgressiveArithDecodeState) JBig2SegmentState {
+			return g.decodeTemplateUnopt(s, 0)
+		})
+		got, gotRes := runGeneric(g, data, g.decodeTemplate0Ext)
+		if gotRes != wantRes || !imageEqual(got, want) {
+			t.Fatalf("%dx%d: extended template differs from template 0", g.GBW, g.GBH)
+		}
+	}
+}
+
+func TestGenericExtTemplate(t *testing.T) {
+	r := rand.New(rand.NewSource(50))
+	tests := []struct {
+		name   string
+		at     [24]int8
+		tpgdon bool
+	}{
+		{"nominal", kTestExtNominalAT, false},
+		{"moved", [24]int8{-4, 0, 4, -1, -5, -2, 5, -3, 3, -1, -3, -1, 0, -3, -6, -1, 6, -2, -1, -4, 2, -4, -7, 0}, false},
+		{"far tpgdon", [24]int8{-12, 0, 16, -1, -20, -5, 0, -16, 7, -2, -7, -2, 1, -6, -1, -6, 9, -9, -9, -9, 0, -12, -2, 0}, true},
+	}
+	for _, tt := range tests {
+		img := randomImage(r, 97, 61, 25)
+		s := newTestStream(1)
+		s.segment(48, 1, nil, testPageInfo(97, 61, 0))
+		s.segment(38, 1, nil, testExtGenericRegion(img, tt.at, tt.tpgdon))
+		s.segment(49, 1, nil, nil)
+		got, err := Decode(bytes.NewReader(s.out))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !pageEqual(got, img) {
+			t.Errorf("%s: page differs", tt.name)
+		}
+	}
+}
+
+func TestGenericRegionHeaderAT(t *testing.T) {
+	at := make([]byte, 24)
+	for i := range at {
+		at[i] = byte(i + 1)
+	}
+	tests := []struct {
+		name  string
+		flags byte
+		n     int
+		ext   bool
+	}{
+		{"template 0", 0x00, 8, false},
+		{"template 0 extended", 0x10, 24, true},
+		{"template 1 ext bit", 0x12, 2, false},
+		{"template 3", 0x06, 2, false},
+		{"mmr", 0x11, 0, false},
+	}
+	for _, tt := range tests {
+		data := append(testRegionInfo(16, 4, 0, 0, ComposeOr), tt.flags)
+		data = append(data, at[:tt.n]...)
+		// 之后的字节属于编码数据, 不得作为AT像素读取
+		data = append(data, 0xEE, 0xEE)
+		d := NewDocument(data, nil, false, false)
+		g, _, res := d.parseGenericRegionHeader(NewSegment())
+		if res != ResultSuccess {
+			t.Fatalf("%s: result %v", tt.name, res)
+		}
+		if off := int(d.stream.GetOffset()); off != len(data)-2 {
+			t.Errorf("%s: read %d AT bytes, want %d", tt.name, off-18, tt.n)
+		}
+		if g.EXTTEMPLATE != tt.ext {
+			t.Errorf("%s: EXTTEMPLATE %v, want %v", tt.name, g.EXTTEMPLATE, tt.ext)
+		}
+		var want [24]int8
+		for i := 0; i < tt.n; i++ {
+			want[i] = int8(i + 1)
+		}
+		var got [24]int8
+		copy(got[:8], g.GBAT[:])
+		copy(got[8:], g.GBATEXT[:])
+		if got != want {
+			t.Errorf("%s: AT bytes %v, want %v", tt.name, got, want)
+		}
+
+		// AT字节不完整时解析失败
+		if tt.n > 0 {
+			d = NewDocument(data[:18+tt.n-1], nil, false, false)
+			if _, _, res := d.parseGenericRegionHeader(NewSegment()); res != ResultFailure {
+				t.Errorf("%s: truncated AT bytes accepted", tt.name)
+			}
+		}
+	}
+}
@@ -44,30 +44,130 @@ func (g *GRRDProc) Decode(arithDecoder *ArithDecoder, grContexts []ArithCtx) (*I
 		return NewImage(int32(g.GRW), int32(g.GRH)), nil
 	}
 	if !g.GRTEMPLATE {
-		if g.GRAT[0] == -1 && g.GRAT[1] == -1 && g.GRAT[2] == -1 && g.GRAT[3] == -1 &&
-			g.GRREFERENCEDX == 0 && int32(g.GRW) == g.GRREFERENCE.width {
+		if g.GRAT[0] == -1 && g.GRAT[1] == -1 && g.GRAT[2] == -1 && g.GRAT[3] == -1 {
 			return g.decodeTemplate0Opt(arithDecoder, grContexts)
 		}
 		return g.decodeTemplate0Unopt(arithDecoder, grContexts)
 	}
-	if g.GRREFERENCEDX == 0 && int32(g.GRW) == g.GRREFERENCE.width {
-		return g.decodeTemplate1Opt(arithDecoder, grContexts)
-	}
-	return g.decodeTemplate1Unopt(arithDecoder, grContexts)
+	return g.decodeTemplate1Opt(arithDecoder, grContexts)
 }
 
 // decodeTemplate0Opt 模板0优化解码
 // 入参: decoder 算术解码器, contexts 上下文
 // 返回: *Image 图像, error 错误信息
 func (g *GRRDProc) decodeTemplate0Opt(decoder *ArithDecoder, contexts []ArithCtx) (*Image, error) {
-	return g.decodeTemplate0Unopt(decoder, contexts)
+	return g.decodeOpt(decoder, contexts, false)
 }
 
 // decodeTemplate1Opt 模板1优化解码
 // 入参: decoder 算术解码器, contexts 上下文
 // 返回: *Image 图像, error 错误信息
 func (g *GRRDProc) decodeTemplate1Opt(decoder *ArithDecoder, contexts []ArithCtx) (*Image, error) {
-	return g.decodeTemplate1Unopt(decoder, contexts)
+	return g.decodeOpt(decoder, contexts, true)
+}
+
+// decodeOpt 按字节滑动窗口的细化解码
+// 参考图像的三行按偏移对齐到输出坐标后载入滚动寄存器, 输出行按字节写入
+// 入参: decoder 算术解码器, contexts 上下文, template1 是否为模板1
+// 返回: *Image 图像, error 错误信息
+func (g *GRRDProc) decodeOpt(decoder *ArithDecoder, contexts []ArithCtx, template1 bool) (*Image, error) {
+	grReg := NewImage(int32(g.GRW), int32(g.GRH))
+	if grReg == nil {
+		return nil, errors.New("failed to create image")
+	}
+	width := int(g.GRW)
+	stride := int(grReg.stride)
+	refLines := [3]*Image{}
+	for i := range refLines {
+		refLines[i] = NewImage(int32(width+16), 1)
+		defer refLines[i].release()
+	}
+	loadRef := func(line *Image, row int32) {
+		line.Fill(false)
+		blit(line, g.GRREFERENCE, int64(g.GRREFERENCEDX)+8, -int64(row), ComposeReplace)
+	}
+	refRow := -g.GRREFERENCEDY
+	loadRef(refLines[0], refRow-1)
+	loadRef(refLines[1], refRow)
+	loadRef(refLines[2], refRow+1)
+	ltpContext := uint32(0x0010)
+	if template1 {
+		ltpContext = 0x0008
+	}
+	ltp := 0
+	for h := 0; h < int(g.GRH); h++ {
+		if h > 0 {
+			refLines[0], refLines[1], refLines[2] = refLines[1], refLines[2], refLines[0]
+			loadRef(refLines[2], refRow+int32(h)+1)
+		}
+		if g.TPGRON {
+			if decoder.IsComplete() {
+				return nil, errors.New("decoder complete prematurely")
+			}
+			if decoder.Decode(&contexts[ltpContext]) != 0 {
+				ltp ^= 1
+			}
+		}
+		row := grReg.data[h*stride : (h+1)*stride]
+		var prev []byte
+		if h > 0 {
+			prev = grReg.data[(h-1)*stride : h*stride]
+		}
+		up, mid, down := refLines[0].data, refLines[1].data, refLines[2].data
+		regPrev := lineByte(prev, 0)<<8 | lineByte(prev, 1)
+		regUp := uint32(up[0])<<16 | uint32(up[1])<<8 | uint32(up[2])
+		regMid := uint32(mid[0])<<16 | uint32(mid[1])<<8 | uint32(mid[2])
+		regDown := uint32(down[0])<<16 | uint32(down[1])<<8 | uint32(down[2])
+		line2 := uint32(0)
+		for b := 0; b*8 < width; b++ {
+			if b > 0 {
+				regPrev = regPrev<<8 | lineByte(prev, b+1)
+				regUp = regUp<<8 | uint32(up[b+2])
+				regMid = regMid<<8 | uint32(mid[b+2])
+				regDown = regDown<<8 | uint32(down[b+2])
+			}
+			n := min(8, width-b*8)
+			cVal := byte(0)
+			for k := 0; k < n; k++ {
+				sh := 14 - uint(k)
+				winUp := (regUp >> sh) & 0x07
+				winMid := (regMid >> sh) & 0x07
+				winDown := (regDown >> sh) & 0x07
+				bVal := -1
+				if ltp == 1 && g.TPGRON {
+					if winUp == 0 && winMid == 0 && winDown == 0 {
+						bVal = 0
+					} else if winUp == 0x07 && winMid == 0x07 && winDown == 0x07 {
+						bVal = 1
+					}
+				}
+				if bVal < 0 {
+					var CONTEXT uint32
+					if template1 {
+						CONTEXT = winDown & 0x03
+						CONTEXT |= winMid << 2
+						CONTEXT |= ((winUp >> 1) & 0x01) << 5
+						CONTEXT |= line2 << 6
+						CONTEXT |= ((regPrev >> sh) & 0x07) << 7
+					} else {
+						CONTEXT = winDown
+						CONTEXT |= winMid << 3
+						CONTEXT |= winUp << 6
+						CONTEXT |= line2 << 9
+						CONTEXT |= ((regPrev >> sh) & 0x07) << 10
+					}
+					if decoder.IsComplete() {
+						return nil, errors.New("decoder complete prematurely")
+					}
+					bVal = decoder.Decode(&contexts[CONTEXT])
+				}
+				cVal |= byte(bVal) << (7 - uint(k))
+				line2 = uint32(bVal)
+			}
+			row[b] = cVal
+		}
+	}
+	return grReg, nil
 }
 
 // decodeTemplate0Unopt 模板0非优化解码
@@ -113,17 +213,19 @@ func (g *GRRDProc) decodeTemplate0Unopt(decoder *ArithDecoder, contexts []ArithC
 			}
 		} else {
 			for w := int32(0); w < int32(g.GRW); w++ {
-				bVal := g.GRREFERENCE.GetPixel(w, h)
+				refX := w - g.GRREFERENCEDX
+				refY := h - g.GRREFERENCEDY
+				bVal := g.GRREFERENCE.GetPixel(refX, refY)
 				needDecode := true
 				if g.TPGRON {
-					if bVal == g.GRREFERENCE.GetPixel(w-1, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w-1, h) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h) &&
-						bVal == g.GRREFERENCE.GetPixel(w-1, h+1) &&
-						bVal == g.GRREFERENCE.GetPixel(w, h+1) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h+1) {
+					if bVal == g.GRREFERENCE.GetPixel(refX-1, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX-1, refY) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY) &&
+						bVal == g.GRREFERENCE.GetPixel(refX-1, refY+1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX, refY+1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY+1) {
 						needDecode = false
 					}
 				}
@@ -165,100 +267,3 @@ func (g *GRRDProc) setPixel0(grReg *Image, lines []uint32, w, h int32, bVal int)
 	lines[3] = ((lines[3] << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY))) & 0x07
 	lines[4] = ((lines[4] << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY+1))) & 0x07
 }
-
-// decodeTemplate1Unopt 模板1非优化解码
-// 入参: decoder 算术解码器, contexts 上下文
-// 返回: *Image 图像, error 错误信息
-func (g *GRRDProc) decodeTemplate1Unopt(decoder *ArithDecoder, contexts []ArithCtx) (*Image, error) {
-	grReg := NewImage(int32(g.GRW), int32(g.GRH))
-	if grReg == nil {
-		return nil, errors.New("failed to create image")
-	}
-	grReg.Fill(false)
-	ltp := 0
-	for h := int32(0); h < int32(g.GRH); h++ {
-		if g.TPGRON {
-			if decoder.IsComplete() {
-				return nil, errors.New("decoder complete prematurely")
-			}
-			bit := decoder.Decode(&contexts[0x0008])
-			if bit != 0 {
-				ltp ^= 1
-			}
-		}
-		if ltp == 0 {
-			line1 := uint32(grReg.GetPixel(1, h-1))
-			line1 |= uint32(grReg.GetPixel(0, h-1)) << 1
-			line1 |= uint32(grReg.GetPixel(-1, h-1)) << 2
-			line2 := uint32(0)
-			line3 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY-1))
-			line4 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY))
-			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY)) << 1
-			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX-1, h-g.GRREFERENCEDY)) << 2
-			line5 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY+1))
-			line5 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY+1)) << 1
-			for w := int32(0); w < int32(g.GRW); w++ {
-				CONTEXT := line5
-				CONTEXT |= line4 << 2
-				CONTEXT |= line3 << 5
-				CONTEXT |= line2 << 6
-				CONTEXT |= line1 << 7
-				if decoder.IsComplete() {
-					return nil, errors.New("decoder complete prematurely")
-				}
-				bVal := decoder.Decode(&contexts[CONTEXT])
-				grReg.SetPixel(w, h, bVal)
-				line1 = ((line1 << 1) | uint32(grReg.GetPixel(w+2, h-1))) & 0x07
-				line2 = ((line2 << 1) | uint32(bVal)) & 0x01
-				line3 = ((line3 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY-1))) & 0x01
-				line4 = ((line4 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY))) & 0x07
-				line5 = ((line5 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY+1))) & 0x03
-			}
-		} else {
-			line1 := uint32(grReg.GetPixel(1, h-1))
-			line1 |= uint32(grReg.GetPixel(0, h-1)) << 1
-			line1 |= uint32(grReg.GetPixel(-1, h-1)) << 2
-			line2 := uint32(0)
-			line3 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY-1))
-			line4 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY))
-			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY)) << 1
-			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX-1, h-g.GRREFERENCEDY)) << 2
-			line5 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY+1))
-			line5 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY+1)) << 1
-			for w := int32(0); w < int32(g.GRW); w++ {
-				bVal := g.GRREFERENCE.GetPixel(w, h)
-				needDecode := true
-				if g.TPGRON {
-					if bVal == g.GRREFERENCE.GetPixel(w-1, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h-1) &&
-						bVal == g.GRREFERENCE.GetPixel(w-1, h) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h) &&
-						bVal == g.GRREFERENCE.GetPixel(w-1, h+1) &&
-						bVal == g.GRREFERENCE.GetPixel(w, h+1) &&
-						bVal == g.GRREFERENCE.GetPixel(w+1, h+1) {
-						needDecode = false
-					}
-				}
-				if needDecode {
-					CONTEXT := line5
-					CONTEXT |= line4 << 2
-					CONTEXT |= line3 << 5
-					CONTEXT |= line2 << 6
-					CONTEXT |= line1 << 7
-					if decoder.IsComplete() {
-						return nil, errors.New("decoder complete prematurely")
-					}
-					bVal = int(decoder.Decode(&contexts[CONTEXT]))
-				}
-				grReg.SetPixel(w, h, int(bVal))
-				line1 = ((line1 << 1) | uint32(grReg.GetPixel(w+2, h-1))) & 0x07
-				line2 = ((line2 << 1) | uint32(bVal)) & 0x01
-				line3 = ((line3 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY-1))) & 0x01
-				line4 = ((line4 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY))) & 0x07
-				line5 = ((line5 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY+1))) & 0x03
-			}
-		}
-	}
-	return grReg, nil
-}
@@ -0,0 +1,219 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// newTestGRRD 创建名义AT像素的细化解码过程
+// 入参: ref 参考图像, w h 大小, template1 是否模板1, dx dy 参考偏移, tpgron 是否典型预测
+// 返回: *GRRDProc 解码过程
+func newTestGRRD(ref *Image, w, h int32, template1 bool, dx, dy int32, tpgron bool) *GRRDProc {
+	g := NewGRRDProc()
+	g.GRW, g.GRH = uint32(w), uint32(h)
+	g.GRTEMPLATE = template1
+	g.TPGRON = tpgron
+	g.GRREFERENCE = ref
+	g.GRREFERENCEDX, g.GRREFERENCEDY = dx, dy
+	g.GRAT = [4]int8{-1, -1, -1, -1}
+	return g
+}
+
+func TestRefinementTypicalPredictionOffset(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for _, template1 := range []bool{false, true} {
+		for _, off := range [][2]int32{{0, 0}, {3, 0}, {0, 2}, {-2, 5}, {7, -3}} {
+			ref := randomImage(r, 60, 30, 8)
+			img := NewImage(60, 30)
+			img.Fill(false)
+			ref.ComposeTo(img, off[0], off[1], ComposeReplace)
+			for i := 0; i < 6; i++ {
+				img.SetPixel(r.Int31n(60), r.Int31n(30), r.Intn(2))
+			}
+			e := newTestEncoder()
+			e.encodeRefinement(make([]ArithCtx, 1<<13), img, ref, template1, off[0], off[1], true)
+			data := e.flush()
+			g := newTestGRRD(ref, 60, 30, template1, off[0], off[1], true)
+			got, err := g.Decode(NewArithDecoder(NewBitStream(data, 0)), make([]ArithCtx, 1<<13))
+			if err != nil {
+				t.Fatalf("template1 %v offset %v: %v", template1, off, err)
+			}
+			if !imageEqual(got, img) {
+				t.Errorf("template1 %v offset %v: image differs", template1, off)
+			}
+		}
+	}
+}
+
+func TestRefinementOptMatchesUnopt(t *testing.T) {
+	r := rand.New(rand.NewSource(12))
+	for _, template1 := range []bool{false, true} {
+		for _, tpgron := range []bool{false, true} {
+			t.Run(fmt.Sprintf("template1=%v/tpgron=%v", template1, tpgron), func(t *testing.T) {
+				for n := 0; n < 40; n++ {
+					w, h := 1+r.Int31n(120), 1+r.Int31n(30)
+					ref := randomImage(r, 1+r.Int31n(130), 1+r.Int31n(40), 10)
+					dx, dy := r.Int31n(11)-5, r.Int31n(11)-5
+					data := randomBytes(r, 2048)
+					g := newTestGRRD(ref, w, h, template1, dx, dy, tpgron)
+					unopt := g.decodeTemplate0Unopt
+					if template1 {
+						unopt = g.decodeTemplate1Unopt
+					}
+					want, wantErr := unopt(NewArithDecoder(NewBitStream(data, 0)), make([]ArithCtx, 1<<13))
+					got, gotErr := g.decodeOpt(NewArithDecoder(NewBitStream(data, 0)), make([]ArithCtx, 1<<13), template1)
+					if (gotErr != nil) != (wantErr != nil) {
+						t.Fatalf("%dx%d offset (%d, %d): error %v, want %v", w, h, dx, dy, gotErr, wantErr)
+					}
+					if wantErr == nil && !imageEqual(got, want) {
+						t.Fatalf("%dx%d offset (%d, %d): image differs from unoptimised decode", w, h, dx, dy)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRefinementDecode(b *testing.B) {
+	r := rand.New(rand.NewSource(13))
+	ref := randomImage(r, 512, 256, 400)
+	img := ref.Duplicate()
+	for i := 0; i < 2000; i++ {
+		img.SetPixel(r.Int31n(512), r.Int31n(256), r.Intn(2))
+	}
+	e := newTestEncoder()
+	e.encodeRefinement(make([]ArithCtx, 1<<13), img, ref, false, 1, 1, true)
+	data := e.flush()
+	g := newTestGRRD(ref, 512, 256, false, 1, 1, true)
+	contexts := make([]ArithCtx, 1<<13)
+	run := func(b *testing.B, decode func(*ArithDecoder, []ArithCtx) (*Image, error)) {
+		b.SetBytes(int64(len(img.Data())))
+		for n := 0; n < b.N; n++ {
+			clear(contexts)
+			if _, err := decode(NewArithDecoder(NewBitStream(data, 0)), contexts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("opt", func(b *testing.B) {
+		run(b, func(d *ArithDecoder, cx []ArithCtx) (*Image, error) { return g.decodeOpt(d, cx, false) })
+	})
+	b.Run("unopt", func(b *testing.B) {
+		run(b, g.decodeTemplate0Unopt)
+	})
+}
+
+// decodeTemplate1Unopt 模板1非优化解码, 作为按字节解码的参照
+// 入参: decoder 算术解码器, contexts 上下文
+// 返回: *Image 图像, error 错误信息
+func (g *GRRDProc) decodeTemplate1Unopt(decoder *ArithDecoder, contexts []ArithCtx) (*Image, error) {
+	grReg := NewImage(int32(g.GRW), int32(g.GRH))
+	if grReg == nil {
+		return nil, errors.New("failed to create image")
+	}
+	grReg.Fill(false)
+	ltp := 0
+	for h := int32(0); h < int32(g.GRH); h++ {
+		if g.TPGRON {
+			if decoder.IsComplete() {
+				return nil, errors.New("decoder complete prematurely")
+			}
+			bit := decoder.Decode(&contexts[0x0008])
+			if bit != 0 {
+				ltp ^= 1
+			}
+		}
+		if ltp == 0 {
+			line1 := uint32(grReg.GetPixel(1, h-1))
+			line1 |= uint32(grReg.GetPixel(0, h-1)) << 1
+			line1 |= uint32(grReg.GetPixel(-1, h-1)) << 2
+			line2 := uint32(0)
+			line3 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY-1))
+			line4 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY))
+			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY)) << 1
+			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX-1, h-g.GRREFERENCEDY)) << 2
+			line5 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY+1))
+			line5 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY+1)) << 1
+			for w := int32(0); w < int32(g.GRW); w++ {
+				CONTEXT := line5
+				CONTEXT |= line4 << 2
+				CONTEXT |= line3 << 5
+				CONTEXT |= line2 << 6
+				CONTEXT |= line1 << 7
+				if decoder.IsComplete() {
+					return nil, errors.New("decoder complete prematurely")
+				}
+				bVal := decoder.Decode(&contexts[CONTEXT])
+				grReg.SetPixel(w, h, bVal)
+				line1 = ((line1 << 1) | uint32(grReg.GetPixel(w+2, h-1))) & 0x07
+				line2 = ((line2 << 1) | uint32(bVal)) & 0x01
+				line3 = ((line3 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY-1))) & 0x01
+				line4 = ((line4 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY))) & 0x07
+				line5 = ((line5 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY+1))) & 0x03
+			}
+		} else {
+			line1 := uint32(grReg.GetPixel(1, h-1))
+			line1 |= uint32(grReg.GetPixel(0, h-1)) << 1
+			line1 |= uint32(grReg.GetPixel(-1, h-1)) << 2
+			line2 := uint32(0)
+			line3 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY-1))
+			line4 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY))
+			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY)) << 1
+			line4 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX-1, h-g.GRREFERENCEDY)) << 2
+			line5 := uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY+1))
+			line5 |= uint32(g.GRREFERENCE.GetPixel(-g.GRREFERENCEDX, h-g.GRREFERENCEDY+1)) << 1
+			for w := int32(0); w < int32(g.GRW); w++ {
+				refX := w - g.GRREFERENCEDX
+				refY := h - g.GRREFERENCEDY
+				bVal := g.GRREFERENCE.GetPixel(refX, refY)
+				needDecode := true
+				if g.TPGRON {
+					if bVal == g.GRREFERENCE.GetPixel(refX-1, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY-1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX-1, refY) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY) &&
+						bVal == g.GRREFERENCE.GetPixel(refX-1, refY+1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX, refY+1) &&
+						bVal == g.GRREFERENCE.GetPixel(refX+1, refY+1) {
+						needDecode = false
+					}
+				}
+				if needDecode {
+					CONTEXT := line5
+					CONTEXT |= line4 << 2
+					CONTEXT |= line3 << 5
+					CONTEXT |= line2 << 6
+					CONTEXT |= line1 << 7
+					if decoder.IsComplete() {
+						return nil, errors.New("decoder complete prematurely")
+					}
+					bVal = int(decoder.Decode(&contexts[CONTEXT]))
+				}
+				grReg.SetPixel(w, h, int(bVal))
+				line1 = ((line1 << 1) | uint32(grReg.GetPixel(w+2, h-1))) & 0x07
+				line2 = ((line2 << 1) | uint32(bVal)) & 0x01
+				line3 = ((line3 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+1, h-g.GRREFERENCEDY-1))) & 0x01
+				line4 = ((line4 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY))) & 0x07
+				line5 = ((line5 << 1) | uint32(g.GRREFERENCE.GetPixel(w-g.GRREFERENCEDX+2, h-g.GRREFERENCEDY+1))) & 0x03
+			}
+		}
+	}
+	return grReg, nil
+}
@@ -14,6 +14,14 @@
 
 package jbig2
 
+import (
+	"errors"
+	"sync"
+)
+
+// huffmanLookupBits 霍夫曼查找表的最大索引位数
+const huffmanLookupBits = 9
+
 // TableLine 霍夫曼表行定义
 type TableLine struct {
 	PrefLen  int32
@@ -29,15 +37,35 @@ type HuffmanTable struct {
 	RANGELEN []int32
 	RANGELOW []int32
 	Ok       bool
+	once     sync.Once
+	lookup   *huffmanLookup
 }
 
-// NewStandardTable 从标准表创建霍夫曼表
+var (
+	// standardTablesOnce 标准表构建控制
+	standardTablesOnce sync.Once
+	// standardTables 共享的标准表
+	standardTables []*HuffmanTable
+)
+
+// NewStandardTable 获取标准霍夫曼表
+// 标准表只构建一次并在所有解码器间共享, 调用方不得修改返回的表
 // 入参: idx 表索引
 // 返回: *HuffmanTable 霍夫曼表
 func NewStandardTable(idx int) *HuffmanTable {
-	ht := &HuffmanTable{}
-	ht.parseFromStandardTable(idx)
-	return ht
+	if idx < 1 || idx >= len(kHuffmanTables) {
+		return &HuffmanTable{}
+	}
+	standardTablesOnce.Do(func() {
+		standardTables = make([]*HuffmanTable, len(kHuffmanTables))
+		for i := 1; i < len(kHuffmanTables); i++ {
+			ht := &HuffmanTable{}
+			ht.parseFromStandardTable(i)
+			ht.compiled()
+			standardTables[i] = ht
+		}
+	})
+	return standardTables[idx]
 }
 
 // NewTableFromStream 从流创建霍夫曼表
@@ -45,7 +73,9 @@ func NewStandardTable(idx int) *HuffmanTable {
 // 返回: *HuffmanTable 霍夫曼表
 func NewTableFromStream(stream *BitStream) *HuffmanTable {
 	ht := &HuffmanTable{}
-	ht.parseFromCodedBuffer(stream)
+	if ht.parseFromCodedBuffer(stream) {
+		ht.compiled()
+	}
 	return ht
 }
 
@@ -96,39 +126,82 @@ func (h *HuffmanTable) parseFromStandardTable(idx int) bool {
 // 入参: stream 位流
 // 返回: bool 是否成功
 func (h *HuffmanTable) parseFromCodedBuffer(stream *BitStream) bool {
-	var err error
-	var val uint32
-	val, err = stream.ReadNBits(1)
+	flags, err := stream.Read1Byte()
 	if err != nil {
 		return false
 	}
-	h.HTOOB = val != 0
-	val, err = stream.ReadNBits(3)
+	h.HTOOB = (flags & 0x01) != 0
+	HTPS := uint32((flags>>1)&0x07) + 1
+	HTRS := uint32((flags>>4)&0x07) + 1
+	val, err := stream.ReadInteger()
 	if err != nil {
 		return false
 	}
-	HTPS := val + 1
-	val, err = stream.ReadNBits(4)
-	if err != nil {
+	htLow := int32(val)
+	if val, err = stream.ReadInteger(); err != nil {
 		return false
 	}
-	HTRS := val + 1
-	val, err = stream.ReadInteger()
-	if err != nil {
+	htHigh := int32(val)
+	if htLow > htHigh {
 		return false
 	}
-	htLow := int32(val)
-	val, err = stream.ReadInteger()
+	h.CODES = make([]HuffmanCode, 0)
+	curRangeLow := int64(htLow)
+	for curRangeLow < int64(htHigh) {
+		prefLen, err := stream.ReadNBits(HTPS)
+		if err != nil {
+			return false
+		}
+		rangeLen, err := stream.ReadNBits(HTRS)
+		if err != nil || rangeLen >= 32 {
+			return false
+		}
+		h.CODES = append(h.CODES, HuffmanCode{Codelen: int32(prefLen), Val1: int32(rangeLen), Val2: int32(curRangeLow)})
+		curRangeLow += int64(1) << rangeLen
+	}
+	h.NTEMP = uint32(len(h.CODES))
+	prefLen, err := stream.ReadNBits(HTPS)
 	if err != nil {
 		return false
 	}
-	htHigh := int32(val)
-	h.CODES = make([]HuffmanCode, 0)
-	_ = HTPS
-	_ = HTRS
-	_ = htLow
-	_ = htHigh
-	return false
+	h.CODES = append(h.CODES, HuffmanCode{Codelen: int32(prefLen), Val1: 32, Val2: htLow - 1})
+	if prefLen, err = stream.ReadNBits(HTPS); err != nil {
+		return false
+	}
+	h.CODES = append(h.CODES, HuffmanCode{Codelen: int32(prefLen), Val1: 32, Val2: htHigh})
+	if h.HTOOB {
+		if prefLen, err = stream.ReadNBits(HTPS); err != nil {
+			return false
+		}
+		h.CODES = append(h.CODES, HuffmanCode{Codelen: int32(prefLen), Val1: -1})
+	}
+	h.extendBuffers(false)
+	if err := HuffmanAssignCode(h.CODES); err != nil {
+		return false
+	}
+	h.Ok = true
+	return true
+}
+
+// compiled 获取编译后的查找表, 首次调用时编译
+// 返回: *huffmanLookup 查找表
+func (h *HuffmanTable) compiled() *huffmanLookup {
+	h.once.Do(func() {
+		h.lookup = compileHuffmanCodes(h.CODES)
+	})
+	return h.lookup
+}
+
+// isLowerRange 判断表行是否为下界范围行
+// 下界范围行位于上界范围行与越界行之前, 其值为RANGELOW减去偏移
+// 入参: i 表行索引
+// 返回: bool 是否为下界范围行
+func (h *HuffmanTable) isLowerRange(i int) bool {
+	lower := len(h.CODES) - 2
+	if h.HTOOB {
+		lower--
+	}
+	return i == lower && h.RANGELEN[i] == 32
 }
 
 // extendBuffers 扩展内部缓冲区
@@ -158,41 +231,121 @@ func NewHuffmanDecoder(stream *BitStream) *HuffmanDecoder {
 // 入参: table 霍夫曼表, result 结果指针
 // 返回: int 状态码
 func (h *HuffmanDecoder) DecodeAValue(table *HuffmanTable, result *int32) int {
-	var val int32
-	var nBits int
-	for {
-		if nBits > 32 {
-			return -1
-		}
-		bit, err := h.stream.Read1Bit()
+	i, err := table.compiled().decode(h.stream)
+	if err != nil {
+		return -1
+	}
+	if table.HTOOB && i == len(table.CODES)-1 {
+		return JBig2OOB
+	}
+	rlen := table.RANGELEN[i]
+	rlow := table.RANGELOW[i]
+	if rlen < 0 {
+		return JBig2OOB
+	}
+	if rlen > 0 {
+		offset, err := h.stream.ReadNBits(uint32(rlen))
 		if err != nil {
 			return -1
 		}
-		val = (val << 1) | int32(bit)
-		nBits++
-		for i := 0; i < len(table.CODES); i++ {
-			if table.CODES[i].Codelen == int32(nBits) && table.CODES[i].Code == val {
-				if table.HTOOB && i == len(table.CODES)-1 {
-					return JBig2OOB
-				}
-				rlen := table.RANGELEN[i]
-				rlow := table.RANGELOW[i]
-				if rlen < 0 {
-					return JBig2OOB
-				}
-				if rlen > 0 {
-					offset, err := h.stream.ReadNBits(uint32(rlen))
-					if err != nil {
-						return -1
-					}
-					*result = rlow + int32(offset)
-				} else {
-					*result = rlow
+		if table.isLowerRange(i) {
+			*result = rlow - int32(offset)
+		} else {
+			*result = rlow + int32(offset)
+		}
+	} else {
+		*result = rlow
+	}
+	return 0
+}
+
+// huffmanEntry 查找表项
+type huffmanEntry struct {
+	length uint8
+	index  int32
+}
+
+// huffmanLookup 编译后的霍夫曼查找表
+// 不超过索引位数的编码直接查表, 更长的编码按前缀长度逐位回退查找
+type huffmanLookup struct {
+	bits    uint32
+	maxLen  int32
+	entries []huffmanEntry
+	codes   map[uint64]int
+}
+
+// compileHuffmanCodes 编译霍夫曼编码
+// 同一前缀存在多个编码时, 与逐位线性查找一致, 优先取长度最短且索引最小的编码
+// 入参: codes 霍夫曼编码列表
+// 返回: *huffmanLookup 查找表
+func compileHuffmanCodes(codes []HuffmanCode) *huffmanLookup {
+	l := &huffmanLookup{codes: make(map[uint64]int, len(codes))}
+	for i, c := range codes {
+		if c.Codelen <= 0 || c.Codelen > 32 || c.Code < 0 || int64(c.Code) >= int64(1)<<c.Codelen {
+			continue
+		}
+		key := huffmanKey(c.Codelen, uint32(c.Code))
+		if _, ok := l.codes[key]; !ok {
+			l.codes[key] = i
+		}
+		l.maxLen = max(l.maxLen, c.Codelen)
+	}
+	l.bits = uint32(min(l.maxLen, huffmanLookupBits))
+	l.entries = make([]huffmanEntry, 1<<l.bits)
+	for length := int32(1); length <= int32(l.bits); length++ {
+		for i, c := range codes {
+			if c.Codelen != length || l.codes[huffmanKey(length, uint32(c.Code))] != i {
+				continue
+			}
+			if c.Code < 0 || int64(c.Code) >= int64(1)<<length {
+				continue
+			}
+			shift := l.bits - uint32(length)
+			first := uint32(c.Code) << shift
+			for v := first; v < first+(1<<shift); v++ {
+				if l.entries[v].length == 0 {
+					l.entries[v] = huffmanEntry{length: uint8(length), index: int32(i)}
 				}
-				return 0
 			}
 		}
 	}
+	return l
+}
+
+// huffmanKey 生成编码键
+// 入参: length 编码长度, code 编码
+// 返回: uint64 键
+func huffmanKey(length int32, code uint32) uint64 {
+	return uint64(length)<<32 | uint64(code)
+}
+
+// decode 从位流解码一个编码
+// 入参: stream 位流
+// 返回: int 编码索引, error 错误信息
+func (l *huffmanLookup) decode(stream *BitStream) (int, error) {
+	var val uint32
+	nBits := int32(0)
+	if peek, avail := stream.PeekNBits(l.bits); avail == l.bits && l.bits > 0 {
+		if e := l.entries[peek]; e.length != 0 {
+			stream.SkipBits(uint32(e.length))
+			return int(e.index), nil
+		}
+		stream.SkipBits(l.bits)
+		val = peek
+		nBits = int32(l.bits)
+	}
+	for nBits < l.maxLen {
+		bit, err := stream.Read1Bit()
+		if err != nil {
+			return 0, err
+		}
+		val = (val << 1) | bit
+		nBits++
+		if i, ok := l.codes[huffmanKey(nBits, val)]; ok {
+			return i, nil
+		}
+	}
+	return 0, errors.New("no matching huffman code")
 }
 
 // HuffmanAssignCode 为霍夫曼表分配编码
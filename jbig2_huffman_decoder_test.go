@@ -0,0 +1,197 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// bitsToBytes 将01字符串转换为字节, 忽略空格, 末尾补0
+// 入参: s 01字符串
+// 返回: []byte 数据
+func bitsToBytes(s string) []byte {
+	s = strings.ReplaceAll(s, " ", "")
+	out := make([]byte, (len(s)+7)/8)
+	for i, c := range s {
+		if c == '1' {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+// decodeHuffmanBits 以霍夫曼表解码01字符串中的一个数值
+// 入参: table 霍夫曼表, bits 01字符串
+// 返回: int32 数值, int 状态码, uint32 消耗的位数
+func decodeHuffmanBits(table *HuffmanTable, bits string) (int32, int, uint32) {
+	stream := NewBitStream(bitsToBytes(bits), 0)
+	var v int32
+	res := NewHuffmanDecoder(stream).DecodeAValue(table, &v)
+	return v, res, stream.GetBitPos()
+}
+
+func TestStandardTableLines(t *testing.T) {
+	for idx := 1; idx <= 15; idx++ {
+		table := NewStandardTable(idx)
+		if !table.IsOK() {
+			t.Fatalf("table B.%d not ok", idx)
+		}
+		for i, code := range table.CODES {
+			bits := fmt.Sprintf("%0*b", code.Codelen, code.Code)
+			if table.HTOOB && i == len(table.CODES)-1 {
+				if _, res, _ := decodeHuffmanBits(table, bits); res != JBig2OOB {
+					t.Errorf("B.%d line %d: got %d, want OOB", idx, i, res)
+				}
+				continue
+			}
+			rangeLen := table.RANGELEN[i]
+			// 两个32位范围行中前一个为下界范围行
+			lower := false
+			if rangeLen == 32 {
+				for _, c := range table.CODES[i+1:] {
+					if c.Val1 == 32 {
+						lower = true
+					}
+				}
+			}
+			offsets := []uint32{0, 1<<uint(rangeLen) - 1}
+			if rangeLen == 32 {
+				offsets = []uint32{0, 1, 12345}
+			}
+			for _, off := range offsets {
+				want := table.RANGELOW[i] + int32(off)
+				if lower {
+					want = table.RANGELOW[i] - int32(off)
+				}
+				in := bits
+				if rangeLen > 0 {
+					in += fmt.Sprintf("%0*b", rangeLen, off)
+				}
+				got, res, used := decodeHuffmanBits(table, in)
+				if res != 0 || got != want || used != uint32(len(in)) {
+					t.Errorf("B.%d line %d offset %d: got %d (res %d, %d bits), want %d (%d bits)",
+						idx, i, off, got, res, used, want, len(in))
+				}
+			}
+		}
+	}
+}
+
+func TestStandardTableCodes(t *testing.T) {
+	tests := []struct {
+		table int
+		bits  string
+		want  int32
+		oob   bool
+	}{
+		{1, "0 0000", 0, false},
+		{1, "0 1111", 15, false},
+		{1, "10 00000000", 16, false},
+		{1, "110 0000000000000000", 272, false},
+		{1, "111 00000000000000000000000000000001", 65809, false},
+		{2, "0", 0, false},
+		{2, "1110 111", 10, false},
+		{2, "111110 00000000000000000000000000000000", 75, false},
+		{2, "111111", 0, true},
+		{3, "11111110 11111111", -1, false},
+		{3, "11111110 00000000", -256, false},
+		{3, "11111111 00000000000000000000000000000000", -257, false},
+		{3, "11111111 00000000000000000000001111101000", -1257, false},
+		{3, "1111110 00000000000000000000000000000000", 75, false},
+		{3, "111110", 0, true},
+		{15, "0", 0, false},
+		{15, "100", -1, false},
+		{15, "1111110 00000000000000000000000000000101", -30, false},
+		{15, "1111111 00000000000000000000000000000101", 30, false},
+	}
+	for _, tt := range tests {
+		got, res, _ := decodeHuffmanBits(NewStandardTable(tt.table), tt.bits)
+		if tt.oob {
+			if res != JBig2OOB {
+				t.Errorf("B.%d %q: got %d, want OOB", tt.table, tt.bits, res)
+			}
+			continue
+		}
+		if res != 0 || got != tt.want {
+			t.Errorf("B.%d %q: got %d (res %d), want %d", tt.table, tt.bits, got, res, tt.want)
+		}
+	}
+}
+
+func TestUserTable(t *testing.T) {
+	// T.88 B.2 编码的表: HTOOB=1, HTPS=3, HTRS=3, HTLOW=-4, HTHIGH=12
+	// 表行(PREFLEN, RANGELEN)依次为(2, 2), (2, 3), (3, 2), 下界、上界与越界行的PREFLEN为4, 4, 3
+	fixture := []byte{
+		0x25,
+		0xFF, 0xFF, 0xFF, 0xFC,
+		0x00, 0x00, 0x00, 0x0C,
+		0x49, 0x36, 0xA4, 0x60,
+	}
+	stream := NewBitStream(fixture, 0)
+	table := NewTableFromStream(stream)
+	if !table.IsOK() {
+		t.Fatal("user table not ok")
+	}
+	if !table.IsHTOOB() || table.Size() != 6 {
+		t.Fatalf("got HTOOB %v size %d, want true 6", table.IsHTOOB(), table.Size())
+	}
+	tests := []struct {
+		bits string
+		want int32
+		oob  bool
+	}{
+		{"00 00", -4, false},
+		{"00 11", -1, false},
+		{"01 000", 0, false},
+		{"01 101", 5, false},
+		{"100 11", 11, false},
+		{"101", 0, true},
+		{"1100 00000000000000000000000000000000", -5, false},
+		{"1100 00000000000000000000000001011111", -100, false},
+		{"1101 00000000000000000000000000000000", 12, false},
+		{"1101 00000000000000000000000000000111", 19, false},
+	}
+	for _, tt := range tests {
+		got, res, _ := decodeHuffmanBits(table, tt.bits)
+		if tt.oob {
+			if res != JBig2OOB {
+				t.Errorf("%q: got %d, want OOB", tt.bits, res)
+			}
+			continue
+		}
+		if res != 0 || got != tt.want {
+			t.Errorf("%q: got %d (res %d), want %d", tt.bits, got, res, tt.want)
+		}
+	}
+}
+
+func TestUserTableInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", []byte{0x25, 0xFF, 0xFF}},
+		{"low above high", []byte{0x25, 0, 0, 0, 12, 0xFF, 0xFF, 0xFF, 0xFC, 0x49, 0x36, 0xA4, 0x60}},
+		{"missing lines", []byte{0x25, 0xFF, 0xFF, 0xFF, 0xFC, 0, 0, 0, 12, 0x49}},
+	}
+	for _, tt := range tests {
+		if NewTableFromStream(NewBitStream(tt.data, 0)).IsOK() {
+			t.Errorf("%s: table accepted", tt.name)
+		}
+	}
+}
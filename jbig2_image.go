@@ -14,6 +14,8 @@
 
 package jbig2
 
+import "encoding/binary"
+
 // Image 图像结构体
 type Image struct {
 	width  int32
@@ -34,7 +36,7 @@ func NewImage(width, height int32) *Image {
 		return nil
 	}
 	size := stride * height
-	data := make([]byte, size)
+	data := getImageBuffer(int(size))
 	return &Image{
 		width:  width,
 		height: height,
@@ -120,36 +122,89 @@ func (i *Image) ComposeTo(dst *Image, x, y int32, op ComposeOp) {
 	if i == nil || dst == nil {
 		return
 	}
-	for h := int32(0); h < i.height; h++ {
-		for w := int32(0); w < i.width; w++ {
-			dstX := x + w
-			dstY := y + h
-			srcBit := i.GetPixel(w, h)
-			dstBit := dst.GetPixel(dstX, dstY)
-			var resBit int
+	blit(dst, i, int64(x), int64(y), op)
+}
+
+// blit 按64位字将源图像组合到目标图像
+// 裁剪只在调用开始时进行一次, 行内按目标字节对齐分块, 每块最多56位
+// 入参: dst 目标图像, src 源图像, x 轴坐标, y 轴坐标, op 组合操作
+func blit(dst, src *Image, x, y int64, op ComposeOp) {
+	if op < ComposeOr || op > ComposeReplace {
+		return
+	}
+	sx0, sy0 := int64(0), int64(0)
+	if x < 0 {
+		sx0 = -x
+	}
+	if y < 0 {
+		sy0 = -y
+	}
+	sx1 := min(int64(src.width), int64(dst.width)-x)
+	sy1 := min(int64(src.height), int64(dst.height)-y)
+	if sx0 >= sx1 || sy0 >= sy1 {
+		return
+	}
+	n := int(sx1 - sx0)
+	dx := int(x + sx0)
+	for sy := sy0; sy < sy1; sy++ {
+		srcRow := src.data[sy*int64(src.stride) : (sy+1)*int64(src.stride)]
+		dy := y + sy
+		dstRow := dst.data[dy*int64(dst.stride) : (dy+1)*int64(dst.stride)]
+		for pos := 0; pos < n; {
+			k := min(n-pos, 56)
+			sb := int(sx0) + pos
+			s := load64(srcRow, sb>>3) << uint(sb&7)
+			db := dx + pos
+			off := uint(db & 7)
+			s >>= off
+			mask := (^uint64(0) << uint(64-k)) >> off
+			w := load64(dstRow, db>>3)
+			var r uint64
 			switch op {
 			case ComposeOr:
-				resBit = dstBit | srcBit
+				r = w | s
 			case ComposeAnd:
-				resBit = dstBit & srcBit
+				r = w & s
 			case ComposeXor:
-				resBit = dstBit ^ srcBit
+				r = w ^ s
 			case ComposeXnor:
-				if dstBit == srcBit {
-					resBit = 1
-				} else {
-					resBit = 0
-				}
-			case ComposeReplace:
-				resBit = srcBit
+				r = ^(w ^ s)
 			default:
-				resBit = dstBit
+				r = s
 			}
-			dst.SetPixel(dstX, dstY, resBit)
+			store64(dstRow, db>>3, (w&^mask)|(r&mask))
+			pos += k
 		}
 	}
 }
 
+// load64 以大端序读取从指定字节开始的64位, 越界部分补0
+// 入参: row 行数据, idx 字节索引
+// 返回: uint64 数据
+func load64(row []byte, idx int) uint64 {
+	if idx+8 <= len(row) {
+		return binary.BigEndian.Uint64(row[idx:])
+	}
+	var v uint64
+	j := 0
+	for ; idx+j < len(row); j++ {
+		v = v<<8 | uint64(row[idx+j])
+	}
+	return v << uint(64-8*j)
+}
+
+// store64 以大端序写入从指定字节开始的64位, 越界部分丢弃
+// 入参: row 行数据, idx 字节索引, v 数据
+func store64(row []byte, idx int, v uint64) {
+	if idx+8 <= len(row) {
+		binary.BigEndian.PutUint64(row[idx:], v)
+		return
+	}
+	for j := 0; j < 8 && idx+j < len(row); j++ {
+		row[idx+j] = byte(v >> uint(56-8*j))
+	}
+}
+
 // ComposeFrom 从源图像组合到当前图像
 // 入参: x 轴坐标, y 轴坐标, src 源图像, op 组合操作
 func (i *Image) ComposeFrom(x, y int32, src *Image, op ComposeOp) {
@@ -169,12 +224,7 @@ func (i *Image) SubImage(x, y, w, h int32) *Image {
 	if sub == nil {
 		return nil
 	}
-	sub.Fill(false)
-	for r := int32(0); r < h; r++ {
-		for c := int32(0); c < w; c++ {
-			sub.SetPixel(c, r, i.GetPixel(x+c, y+r))
-		}
-	}
+	blit(sub, i, -int64(x), -int64(y), ComposeReplace)
 	return sub
 }
 
@@ -186,8 +236,9 @@ func (i *Image) Expand(height int32, defaultPixel bool) {
 	}
 	newStride := i.stride
 	newHeight := height
-	newData := make([]byte, newStride*newHeight)
+	newData := getImageBuffer(int(newStride * newHeight))
 	copy(newData, i.data)
+	putImageBuffer(i.data)
 	start := i.stride * i.height
 	fill := byte(0x00)
 	if defaultPixel {
@@ -0,0 +1,190 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// composePixels 逐像素组合图像, 作为按字组合的参照
+// 入参: dst 目标图像, x y 坐标, src 源图像, op 组合操作
+func composePixels(dst *Image, x, y int32, src *Image, op ComposeOp) {
+	if op < ComposeOr || op > ComposeReplace {
+		return
+	}
+	for h := int32(0); h < src.Height(); h++ {
+		for w := int32(0); w < src.Width(); w++ {
+			dx, dy := int64(x)+int64(w), int64(y)+int64(h)
+			if dx < 0 || dy < 0 || dx >= int64(dst.Width()) || dy >= int64(dst.Height()) {
+				continue
+			}
+			s, d := src.GetPixel(w, h), dst.GetPixel(int32(dx), int32(dy))
+			var v int
+			switch op {
+			case ComposeOr:
+				v = d | s
+			case ComposeAnd:
+				v = d & s
+			case ComposeXor:
+				v = d ^ s
+			case ComposeXnor:
+				v = 1 ^ d ^ s
+			default:
+				v = s
+			}
+			dst.SetPixel(int32(dx), int32(dy), v)
+		}
+	}
+}
+
+// noiseImage 生成随机内容的图像
+// 入参: r 随机数源, w h 大小
+// 返回: *Image 图像
+func noiseImage(r *rand.Rand, w, h int32) *Image {
+	img := NewImage(w, h)
+	r.Read(img.Data())
+	return img
+}
+
+func TestComposeFrom(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	tests := []struct {
+		name       string
+		srcW, srcH int32
+		x, y       int32
+		dstW, dstH int32
+		ops        []ComposeOp
+	}{
+		{"aligned", 64, 9, 0, 0, 130, 20, nil},
+		{"byte aligned", 70, 9, 16, 3, 130, 20, nil},
+		{"unaligned", 70, 9, 3, 5, 130, 20, nil},
+		{"unaligned wide", 200, 4, 13, 1, 260, 8, nil},
+		{"narrow", 5, 7, 61, 2, 70, 12, nil},
+		{"left clip", 70, 9, -5, 0, 130, 20, nil},
+		{"top clip", 20, 9, 9, -4, 40, 20, nil},
+		{"right clip", 70, 9, 100, 15, 130, 20, nil},
+		{"outside", 10, 10, 200, 0, 130, 20, nil},
+		{"far outside", 10, 10, math.MaxInt32, math.MinInt32, 130, 20, nil},
+		{"invalid op", 70, 9, 3, 5, 130, 20, []ComposeOp{5, 7}},
+	}
+	for _, tt := range tests {
+		ops := tt.ops
+		if ops == nil {
+			ops = []ComposeOp{ComposeOr, ComposeAnd, ComposeXor, ComposeXnor, ComposeReplace}
+		}
+		for _, op := range ops {
+			t.Run(fmt.Sprintf("%s/op%d", tt.name, op), func(t *testing.T) {
+				src := noiseImage(r, tt.srcW, tt.srcH)
+				dst := noiseImage(r, tt.dstW, tt.dstH)
+				want := dst.Duplicate()
+				composePixels(want, tt.x, tt.y, src, op)
+				dst.ComposeFrom(tt.x, tt.y, src, op)
+				if !imageEqual(dst, want) {
+					t.Error("composed image differs from per-pixel reference")
+				}
+			})
+		}
+	}
+}
+
+func TestComposeFromRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for n := 0; n < 5000; n++ {
+		src := noiseImage(r, 1+r.Int31n(150), 1+r.Int31n(12))
+		dst := noiseImage(r, 1+r.Int31n(200), 1+r.Int31n(20))
+		x, y := r.Int31n(260)-80, r.Int31n(40)-15
+		op := ComposeOp(r.Intn(6))
+		want := dst.Duplicate()
+		composePixels(want, x, y, src, op)
+		dst.ComposeFrom(x, y, src, op)
+		if !bytes.Equal(dst.Data(), want.Data()) {
+			t.Fatalf("src %dx%d dst %dx%d at (%d, %d) op %d differs",
+				src.Width(), src.Height(), dst.Width(), dst.Height(), x, y, op)
+		}
+	}
+}
+
+func TestSubImage(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	img := noiseImage(r, 100, 30)
+	tests := []struct {
+		name       string
+		x, y, w, h int32
+	}{
+		{"aligned", 0, 0, 64, 10},
+		{"byte aligned", 8, 4, 50, 10},
+		{"unaligned", 3, 2, 77, 13},
+		{"right edge", 90, 25, 20, 10},
+		{"negative", -9, -3, 30, 10},
+		{"outside", 120, 40, 10, 10},
+		{"empty", 0, 0, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := img.SubImage(tt.x, tt.y, tt.w, tt.h)
+			if tt.w <= 0 || tt.h <= 0 {
+				if sub != nil {
+					t.Fatal("expected nil for empty size")
+				}
+				return
+			}
+			for y := int32(0); y < tt.h; y++ {
+				for x := int32(0); x < tt.w; x++ {
+					if sub.GetPixel(x, y) != img.GetPixel(tt.x+x, tt.y+y) {
+						t.Fatalf("pixel (%d, %d) differs", x, y)
+					}
+				}
+			}
+			if tail := sub.Stride()*8 - tt.w; tail > 0 {
+				for y := int32(0); y < tt.h; y++ {
+					if sub.Data()[(y+1)*sub.Stride()-1]&byte(1<<uint(tail)-1) != 0 {
+						t.Fatalf("padding bits set in row %d", y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkComposeFrom(b *testing.B) {
+	r := rand.New(rand.NewSource(8))
+	src := noiseImage(r, 256, 64)
+	dst := noiseImage(r, 2048, 256)
+	ops := []struct {
+		name string
+		op   ComposeOp
+	}{
+		{"or", ComposeOr}, {"and", ComposeAnd}, {"xor", ComposeXor},
+		{"xnor", ComposeXnor}, {"replace", ComposeReplace},
+	}
+	for _, shift := range []int32{0, 3} {
+		for _, o := range ops {
+			b.Run(fmt.Sprintf("shift%d/%s/word", shift, o.name), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					dst.ComposeFrom(64+shift, 16, src, o.op)
+				}
+			})
+			b.Run(fmt.Sprintf("shift%d/%s/pixel", shift, o.name), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					composePixels(dst, 64+shift, 16, src, o.op)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DecodePagesParallel 并行解码指定页面
+// 全局段与页面0的段先顺序解码并冻结, 之后各页面在独立的goroutine中解码
+// 入参: ctx 上下文, pages 页面编号列表(从1开始, 为空时解码全部页面), workers 并发数(<=0时使用GOMAXPROCS)
+// 返回: []image.Image 与pages顺序一致的图像列表, error 错误信息
+func (d *Decoder) DecodePagesParallel(ctx context.Context, pages []int, workers int) ([]image.Image, error) {
+	if d.doc == nil {
+		return nil, errors.New("decoder not initialized")
+	}
+	segments, err := d.doc.scanSegmentHeaders()
+	if err != nil {
+		return d.decodePagesSerial(ctx, pages)
+	}
+	shared := d.doc.newSibling(d.doc.globalContext)
+	pageSegments := make(map[uint32][]*Segment)
+	var pageOrder []uint32
+	for _, seg := range segments {
+		if seg.PageAssociation == 0 {
+			if err := shared.parseIndexedSegment(seg); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, ok := pageSegments[seg.PageAssociation]; !ok {
+			pageOrder = append(pageOrder, seg.PageAssociation)
+		}
+		pageSegments[seg.PageAssociation] = append(pageSegments[seg.PageAssociation], seg)
+	}
+	if len(pages) == 0 {
+		for _, num := range pageOrder {
+			pages = append(pages, int(num))
+		}
+	}
+	for _, num := range pages {
+		if num <= 0 || len(pageSegments[uint32(num)]) == 0 {
+			return nil, fmt.Errorf("page %d not found", num)
+		}
+	}
+	images := make([]image.Image, len(pages))
+	err = runParallel(ctx, len(pages), workers, func(i int) error {
+		doc := d.doc.newSibling(shared)
+		for _, tmpl := range pageSegments[uint32(pages[i])] {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			seg := *tmpl
+			ret := doc.parseIndexedSegmentResult(&seg)
+			if ret == ResultFailure {
+				if doc.profileErr != nil {
+					return fmt.Errorf("page %d: %w", pages[i], doc.profileErr)
+				}
+				if doc.extErr != nil {
+					return fmt.Errorf("page %d: %w", pages[i], doc.extErr)
+				}
+				return fmt.Errorf("page %d: decoding failed", pages[i])
+			}
+			if ret == ResultPageCompleted || ret == ResultEndReached {
+				break
+			}
+		}
+		if !doc.hasPage() {
+			return fmt.Errorf("page %d: no page information", pages[i])
+		}
+		if doc.tiledPage != nil && doc.colourUsed {
+			return fmt.Errorf("page %d: %w", pages[i], ErrColourUnsupported)
+		}
+		images[i] = doc.pageImage()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// DecodeStreamsParallel 并行解码共享全局段的多个页面流
+// 入参: ctx 上下文, globals 已解析的全局段(可为nil), streams 页面流数据, workers 并发数(<=0时使用GOMAXPROCS)
+// 返回: []image.Image 与streams顺序一致的图像列表, error 错误信息
+func DecodeStreamsParallel(ctx context.Context, globals *Globals, streams [][]byte, workers int) ([]image.Image, error) {
+	images := make([]image.Image, len(streams))
+	err := runParallel(ctx, len(streams), workers, func(i int) error {
+		dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(streams[i]), globals)
+		if err != nil {
+			return fmt.Errorf("stream %d: %w", i, err)
+		}
+		img, err := dec.Decode()
+		if err != nil {
+			return fmt.Errorf("stream %d: %w", i, err)
+		}
+		images[i] = img
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// decodePagesSerial 无法建立段索引时顺序解码指定页面
+// 入参: ctx 上下文, pages 页面编号列表
+// 返回: []image.Image 图像列表, error 错误信息
+func (d *Decoder) decodePagesSerial(ctx context.Context, pages []int) ([]image.Image, error) {
+	dec := &Decoder{doc: d.doc.newSibling(d.doc.globalContext)}
+	var all []image.Image
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		img, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, img)
+	}
+	if len(pages) == 0 {
+		return all, nil
+	}
+	images := make([]image.Image, len(pages))
+	for i, num := range pages {
+		if num <= 0 || num > len(all) {
+			return nil, fmt.Errorf("page %d not found", num)
+		}
+		images[i] = all[num-1]
+	}
+	return images, nil
+}
+
+// runParallel 使用固定数量的goroutine执行任务
+// 入参: ctx 上下文, n 任务数, workers 并发数, fn 任务函数
+// 返回: error 第一个错误
+func runParallel(ctx context.Context, n, workers int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// newSibling 创建共享数据与配置的新文档对象
+// 入参: globalContext 全局上下文
+// 返回: *Document 文档对象
+func (d *Document) newSibling(globalContext *Document) *Document {
+	stream := NewBitStream(d.stream.data, d.stream.key)
+	stream.SetLittleEndian(d.stream.littleEndian)
+	return &Document{
+		stream:        stream,
+		globalContext: globalContext,
+		randomAccess:  d.randomAccess,
+		tileSize:      d.tileSize,
+		opts:          d.opts,
+		Grouped:       d.Grouped,
+		OrgMode:       d.OrgMode,
+	}
+}
+
+// scanSegmentHeaders 扫描全部段头并确定各段数据偏移
+// 返回: []*Segment 段头列表, error 错误信息
+func (d *Document) scanSegmentHeaders() ([]*Segment, error) {
+	if d.randomAccess && d.OrgMode != 1 {
+		return nil, errors.New("segment headers carry no page association")
+	}
+	scan := d.newSibling(nil)
+	var segments []*Segment
+	for scan.stream.GetByteLeft() > 0 {
+		seg := NewSegment()
+		if scan.ParseSegmentHeader(seg) != ResultSuccess {
+			break
+		}
+		segments = append(segments, seg)
+		if seg.Flags.Type == 51 {
+			break
+		}
+		if scan.Grouped {
+			continue
+		}
+		if seg.DataLength == 0xFFFFFFFF {
+			return nil, errors.New("segment with unknown data length")
+		}
+		end := uint64(seg.DataOffset) + uint64(seg.DataLength)
+		if end > uint64(scan.stream.GetLength()) {
+			return nil, errors.New("segment data out of range")
+		}
+		scan.stream.SetOffset(uint32(end))
+	}
+	if scan.Grouped {
+		offset := uint64(scan.stream.GetOffset())
+		for _, seg := range segments {
+			if seg.DataLength == 0xFFFFFFFF {
+				return nil, errors.New("segment with unknown data length")
+			}
+			seg.DataOffset = uint32(offset)
+			offset += uint64(seg.DataLength)
+			if offset > uint64(scan.stream.GetLength()) {
+				return nil, errors.New("segment data out of range")
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return nil, errors.New("no segments found")
+	}
+	return segments, nil
+}
+
+// parseIndexedSegment 解析已建立索引的页面0段
+// 与顺序解码相同, 页面0的段按段类型统一分发解析, 字典、表、配置与扩展等段的结果由各页面共享
+// 入参: segment 段对象
+// 返回: error 错误信息
+func (d *Document) parseIndexedSegment(segment *Segment) error {
+	if d.parseIndexedSegmentResult(segment) == ResultFailure {
+		if d.profileErr != nil {
+			return d.profileErr
+		}
+		if d.extErr != nil {
+			return d.extErr
+		}
+		return fmt.Errorf("segment %d: decoding failed", segment.Number)
+	}
+	return nil
+}
+
+// parseIndexedSegmentResult 定位到段数据并解析
+// 入参: segment 段对象
+// 返回: Result 结果
+func (d *Document) parseIndexedSegmentResult(segment *Segment) Result {
+	d.stream.SetOffset(segment.DataOffset)
+	d.segment = segment
+	d.offset = segment.DataOffset
+	ret := d.ParseSegmentData(segment)
+	d.segment = nil
+	if ret == ResultFailure {
+		return ret
+	}
+	d.segmentList = append(d.segmentList, segment)
+	d.applyRetention(segment)
+	return ret
+}
+
+// pendingCompose 待组合的区域图像
+type pendingCompose struct {
+	ri   RegionInfo
+	x, y int32
+	img  *Image
+}
+
+// SetRegionWorkers 设置页面内区域并行解码的并发数
+// 相邻且互不依赖的直接区域段并行解码, 之后按段顺序组合到页面
+// 入参: workers 并发数(0或1关闭, 小于0时使用GOMAXPROCS)
+func (d *Decoder) SetRegionWorkers(workers int) {
+	if d.doc == nil {
+		return
+	}
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	d.doc.regionWorkers = workers
+}
+
+// isBatchableRegion 判断段是否为可并行解码的直接区域段
+// 入参: segment 段对象
+// 返回: bool 是否可并行解码
+func (d *Document) isBatchableRegion(segment *Segment) bool {
+	if d.randomAccess || d.Grouped {
+		return false
+	}
+	switch segment.Flags.Type {
+	case 6, 7, 22, 23, 38, 39:
+	default:
+		return false
+	}
+	if segment.DataLength == 0xFFFFFFFF {
+		return false
+	}
+	return uint64(segment.DataOffset)+uint64(segment.DataLength) <= uint64(d.stream.GetLength())
+}
+
+// decodeRegionBatch 并行解码从当前段开始的一组直接区域段
+// 入参: 无
+// 返回: Result 结果
+func (d *Document) decodeRegionBatch() Result {
+	batch := []*Segment{d.segment}
+	end := d.segment.DataOffset + d.segment.DataLength
+	for len(batch) < d.regionWorkers*4 && end < d.stream.GetLength() {
+		d.stream.SetOffset(end)
+		seg := NewSegment()
+		if d.ParseSegmentHeader(seg) != ResultSuccess || !d.isBatchableRegion(seg) {
+			break
+		}
+		batch = append(batch, seg)
+		end = seg.DataOffset + seg.DataLength
+	}
+	for _, seg := range batch {
+		if err := d.checkProfile(seg); err != nil {
+			d.profileErr = err
+			return ResultFailure
+		}
+	}
+	results := make([][]pendingCompose, len(batch))
+	err := runParallel(context.Background(), len(batch), d.regionWorkers, func(i int) error {
+		worker := d.newSibling(d)
+		worker.inPage = true
+		worker.deferCompose = true
+		worker.stream.SetOffset(batch[i].DataOffset)
+		// 批内各段的配置已在分发前检查
+		if worker.parseSegmentContent(batch[i]) != ResultSuccess {
+			return fmt.Errorf("segment %d: decoding failed", batch[i].Number)
+		}
+		results[i] = worker.pendingCompose
+		return nil
+	})
+	if err != nil {
+		return ResultFailure
+	}
+	for i, seg := range batch {
+		for _, pc := range results[i] {
+			d.composeRegion(&pc.ri, pc.x, pc.y, pc.img)
+		}
+		d.applyRetention(seg)
+		d.segmentList = append(d.segmentList, seg)
+	}
+	d.stream.SetOffset(end)
+	d.segment = nil
+	return ResultSuccess
+}
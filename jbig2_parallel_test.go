@@ -0,0 +1,208 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// goImagesEqual 比较两个图像的类型、大小与逐像素颜色
+// 入参: a b 图像
+// 返回: error 不一致时的说明
+func goImagesEqual(a, b image.Image) error {
+	if fmt.Sprintf("%T", a) != fmt.Sprintf("%T", b) {
+		return fmt.Errorf("type %T vs %T", a, b)
+	}
+	if a.Bounds() != b.Bounds() {
+		return fmt.Errorf("bounds %v vs %v", a.Bounds(), b.Bounds())
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r1, g1, b1, a1 := a.At(x, y).RGBA()
+			r2, g2, b2, a2 := b.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return fmt.Errorf("pixel (%d,%d) differs", x, y)
+			}
+		}
+	}
+	return nil
+}
+
+// testParallelStream 构造共享页面0字典的多页数据流
+// 页面0包含字典、颜色调色板与注释扩展, 各页面另有自己的字典、引用两个字典的文本区域与通用区域
+// 入参: pages 页面数
+// 返回: []byte 数据流
+func testParallelStream(pages int) []byte {
+	r := rand.New(rand.NewSource(31))
+	var shared []*Image
+	for i := 0; i < 5; i++ {
+		shared = append(shared, randomImage(r, 5+r.Int31n(8), 8+r.Int31n(6), 3))
+	}
+	sharedData, sharedOrder := testSymbolDict(shared, 0)
+	s := newTestStream(uint32(pages))
+	dict := s.segment(0, 0, nil, sharedData)
+	s.segment(62, 0, nil, append([]byte{0x20, 0, 0, 0}, "Producer\x00test\x00\x00"...))
+	for p := uint32(1); p <= uint32(pages); p++ {
+		own := []*Image{randomImage(r, 9, 11, 4), randomImage(r, 7, 9, 3)}
+		ownData, ownOrder := testSymbolDict(own, 2)
+		pageDict := s.segment(0, p, nil, ownData)
+		s.segment(48, p, nil, testPageInfo(96, 64, 0))
+		n := len(sharedOrder) + len(ownOrder)
+		var ps []testPlacement
+		for j := 0; j < 12; j++ {
+			ps = append(ps, testPlacement{id: uint32(r.Intn(n)), x: r.Int31n(80), y: r.Int31n(48)})
+		}
+		s.segment(6, p, []uint32{dict, pageDict}, testTextRegion(96, 64, n, ps))
+		s.segment(38, p, nil, testGenericRegion(randomImage(r, 32, 16, 5), 40, 44, ComposeXor, int(p%4), p%2 == 0))
+		if p%2 == 0 {
+			s.segment(38, p, nil, testColourRegion(randomImage(r, 24, 12, 4), 8, 48, uint16(p%4)))
+		}
+		s.segment(49, p, nil, nil)
+	}
+	s.segment(51, 0, nil, nil)
+	return s.out
+}
+
+func TestDecodePagesParallelMatchesSerial(t *testing.T) {
+	const pages = 6
+	data := testParallelStream(pages)
+	serial, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := serial.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != pages {
+		t.Fatalf("serial decode gave %d pages, want %d", len(want), pages)
+	}
+	tests := []struct {
+		name  string
+		pages []int
+	}{
+		{"all", nil},
+		{"subset", []int{5, 2, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := NewDecoder(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := dec.DecodePagesParallel(context.Background(), tt.pages, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nums := tt.pages
+			if nums == nil {
+				for i := 1; i <= pages; i++ {
+					nums = append(nums, i)
+				}
+			}
+			if len(got) != len(nums) {
+				t.Fatalf("got %d pages, want %d", len(got), len(nums))
+			}
+			for i, num := range nums {
+				if err := goImagesEqual(got[i], want[num-1]); err != nil {
+					t.Errorf("page %d: %v", num, err)
+				}
+			}
+		})
+	}
+}
+
+// testPatternDict 构造算术编码的图案字典段数据, 集合位图的编码数据为随机字节
+// 入参: r 随机数源, pw ph 图案大小, grayMax 最大灰度值
+// 返回: []byte 段数据
+func testPatternDict(r *rand.Rand, pw, ph byte, grayMax uint32) []byte {
+	b := []byte{1 << 1, pw, ph}
+	b = binary.BigEndian.AppendUint32(b, grayMax)
+	return append(b, randomBytes(r, 256)...)
+}
+
+// testHalftoneRegion 构造算术编码的半色调区域段数据, 灰度图像的编码数据为随机字节
+// 网格以图案大小为步长横向排列, 区域组合操作与半色调组合操作均为op
+// 入参: r 随机数源, w h 区域大小, x y 位置, op 组合操作, gw gh 网格大小, step 网格步长
+// 返回: []byte 段数据
+func testHalftoneRegion(r *rand.Rand, w, h, x, y uint32, op ComposeOp, gw, gh uint32, step uint16) []byte {
+	b := testRegionInfo(w, h, x, y, op)
+	b = append(b, 1<<1|byte(op)<<4)
+	b = binary.BigEndian.AppendUint32(b, gw)
+	b = binary.BigEndian.AppendUint32(b, gh)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint16(b, step<<8)
+	b = binary.BigEndian.AppendUint16(b, 0)
+	return append(b, randomBytes(r, 256)...)
+}
+
+func TestRegionBatchMatchesSequential(t *testing.T) {
+	r := rand.New(rand.NewSource(32))
+	syms := []*Image{randomImage(r, 9, 12, 4), randomImage(r, 6, 8, 3), randomImage(r, 11, 10, 4)}
+	dictData, order := testSymbolDict(syms, 1)
+	s := newTestStream(1)
+	dict := s.segment(0, 1, nil, dictData)
+	patterns := s.segment(16, 1, nil, testPatternDict(r, 4, 4, 7))
+	// 覆盖组合操作由区域自身给出
+	s.segment(48, 1, nil, testPageInfo(128, 96, 0x40))
+	ops := []ComposeOp{ComposeOr, ComposeAnd, ComposeXor, ComposeXnor, ComposeReplace}
+	for i := 0; i < 12; i++ {
+		op := ops[i%len(ops)]
+		x, y := uint32(r.Intn(64)), uint32(r.Intn(48))
+		switch i % 3 {
+		case 0:
+			typ := byte(38 + i%2)
+			s.segment(typ, 1, nil, testGenericRegion(randomImage(r, 64, 48, 12), x, y, op, i%4, i%2 == 0))
+		case 1:
+			var ps []testPlacement
+			for j := 0; j < 8; j++ {
+				ps = append(ps, testPlacement{id: uint32(r.Intn(len(order))), x: r.Int31n(50), y: r.Int31n(36)})
+			}
+			data := testTextRegion(64, 48, len(order), ps)
+			data[16] = byte(op)
+			s.segment(byte(6+i%2), 1, []uint32{dict}, data)
+		case 2:
+			typ := byte(22 + i%2)
+			s.segment(typ, 1, []uint32{patterns}, testHalftoneRegion(r, 64, 48, x, y, op, 16, 12, 4))
+		}
+	}
+	s.segment(49, 1, nil, nil)
+
+	decode := func(workers int) *Image {
+		dec, err := NewDecoder(bytes.NewReader(s.out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.SetRegionWorkers(workers)
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("workers %d: %v", workers, err)
+		}
+		return dec.doc.page.Duplicate()
+	}
+	want := decode(0)
+	for _, workers := range []int{2, 4, 8} {
+		if got := decode(workers); !imageEqual(got, want) {
+			t.Errorf("workers %d: batched page differs from sequential decoding", workers)
+		}
+	}
+}
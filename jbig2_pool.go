@@ -0,0 +1,143 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// poolMaxBucket 池化的最大容量级别, 更大的缓冲区直接分配
+const poolMaxBucket = 28
+
+// ctxPools 按2的幂容量分级的上下文数组池
+var ctxPools [poolMaxBucket + 1]sync.Pool
+
+// bufPools 按2的幂容量分级的位图缓冲区池
+var bufPools [poolMaxBucket + 1]sync.Pool
+
+// intDecoderPool 算术整数解码器池
+var intDecoderPool = sync.Pool{New: func() any { return NewArithIntDecoder() }}
+
+// intDecoderStatePool 文本区域整数解码器状态池
+var intDecoderStatePool = sync.Pool{New: func() any { return NewIntDecoderState(0) }}
+
+// poolBucket 计算容纳size所需的容量级别
+// 入参: size 元素个数
+// 返回: int 容量级别, bool 是否可池化
+func poolBucket(size int) (int, bool) {
+	if size <= 0 {
+		return 0, false
+	}
+	b := bits.Len(uint(size - 1))
+	return b, b <= poolMaxBucket
+}
+
+// getContexts 获取已清零的上下文数组
+// 入参: size 上下文个数
+// 返回: []ArithCtx 上下文数组
+func getContexts(size int) []ArithCtx {
+	b, ok := poolBucket(size)
+	if !ok {
+		return make([]ArithCtx, size)
+	}
+	if p, _ := ctxPools[b].Get().(*[]ArithCtx); p != nil {
+		ctx := (*p)[:size]
+		clear(ctx)
+		return ctx
+	}
+	return make([]ArithCtx, size, 1<<b)
+}
+
+// putContexts 归还不再引用的上下文数组
+// 入参: ctx 上下文数组
+func putContexts(ctx []ArithCtx) {
+	c := cap(ctx)
+	if c == 0 || c&(c-1) != 0 {
+		return
+	}
+	if b, ok := poolBucket(c); ok {
+		ctx = ctx[:0]
+		ctxPools[b].Put(&ctx)
+	}
+}
+
+// getImageBuffer 获取已清零的位图缓冲区
+// 入参: size 字节数
+// 返回: []byte 缓冲区
+func getImageBuffer(size int) []byte {
+	b, ok := poolBucket(size)
+	if !ok {
+		return make([]byte, size)
+	}
+	if p, _ := bufPools[b].Get().(*[]byte); p != nil {
+		buf := (*p)[:size]
+		clear(buf)
+		return buf
+	}
+	return make([]byte, size, 1<<b)
+}
+
+// putImageBuffer 归还不再引用的位图缓冲区
+// 入参: buf 缓冲区
+func putImageBuffer(buf []byte) {
+	c := cap(buf)
+	if c == 0 || c&(c-1) != 0 {
+		return
+	}
+	if b, ok := poolBucket(c); ok {
+		buf = buf[:0]
+		bufPools[b].Put(&buf)
+	}
+}
+
+// release 将图像缓冲区归还到池中, 之后图像不可再使用
+func (i *Image) release() {
+	if i == nil || i.data == nil {
+		return
+	}
+	putImageBuffer(i.data)
+	i.data = nil
+	i.width, i.height = 0, 0
+}
+
+// getArithIntDecoder 获取已重置的算术整数解码器
+// 返回: *ArithIntDecoder 解码器对象
+func getArithIntDecoder() *ArithIntDecoder {
+	aid := intDecoderPool.Get().(*ArithIntDecoder)
+	aid.Reset()
+	return aid
+}
+
+// putArithIntDecoder 归还算术整数解码器
+// 入参: aid 解码器对象
+func putArithIntDecoder(aid *ArithIntDecoder) {
+	intDecoderPool.Put(aid)
+}
+
+// getIntDecoderState 获取已重置的整数解码器状态
+// 入参: sbsymCodeLen 符号编码长度
+// 返回: *IntDecoderState 解码器状态
+func getIntDecoderState(sbsymCodeLen uint8) *IntDecoderState {
+	s := intDecoderStatePool.Get().(*IntDecoderState)
+	s.Reset(sbsymCodeLen)
+	return s
+}
+
+// putIntDecoderState 归还整数解码器状态
+// 入参: s 解码器状态
+func putIntDecoderState(s *IntDecoderState) {
+	intDecoderStatePool.Put(s)
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// decodeAllPages 以新建的解码器解码数据流的全部页面
+// 入参: t 测试对象, data 数据流
+// 返回: []image.Image 图像列表
+func decodeAllPages(t *testing.T, data []byte) []image.Image {
+	t.Helper()
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pages
+}
+
+func TestResetMatchesFreshDecoder(t *testing.T) {
+	streams := [][]byte{testParallelStream(3), testSnapshotStream(), testParallelStream(2)}
+	dec, err := NewDecoder(bytes.NewReader(streams[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一解码器依次解码各数据流, 再回到第一个数据流, 结果均与新建的解码器一致
+	var first []image.Image
+	for i, data := range append(streams, streams[0]) {
+		if i > 0 {
+			if err := dec.Reset(bytes.NewReader(data)); err != nil {
+				t.Fatalf("stream %d: %v", i, err)
+			}
+		}
+		got, err := dec.DecodeAll()
+		if err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		if i == 0 {
+			first = got
+		}
+		want := decodeAllPages(t, data)
+		if len(got) != len(want) {
+			t.Fatalf("stream %d: %d pages, want %d", i, len(got), len(want))
+		}
+		for p := range want {
+			if err := goImagesEqual(got[p], want[p]); err != nil {
+				t.Errorf("stream %d page %d: %v", i, p+1, err)
+			}
+		}
+	}
+	// 重置前返回的图像不受之后解码的影响
+	for p, want := range decodeAllPages(t, streams[0]) {
+		if err := goImagesEqual(first[p], want); err != nil {
+			t.Errorf("earlier page %d changed: %v", p+1, err)
+		}
+	}
+}
+
+func TestPooledBuffersZeroed(t *testing.T) {
+	reused := 0
+	for n := 0; n < 100; n++ {
+		ctx := getContexts(8192)
+		for i := range ctx {
+			ctx[i] = ArithCtx{mps: true, i: 46}
+		}
+		putContexts(ctx)
+		again := getContexts(8192)
+		if &again[0] == &ctx[0] {
+			reused++
+		}
+		for i, c := range again {
+			if c != (ArithCtx{}) {
+				t.Fatalf("context %d not zeroed: %+v", i, c)
+			}
+		}
+		putContexts(again)
+
+		buf := getImageBuffer(4096)
+		for i := range buf {
+			buf[i] = 0xA5
+		}
+		putImageBuffer(buf)
+		for i, v := range getImageBuffer(3000) {
+			if v != 0 {
+				t.Fatalf("buffer byte %d not zeroed: %#x", i, v)
+			}
+		}
+
+		aid := getArithIntDecoder()
+		for i := range aid.iax {
+			aid.iax[i] = ArithCtx{mps: true, i: 12}
+		}
+		putArithIntDecoder(aid)
+		for i, c := range getArithIntDecoder().iax {
+			if c != (ArithCtx{}) {
+				t.Fatalf("integer decoder context %d not reset", i)
+			}
+		}
+
+		state := getIntDecoderState(4)
+		for i := range state.IAID.iaid {
+			state.IAID.iaid[i] = ArithCtx{mps: true, i: 3}
+		}
+		state.IADT.iax[7] = ArithCtx{i: 9}
+		putIntDecoderState(state)
+		state = getIntDecoderState(3)
+		if len(state.IAID.iaid) != 1<<3 {
+			t.Fatalf("IAID contexts %d, want %d", len(state.IAID.iaid), 1<<3)
+		}
+		for _, c := range append(state.IAID.iaid, state.IADT.iax[:]...) {
+			if c != (ArithCtx{}) {
+				t.Fatal("integer decoder state not reset")
+			}
+		}
+		putIntDecoderState(state)
+	}
+	if reused == 0 {
+		t.Log("context pool never reused an array")
+	}
+}
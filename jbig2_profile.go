@@ -0,0 +1,334 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Feature 编码特性集合
+type Feature uint32
+
+const (
+	// FeatureGeneric 通用区域
+	FeatureGeneric Feature = 1 << iota
+	// FeatureSymbol 符号字典与文本区域
+	FeatureSymbol
+	// FeatureHalftone 图案字典与半色调区域
+	FeatureHalftone
+	// FeatureRefinement 细化编码, 包括细化区域与符号字典、文本区域中的细化
+	// 细化编码总是使用算术编码, 不另计算术编码特性
+	FeatureRefinement
+	// FeatureArith 算术编码
+	FeatureArith
+	// FeatureHuffman 霍夫曼编码
+	FeatureHuffman
+	// FeatureMMR MMR编码
+	FeatureMMR
+	// FeatureIntermediate 中间区域
+	FeatureIntermediate
+	// FeatureCustomTables 自定义霍夫曼表
+	FeatureCustomTables
+	// FeatureExtTemplate 12个自适应像素的扩展通用区域模板
+	FeatureExtTemplate
+	// FeatureColour 颜色扩展区域
+	FeatureColour
+	// FeatureTemplate0 16个像素的模板0, 包括通用区域、符号字典、图案字典与半色调区域的算术编码模板0
+	FeatureTemplate0
+
+	// FeatureAll 全部特性
+	FeatureAll Feature = 1<<iota - 1
+)
+
+// kFeatureNames 特性名称
+var kFeatureNames = []string{
+	"generic", "symbol", "halftone", "refinement", "arith", "huffman",
+	"mmr", "intermediate", "custom-tables", "ext-template", "colour",
+	"template0",
+}
+
+// kProfileFeatures 内置配置允许的特性, 按T.88附件A的配置定义
+// 只包含定义明确给出的限制, 附件A未涉及的特性(如扩展模板与颜色扩展)不受限制, 其他解释通过DecoderOptions.ProfileFeatures给出
+var kProfileFeatures = map[uint32]Feature{
+	// A.1 全部JBIG2能力
+	1: FeatureAll,
+	// A.2 最大压缩: 只使用算术编码
+	2: FeatureAll &^ (FeatureHuffman | FeatureMMR),
+	// A.3 中等复杂度与中等压缩: 只使用算术编码, 不使用模板0
+	3: FeatureAll &^ (FeatureHuffman | FeatureMMR | FeatureTemplate0),
+	// A.4 低复杂度与渐进无损: 只使用MMR与霍夫曼编码, 允许细化
+	4: FeatureAll &^ FeatureArith,
+	// A.5 低复杂度: 只使用MMR与霍夫曼编码, 不使用细化
+	5: FeatureAll &^ (FeatureArith | FeatureRefinement),
+}
+
+// kMaxProfiles 配置段中配置数量的上限
+const kMaxProfiles = 1024
+
+// ErrProfileViolation 段使用了声明的配置不允许的特性
+var ErrProfileViolation = errors.New("segment uses features outside declared profiles")
+
+// String 返回特性名称, 多个特性以"|"连接
+// 返回: string 名称
+func (f Feature) String() string {
+	if f == 0 {
+		return "none"
+	}
+	var names []string
+	for i, name := range kFeatureNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if rest := f &^ FeatureAll; rest != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(rest)))
+	}
+	return strings.Join(names, "|")
+}
+
+// Profiles 获取数据流与全局段中已声明的配置
+// 配置段在解码到达时才被解析
+// 返回: []uint32 配置编号列表
+func (d *Decoder) Profiles() []uint32 {
+	if d.doc == nil {
+		return nil
+	}
+	return d.doc.GetProfiles()
+}
+
+// GetProfiles 获取文档及其全局上下文中已声明的配置
+// 返回: []uint32 配置编号列表
+func (d *Document) GetProfiles() []uint32 {
+	var profiles []uint32
+	for doc := d; doc != nil; doc = doc.globalContext {
+		for _, p := range doc.profiles {
+			found := false
+			for _, q := range profiles {
+				if p == q {
+					found = true
+					break
+				}
+			}
+			if !found {
+				profiles = append(profiles, p)
+			}
+		}
+	}
+	return profiles
+}
+
+// parseProfiles 解析配置段
+// 入参: segment 段对象
+// 返回: Result 解析结果
+func (d *Document) parseProfiles(segment *Segment) Result {
+	var count uint32
+	if val, err := d.stream.ReadInteger(); err != nil {
+		return ResultFailure
+	} else {
+		count = val
+	}
+	if count > kMaxProfiles || uint64(count)*4+4 > uint64(segment.DataLength) {
+		return ResultFailure
+	}
+	for i := uint32(0); i < count; i++ {
+		if val, err := d.stream.ReadInteger(); err != nil {
+			return ResultFailure
+		} else {
+			d.profiles = append(d.profiles, val)
+		}
+	}
+	d.stream.AddOffset(segment.DataLength - count*4 - 4)
+	d.globalsChecked = false
+	if d.enforcingProfiles() {
+		if _, err := d.allowedFeatures(); err != nil {
+			d.profileErr = err
+			return ResultFailure
+		}
+	}
+	return ResultSuccess
+}
+
+// enforcingProfiles 是否强制检查声明的配置
+// 返回: bool 是否检查
+func (d *Document) enforcingProfiles() bool {
+	return d.opts != nil && d.opts.EnforceProfiles
+}
+
+// allowedFeatures 计算声明的全部配置共同允许的特性
+// 返回: Feature 允许的特性, error 配置未知时的错误信息
+func (d *Document) allowedFeatures() (Feature, error) {
+	allowed := FeatureAll
+	for _, p := range d.GetProfiles() {
+		features, ok := d.opts.ProfileFeatures[p]
+		if !ok {
+			features, ok = kProfileFeatures[p]
+		}
+		if !ok {
+			return 0, fmt.Errorf("unknown profile %d", p)
+		}
+		allowed &= features
+	}
+	return allowed, nil
+}
+
+// checkProfile 检查段使用的特性是否在声明的配置之内
+// 未声明配置或未启用检查时不加限制, 声明的配置变化后首次检查时一并检查全局段
+// 入参: segment 段对象
+// 返回: error 错误信息
+func (d *Document) checkProfile(segment *Segment) error {
+	if !d.enforcingProfiles() || segment.Flags.Type == 52 || len(d.GetProfiles()) == 0 {
+		return nil
+	}
+	allowed, err := d.allowedFeatures()
+	if err != nil {
+		return err
+	}
+	if !d.globalsChecked {
+		if err := d.checkGlobalProfiles(allowed); err != nil {
+			return err
+		}
+		d.globalsChecked = true
+	}
+	if used := d.segmentFeatures(segment) &^ allowed; used != 0 {
+		return fmt.Errorf("%w: segment %d uses %v", ErrProfileViolation, segment.Number, used)
+	}
+	return nil
+}
+
+// checkGlobalProfiles 检查全局上下文中已解析的段是否在声明的配置之内
+// 全局段解析时没有解码器选项, 由引用它们的文档在启用检查后补充检查
+// 入参: allowed 允许的特性
+// 返回: error 错误信息
+func (d *Document) checkGlobalProfiles(allowed Feature) error {
+	for doc := d.globalContext; doc != nil; doc = doc.globalContext {
+		for _, seg := range doc.segmentList {
+			if seg.Flags.Type == 52 {
+				continue
+			}
+			if used := doc.segmentFeatures(seg) &^ allowed; used != 0 {
+				return fmt.Errorf("%w: global segment %d uses %v", ErrProfileViolation, seg.Number, used)
+			}
+		}
+	}
+	return nil
+}
+
+// segmentFeatures 根据段类型与段数据中的标志确定段使用的特性
+// 入参: segment 段对象
+// 返回: Feature 使用的特性
+func (d *Document) segmentFeatures(segment *Segment) Feature {
+	data := d.stream.data
+	if int(segment.DataOffset) > len(data) {
+		return 0
+	}
+	data = data[segment.DataOffset:]
+	if segment.DataLength != 0xFFFFFFFF && uint64(segment.DataLength) < uint64(len(data)) {
+		data = data[:segment.DataLength]
+	}
+	order := byteOrder(d.stream.littleEndian)
+	var f Feature
+	// 区域段的标志位于区域信息之后, 颜色扩展的区域信息多2字节
+	region := func() []byte {
+		if len(data) < 17 {
+			return nil
+		}
+		if data[16]&kColourExtFlag != 0 {
+			f |= FeatureColour
+			return data[19:]
+		}
+		return data[17:]
+	}
+	switch segment.Flags.Type {
+	case 0:
+		f |= FeatureSymbol
+		if len(data) >= 2 {
+			flags := order.Uint16(data)
+			f |= entropyFeature(flags&0x0001 != 0, FeatureHuffman)
+			if flags&0x0002 != 0 {
+				f |= FeatureRefinement
+			}
+			if flags&0x0001 == 0 && (flags>>10)&0x03 == 0 {
+				f |= FeatureTemplate0
+			}
+		}
+	case 4, 6, 7:
+		f |= FeatureSymbol
+		if segment.Flags.Type == 4 {
+			f |= FeatureIntermediate
+		}
+		if rest := region(); len(rest) >= 2 {
+			flags := order.Uint16(rest)
+			f |= entropyFeature(flags&0x0001 != 0, FeatureHuffman)
+			if flags&0x0002 != 0 {
+				f |= FeatureRefinement
+			}
+		}
+	case 16:
+		f |= FeatureHalftone
+		if len(data) >= 1 {
+			f |= entropyFeature(data[0]&0x01 != 0, FeatureMMR) | template0Feature(data[0])
+		}
+	case 20, 22, 23:
+		f |= FeatureHalftone
+		if segment.Flags.Type == 20 {
+			f |= FeatureIntermediate
+		}
+		if rest := region(); len(rest) >= 1 {
+			f |= entropyFeature(rest[0]&0x01 != 0, FeatureMMR) | template0Feature(rest[0])
+		}
+	case 36, 38, 39:
+		f |= FeatureGeneric
+		if segment.Flags.Type == 36 {
+			f |= FeatureIntermediate
+		}
+		if rest := region(); len(rest) >= 1 {
+			f |= entropyFeature(rest[0]&0x01 != 0, FeatureMMR) | template0Feature(rest[0])
+			if rest[0]&0x07 == 0 && rest[0]&0x10 != 0 {
+				f |= FeatureExtTemplate
+			}
+		}
+	case 40, 42, 43:
+		f |= FeatureRefinement
+		if segment.Flags.Type == 40 {
+			f |= FeatureIntermediate
+		}
+		region()
+	case 53:
+		f |= FeatureCustomTables
+	}
+	return f
+}
+
+// template0Feature 根据MMR标志(第0位)与模板号(第1-2位)确定是否使用模板0
+// 入参: flags 段数据中的标志字节
+// 返回: Feature 算术编码模板0时为FeatureTemplate0, 否则为0
+func template0Feature(flags byte) Feature {
+	if flags&0x01 == 0 && (flags>>1)&0x03 == 0 {
+		return FeatureTemplate0
+	}
+	return 0
+}
+
+// entropyFeature 根据编码标志确定编码方式
+// 入参: set 标志是否置位, alt 置位时使用的编码特性
+// 返回: Feature 编码特性
+func entropyFeature(set bool, alt Feature) Feature {
+	if set {
+		return alt
+	}
+	return FeatureArith
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// testProfiles 构造配置段数据
+// 入参: profiles 配置编号
+// 返回: []byte 段数据
+func testProfiles(profiles ...uint32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(profiles)))
+	for _, p := range profiles {
+		b = binary.BigEndian.AppendUint32(b, p)
+	}
+	return b
+}
+
+// decodeWithProfiles 启用配置检查解码数据流的第一页
+// 入参: data 数据流, globals 已解析的全局段
+// 返回: error 错误信息
+func decodeWithProfiles(data []byte, globals *Globals) error {
+	dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{Globals: globals, EnforceProfiles: true})
+	if err != nil {
+		return err
+	}
+	_, err = dec.Decode()
+	return err
+}
+
+func TestProfileTemplate0(t *testing.T) {
+	r := rand.New(rand.NewSource(49))
+	img := randomImage(r, 32, 16, 6)
+	for template := 0; template < 4; template++ {
+		s := newTestStream(1)
+		s.segment(52, 0, nil, testProfiles(3))
+		s.segment(48, 1, nil, testPageInfo(32, 16, 0))
+		s.segment(38, 1, nil, testGenericRegion(img, 0, 0, ComposeOr, template, false))
+		err := decodeWithProfiles(s.out, nil)
+		if violated := errors.Is(err, ErrProfileViolation); violated != (template == 0) {
+			t.Errorf("template %d: error = %v", template, err)
+		}
+	}
+}
+
+func TestProfileGlobalSegments(t *testing.T) {
+	r := rand.New(rand.NewSource(50))
+	syms := []*Image{randomImage(r, 8, 10, 2), randomImage(r, 12, 10, 3)}
+	img := randomImage(r, 32, 16, 6)
+	tests := []struct {
+		name          string
+		template      int
+		globalProfile bool
+		violated      bool
+	}{
+		{"template0", 0, false, true},
+		{"template1", 1, false, false},
+		{"declared in globals", 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &testStream{}
+			if tt.globalProfile {
+				g.segment(52, 0, nil, testProfiles(3))
+			}
+			dict, _ := testSymbolDict(syms, tt.template)
+			g.segment(0, 0, nil, dict)
+			globals, err := ParseGlobals(g.out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := newTestStream(1)
+			if !tt.globalProfile {
+				s.segment(52, 0, nil, testProfiles(3))
+			}
+			s.segment(48, 1, nil, testPageInfo(32, 16, 0))
+			s.segment(38, 1, nil, testGenericRegion(img, 0, 0, ComposeOr, 1, false))
+			err = decodeWithProfiles(s.out, globals)
+			if violated := errors.Is(err, ErrProfileViolation); violated != tt.violated {
+				t.Errorf("error = %v, want violation %v", err, tt.violated)
+			}
+			// 未启用检查时全局段不受限制
+			dec, err := NewDecoderWithParsedGlobals(bytes.NewReader(s.out), globals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := dec.Decode(); err != nil {
+				t.Errorf("unchecked decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestProfileAnnexA(t *testing.T) {
+	r := rand.New(rand.NewSource(51))
+	img := randomImage(r, 32, 16, 6)
+	ref := NewImage(32, 16)
+	ref.Fill(false)
+	// 各段依次为算术编码模板1、模板0、扩展模板的通用区域与无引用的细化区域
+	regions := []struct {
+		name    string
+		flags   byte
+		typ     byte
+		data    []byte
+		allowed []uint32
+	}{
+		{"arith template 1", 0, 38, testGenericRegion(img, 0, 0, ComposeOr, 1, false), []uint32{1, 2, 3}},
+		{"arith template 0", 0, 38, testGenericRegion(img, 0, 0, ComposeOr, 0, false), []uint32{1, 2}},
+		{"ext template", 0, 38, testExtGenericRegion(img, kTestExtNominalAT, false), []uint32{1, 2}},
+		{"refinement", 0x02, 42, testRefinementRegion(img, ref, 0, 0, false, false), []uint32{1, 2, 3, 4}},
+	}
+	for _, rg := range regions {
+		for profile := uint32(1); profile <= 5; profile++ {
+			s := newTestStream(1)
+			s.segment(52, 0, nil, testProfiles(profile))
+			s.segment(48, 1, nil, testPageInfo(32, 16, rg.flags))
+			s.segment(rg.typ, 1, nil, rg.data)
+			s.segment(49, 1, nil, nil)
+			err := decodeWithProfiles(s.out, nil)
+			allowed := false
+			for _, p := range rg.allowed {
+				allowed = allowed || p == profile
+			}
+			if violated := errors.Is(err, ErrProfileViolation); violated == allowed || (allowed && err != nil) {
+				t.Errorf("%s profile %d: error = %v, want allowed %v", rg.name, profile, err, allowed)
+			}
+		}
+	}
+}
+
+func TestProfileFeaturesOption(t *testing.T) {
+	r := rand.New(rand.NewSource(52))
+	s := newTestStream(1)
+	s.segment(52, 0, nil, testProfiles(3, 9))
+	s.segment(48, 1, nil, testPageInfo(32, 16, 0))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 32, 16, 6), 0, 0, ComposeOr, 0, false))
+	s.segment(49, 1, nil, nil)
+	tests := []struct {
+		name     string
+		features map[uint32]Feature
+		violated bool
+		unknown  bool
+	}{
+		{"unknown profile", nil, false, true},
+		{"define profile 9", map[uint32]Feature{9: FeatureAll}, true, false},
+		{"replace profile 3", map[uint32]Feature{3: FeatureAll, 9: FeatureGeneric | FeatureArith | FeatureTemplate0}, false, false},
+	}
+	for _, tt := range tests {
+		dec, err := NewDecoderWithOptions(bytes.NewReader(s.out), DecoderOptions{EnforceProfiles: true, ProfileFeatures: tt.features})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = dec.Decode()
+		if violated := errors.Is(err, ErrProfileViolation); violated != tt.violated {
+			t.Errorf("%s: error = %v, want violation %v", tt.name, err, tt.violated)
+		}
+		if tt.unknown != (err != nil && !errors.Is(err, ErrProfileViolation)) {
+			t.Errorf("%s: error = %v, want unknown profile %v", tt.name, err, tt.unknown)
+		}
+	}
+}
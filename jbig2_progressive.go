@@ -0,0 +1,446 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"errors"
+	"image"
+)
+
+// ProgressStatus 渐进解码状态
+type ProgressStatus int
+
+const (
+	// ProgressNeedData 已解码到可用数据的末尾, 需要写入更多数据
+	ProgressNeedData ProgressStatus = iota
+	// ProgressPageReady 一页解码完成, 可通过Page获取
+	ProgressPageReady
+	// ProgressEnd 数据流结束
+	ProgressEnd
+)
+
+// progressMinMargin 暂停时在可用数据末尾保留的最小字节数
+const progressMinMargin = 16
+
+// progressMaxData 渐进解码器缓存数据的上限, 超过时Write返回错误
+const progressMaxData = 256 << 20
+
+// kFileSignature JBIG2文件头标识
+var kFileSignature = []byte{0x97, 0x4A, 0x42, 0x32, 0x0D, 0x0A, 0x1A, 0x0A}
+
+// ProgressiveDecoder 推送式渐进解码器
+// 通过Write追加数据, Poll解码到可用数据允许的位置
+// 算术编码的通用区域可在行间暂停, 数据到达后继续解码; 其他段在数据完整后解码
+// 支持顺序组织的JBIG2文件与不含文件头的嵌入式数据流
+type ProgressiveDecoder struct {
+	doc       *Document
+	data      []byte
+	start     int
+	closed    bool
+	ended     bool
+	pageIndex uint32
+	region    *pausedRegion
+	err       error
+}
+
+// pausedRegion 解码中的通用区域
+type pausedRegion struct {
+	segment  *Segment
+	ri       RegionInfo
+	grd      *GRDProc
+	image    *Image
+	state    ProgressiveArithDecodeState
+	stream   *BitStream
+	started  bool
+	complete bool
+	decoded  bool
+	margin   uint32
+	lastPos  uint32
+	saved    arithCheckpoint
+}
+
+// arithCheckpoint 通用区域的行首解码状态
+type arithCheckpoint struct {
+	decoder   ArithDecoder
+	byteIdx   uint32
+	bitIdx    uint32
+	contexts  []ArithCtx
+	zero      bool
+	loopIndex uint32
+	ltp       int
+}
+
+// NewProgressiveDecoder 创建推送式渐进解码器
+// 入参: globals 已解析的全局段, 可为nil
+// 返回: *ProgressiveDecoder 解码器对象
+func NewProgressiveDecoder(globals *Globals) *ProgressiveDecoder {
+	doc := NewDocument(nil, nil, false, false)
+	if globals != nil {
+		doc.globalContext = globals.doc
+	}
+	return &ProgressiveDecoder{doc: doc, start: -1}
+}
+
+// Write 追加数据
+// 入参: b 数据
+// 返回: int 写入字节数, error 错误信息
+func (p *ProgressiveDecoder) Write(b []byte) (int, error) {
+	if p.closed {
+		return 0, errors.New("write after close")
+	}
+	if len(p.data)+len(b) > progressMaxData {
+		return 0, errors.New("stream too large")
+	}
+	p.data = append(p.data, b...)
+	if p.start >= 0 {
+		p.doc.stream.data = p.data[p.start:]
+	}
+	return len(b), nil
+}
+
+// Close 标记数据已全部写入, 之后的Poll将解码剩余数据直到结束
+// 返回: error 错误信息
+func (p *ProgressiveDecoder) Close() error {
+	p.closed = true
+	return nil
+}
+
+// Poll 解码到可用数据允许的位置
+// 返回ProgressPageReady后可通过Page获取完整页面, 再次调用Poll继续解码下一页
+// 返回: ProgressStatus 解码状态, error 错误信息
+func (p *ProgressiveDecoder) Poll() (ProgressStatus, error) {
+	if p.err != nil {
+		return ProgressEnd, p.err
+	}
+	status, err := p.poll()
+	if err != nil {
+		p.err = err
+		return ProgressEnd, err
+	}
+	return status, nil
+}
+
+// Page 获取当前页面
+// 页面解码过程中返回已解码部分, 解码中的直接通用区域只合成已完成的行
+// 返回: image.Image 图像, 尚未解析页面信息时为nil
+func (p *ProgressiveDecoder) Page() image.Image {
+	d := p.doc
+	if d.page == nil {
+		return nil
+	}
+	r := p.region
+	if r == nil || r.image == nil || r.segment.Flags.Type == 36 || !d.inPage {
+		return d.page.ToGoImage()
+	}
+	rows := r.grd.loopIndex
+	if r.decoded {
+		rows = r.grd.GBH
+	}
+	if rows == 0 {
+		return d.page.ToGoImage()
+	}
+	page := d.page.Duplicate()
+	defer page.release()
+	done := r.image.SubImage(0, 0, r.image.Width(), int32(rows))
+	defer done.release()
+	page.ComposeFrom(r.ri.X-int32(d.pageOrigin.X), r.ri.Y-int32(d.pageOrigin.Y), done, d.regionComposeOp(&r.ri))
+	return page.ToGoImage()
+}
+
+// poll 解码到可用数据允许的位置
+// 返回: ProgressStatus 解码状态, error 错误信息
+func (p *ProgressiveDecoder) poll() (ProgressStatus, error) {
+	if p.start < 0 {
+		if ok, err := p.parseHeader(); err != nil || !ok {
+			return ProgressNeedData, err
+		}
+	}
+	d := p.doc
+	for !p.ended {
+		if p.region != nil {
+			done, err := p.continueRegion()
+			if err != nil || !done {
+				return ProgressNeedData, err
+			}
+			continue
+		}
+		if d.segment == nil {
+			if d.stream.GetByteLeft() == 0 {
+				if !p.closed {
+					return ProgressNeedData, nil
+				}
+				return p.endOfStream(), nil
+			}
+			offset := d.stream.GetOffset()
+			segment := NewSegment()
+			if d.ParseSegmentHeader(segment) != ResultSuccess {
+				if !p.closed {
+					d.stream.SetOffset(offset)
+					return ProgressNeedData, nil
+				}
+				return p.endOfStream(), nil
+			}
+			d.segment = segment
+			d.offset = d.stream.GetOffset()
+		}
+		segment := d.segment
+		available := p.segmentAvailable(segment)
+		if !available && p.isProgressiveRegion(segment) {
+			ok, err := p.startRegion()
+			if err != nil || !ok {
+				return ProgressNeedData, err
+			}
+			continue
+		}
+		if !available && !p.closed {
+			return ProgressNeedData, nil
+		}
+		ret := d.ParseSegmentData(segment)
+		if ret == ResultEndReached {
+			d.segmentList = append(d.segmentList, segment)
+			d.segment = nil
+			return p.endOfStream(), nil
+		}
+		if ret == ResultPageCompleted {
+			d.segmentList = append(d.segmentList, segment)
+			d.segment = nil
+			if !d.hasPage() {
+				return ProgressEnd, errors.New("page completed but no image found")
+			}
+			p.pageIndex++
+			d.ReleasePageSegments(p.pageIndex)
+			return ProgressPageReady, nil
+		}
+		if ret != ResultSuccess {
+			d.segment = nil
+			return ProgressEnd, errors.New("decoding failed")
+		}
+		d.finishSegment()
+	}
+	return ProgressEnd, nil
+}
+
+// parseHeader 解析文件头并定位到第一个段
+// 数据不以文件头标识开始时按嵌入式数据流处理
+// 返回: bool 是否完成, error 错误信息
+func (p *ProgressiveDecoder) parseHeader() (bool, error) {
+	n := min(len(p.data), len(kFileSignature))
+	if !bytes.Equal(p.data[:n], kFileSignature[:n]) {
+		p.setStart(0)
+		return true, nil
+	}
+	if len(p.data) < len(kFileSignature)+1 {
+		if p.closed {
+			return false, errors.New("truncated file header")
+		}
+		return false, nil
+	}
+	flags := p.data[len(kFileSignature)]
+	if flags&0x01 == 0 {
+		return false, errors.New("random-access organisation cannot be decoded progressively")
+	}
+	start := len(kFileSignature) + 1
+	if flags&0x02 == 0 {
+		start += 4
+	}
+	if len(p.data) < start {
+		if p.closed {
+			return false, errors.New("truncated file header")
+		}
+		return false, nil
+	}
+	p.setStart(start)
+	return true, nil
+}
+
+// setStart 设置第一个段在数据中的位置
+// 入参: start 偏移量
+func (p *ProgressiveDecoder) setStart(start int) {
+	p.start = start
+	p.doc.stream.data = p.data[start:]
+	p.doc.stream.SetOffset(0)
+}
+
+// endOfStream 处理数据流结束, 未结束的页面视为完成
+// 返回: ProgressStatus 解码状态
+func (p *ProgressiveDecoder) endOfStream() ProgressStatus {
+	d := p.doc
+	p.ended = true
+	if d.inPage && d.hasPage() {
+		d.inPage = false
+		p.pageIndex++
+		d.ReleasePageSegments(p.pageIndex)
+		return ProgressPageReady
+	}
+	return ProgressEnd
+}
+
+// segmentAvailable 检查段数据是否已完整写入
+// 入参: segment 段对象
+// 返回: bool 是否完整
+func (p *ProgressiveDecoder) segmentAvailable(segment *Segment) bool {
+	if p.closed {
+		return true
+	}
+	if segment.DataLength == 0xFFFFFFFF {
+		return false
+	}
+	return uint64(p.doc.offset)+uint64(segment.DataLength) <= uint64(p.doc.stream.GetLength())
+}
+
+// isProgressiveRegion 检查段是否为可在行间暂停的通用区域
+// 入参: segment 段对象
+// 返回: bool 是否可暂停
+func (p *ProgressiveDecoder) isProgressiveRegion(segment *Segment) bool {
+	switch segment.Flags.Type {
+	case 36, 38, 39:
+		return p.doc.inPage
+	}
+	return false
+}
+
+// startRegion 解析通用区域段头并开始渐进解码
+// MMR编码的区域需等待数据完整
+// 返回: bool 是否已开始, error 错误信息
+func (p *ProgressiveDecoder) startRegion() (bool, error) {
+	d := p.doc
+	segment := d.segment
+	pGRD, ri, ret := d.parseGenericRegionHeader(segment)
+	if ret != ResultSuccess || pGRD.MMR || d.stream.GetByteLeft() < progressMinMargin {
+		d.stream.SetOffset(d.offset)
+		return false, nil
+	}
+	r := &pausedRegion{
+		segment: segment,
+		ri:      ri,
+		grd:     pGRD,
+		stream:  d.stream,
+		margin:  progressMinMargin,
+	}
+	r.state = ProgressiveArithDecodeState{
+		Image:        &r.image,
+		ArithDecoder: NewArithDecoder(d.stream),
+		GbContexts:   getContexts(pGRD.ContextSize()),
+		Pause:        r,
+	}
+	r.lastPos = d.stream.GetOffset()
+	r.saved = arithCheckpoint{
+		decoder: *r.state.ArithDecoder,
+		byteIdx: d.stream.byteIdx,
+		bitIdx:  d.stream.bitIdx,
+		zero:    true,
+	}
+	p.region = r
+	return true, nil
+}
+
+// continueRegion 继续解码当前通用区域
+// 越过可用数据末尾的行会回退到上一个检查点并在更多数据到达后重新解码
+// 返回: bool 是否完成, error 错误信息
+func (p *ProgressiveDecoder) continueRegion() (bool, error) {
+	d := p.doc
+	r := p.region
+	r.complete = p.segmentAvailable(r.segment)
+	for !r.decoded {
+		var res JBig2SegmentState
+		if r.started {
+			res = r.grd.ContinueDecode(&r.state)
+		} else {
+			r.started = true
+			res = r.grd.StartDecodeArith(&r.state)
+		}
+		if r.image != nil && !r.complete && r.overrun() {
+			r.restore(res == JBig2SegmentParseComplete)
+			r.margin *= 2
+			continue
+		}
+		switch res {
+		case JBig2SegmentPaused:
+			if r.grd.loopIndex != r.saved.loopIndex {
+				r.checkpoint()
+			}
+			return false, nil
+		case JBig2SegmentParseComplete:
+			r.decoded = true
+		default:
+			return false, errors.New("decoding failed")
+		}
+	}
+	end := uint64(d.offset) + uint64(r.segment.DataLength)
+	if r.segment.DataLength == 0xFFFFFFFF {
+		end = uint64(d.stream.GetOffset()) + 6
+	}
+	if !p.closed && end > uint64(d.stream.GetLength()) {
+		return false, nil
+	}
+	putContexts(r.state.GbContexts)
+	p.region = nil
+	r.segment.Image = r.image
+	d.stream.AlignByte()
+	d.stream.AddOffset(2)
+	d.composeGenericRegion(r.segment, &r.ri)
+	d.finishSegment()
+	return true, nil
+}
+
+// NeedToPauseNow 在剩余可用数据不足以安全解码下一行时暂停
+// 余量按已解码行的最大字节数自适应增长
+// 返回: bool 是否暂停
+func (r *pausedRegion) NeedToPauseNow() bool {
+	pos := r.stream.GetOffset()
+	if used := pos - r.lastPos; used*2 > r.margin {
+		r.margin = used * 2
+	}
+	r.lastPos = pos
+	return !r.complete && uint64(pos)+uint64(r.margin) >= uint64(r.stream.GetLength())
+}
+
+// overrun 检查解码器是否读取过可用数据之外的字节
+// 返回: bool 是否越界
+func (r *pausedRegion) overrun() bool {
+	return r.state.ArithDecoder.IsComplete() || uint64(r.stream.GetOffset())+1 >= uint64(r.stream.GetLength())
+}
+
+// checkpoint 在行首保存解码状态
+func (r *pausedRegion) checkpoint() {
+	s := &r.saved
+	s.decoder = *r.state.ArithDecoder
+	s.byteIdx, s.bitIdx = r.stream.byteIdx, r.stream.bitIdx
+	s.contexts = append(s.contexts[:0], r.state.GbContexts...)
+	s.zero = false
+	s.loopIndex, s.ltp = r.grd.loopIndex, r.grd.ltp
+}
+
+// restore 回退到上一个检查点并清除其后已写入的行
+// 入参: completed 区域是否已解码到最后一行
+func (r *pausedRegion) restore(completed bool) {
+	s := &r.saved
+	*r.state.ArithDecoder = s.decoder
+	r.stream.byteIdx, r.stream.bitIdx = s.byteIdx, s.bitIdx
+	if s.zero {
+		clear(r.state.GbContexts)
+	} else {
+		copy(r.state.GbContexts, s.contexts)
+	}
+	end := r.grd.GBH
+	if !completed {
+		end = min(r.grd.loopIndex+1, r.grd.GBH)
+	}
+	img := r.image
+	clear(img.data[int(s.loopIndex)*int(img.stride) : int(end)*int(img.stride)])
+	r.grd.loopIndex, r.grd.ltp = s.loopIndex, s.ltp
+	r.lastPos = s.byteIdx
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// testProgressiveStream 构造两页数据流, 区域均以或操作组合到默认像素为0的页面
+// 第1页含字典、文本区域与两个通用区域, 第2页的大通用区域数据长度未知, 以行数结尾
+// 返回: []byte 数据流
+func testProgressiveStream() []byte {
+	r := rand.New(rand.NewSource(43))
+	syms := []*Image{randomImage(r, 9, 12, 4), randomImage(r, 6, 8, 3)}
+	dictData, order := testSymbolDict(syms, 0)
+	s := newTestStream(2)
+	dict := s.segment(0, 0, nil, dictData)
+
+	s.segment(48, 1, nil, testPageInfo(120, 90, 0))
+	var ps []testPlacement
+	for j := 0; j < 10; j++ {
+		ps = append(ps, testPlacement{id: uint32(r.Intn(len(order))), x: r.Int31n(100), y: r.Int31n(70)})
+	}
+	s.segment(6, 1, []uint32{dict}, testTextRegion(120, 90, len(order), ps))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 100, 60, 20), 10, 20, ComposeOr, 1, true))
+	s.segment(39, 1, nil, testGenericRegion(randomImage(r, 40, 30, 6), 70, 5, ComposeOr, 3, false))
+	s.segment(49, 1, nil, nil)
+
+	s.segment(48, 2, nil, testPageInfo(160, 200, 0))
+	region := randomImage(r, 150, 180, 40)
+	n := len(s.out)
+	s.segment(38, 2, nil, testGenericRegion(region, 5, 10, ComposeOr, 0, false))
+	binary.BigEndian.PutUint32(s.out[n+7:], 0xFFFFFFFF)
+	s.out = binary.BigEndian.AppendUint32(s.out, uint32(region.Height()))
+	s.segment(49, 2, nil, nil)
+	s.segment(51, 0, nil, nil)
+	return s.out
+}
+
+// grayCovered 检查部分解码页面的黑色像素均出现在最终页面中
+// 入参: part 部分解码的页面, final 最终页面
+// 返回: error 不一致时的说明
+func grayCovered(part image.Image, final image.Image) error {
+	if part.Bounds() != final.Bounds() {
+		return fmt.Errorf("bounds %v, want %v", part.Bounds(), final.Bounds())
+	}
+	p, f := part.(*image.Gray), final.(*image.Gray)
+	for i, v := range p.Pix {
+		if v == 0 && f.Pix[i] != 0 {
+			return fmt.Errorf("pixel %d painted black but white in the final page", i)
+		}
+	}
+	return nil
+}
+
+// feedProgressive 按给定分块写入数据并轮询, 返回全部页面
+// 每次需要数据时检查部分解码的页面不超出最终页面
+// 入参: t 测试对象, data 数据流, want 最终页面, chunk 下一次写入的字节数
+// 返回: []image.Image 页面
+func feedProgressive(t *testing.T, data []byte, want []image.Image, chunk func() int) []image.Image {
+	t.Helper()
+	pd := NewProgressiveDecoder(nil)
+	var got []image.Image
+	for off := 0; ; {
+		st, err := pd.Poll()
+		if err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		if st == ProgressEnd {
+			break
+		}
+		if st == ProgressPageReady {
+			got = append(got, pd.Page())
+			continue
+		}
+		// 下一页的页面信息到达之前Page仍返回上一页
+		if img := pd.Page(); img != nil && pd.doc.inPage && len(got) < len(want) {
+			if err := grayCovered(img, want[len(got)]); err != nil {
+				t.Fatalf("offset %d page %d: %v", off, len(got)+1, err)
+			}
+		}
+		if off == len(data) {
+			pd.Close()
+			continue
+		}
+		n := min(chunk(), len(data)-off)
+		if _, err := pd.Write(data[off : off+n]); err != nil {
+			t.Fatal(err)
+		}
+		off += n
+	}
+	return got
+}
+
+func TestProgressiveMatchesDecode(t *testing.T) {
+	data := testProgressiveStream()
+	want := decodeAllPages(t, data)
+	r := rand.New(rand.NewSource(44))
+	tests := []struct {
+		name  string
+		chunk func() int
+	}{
+		{"byte", func() int { return 1 }},
+		{"random", func() int { return 1 + r.Intn(64) }},
+		{"odd", func() int { return 37 }},
+		{"whole", func() int { return len(data) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := feedProgressive(t, data, want, tt.chunk)
+			if len(got) != len(want) {
+				t.Fatalf("%d pages, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if err := goImagesEqual(got[i], want[i]); err != nil {
+					t.Errorf("page %d: %v", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+func TestProgressiveCheckpoint(t *testing.T) {
+	r := rand.New(rand.NewSource(45))
+	region := randomImage(r, 200, 160, 40)
+	s := newTestStream(1)
+	s.segment(48, 1, nil, testPageInfo(200, 160, 0))
+	s.segment(38, 1, nil, testGenericRegion(region, 0, 0, ComposeOr, 1, false))
+	s.segment(49, 1, nil, nil)
+	data := s.out
+
+	pd := NewProgressiveDecoder(nil)
+	var early *arithCheckpoint
+	for off := 0; off < len(data); off++ {
+		pd.Write(data[off : off+1])
+		if st, err := pd.Poll(); err != nil || st != ProgressNeedData {
+			t.Fatalf("offset %d: status %d, error %v", off, st, err)
+		}
+		pr := pd.region
+		if pr == nil || pr.image == nil {
+			continue
+		}
+		// 暂停时检查点位于当前行, 之前的行已是最终结果, 之后的行尚未写入
+		rows := pr.grd.loopIndex
+		if pr.saved.loopIndex != rows {
+			t.Fatalf("offset %d: paused at row %d, checkpoint at row %d", off, rows, pr.saved.loopIndex)
+		}
+		if err := checkRegionRows(pr.image, region, rows); err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		if early == nil && rows >= 10 {
+			saved := pr.saved
+			saved.contexts = append([]ArithCtx(nil), saved.contexts...)
+			early = &saved
+		}
+		if early == nil || rows < early.loopIndex+30 {
+			continue
+		}
+		// 回退到较早的检查点, 其后的行被清除, 以完整数据重新解码的结果不变
+		pr.saved = *early
+		pr.restore(false)
+		if err := checkRegionRows(pr.image, region, early.loopIndex); err != nil {
+			t.Fatalf("after restore: %v", err)
+		}
+		pd.Write(data[off+1:])
+		pd.Close()
+		st, err := pd.Poll()
+		if err != nil || st != ProgressPageReady {
+			t.Fatalf("status %d, error %v", st, err)
+		}
+		if !pageEqual(pd.Page(), region) {
+			t.Error("page differs after restoring the checkpoint")
+		}
+		return
+	}
+	t.Fatal("region never paused far enough past a checkpoint")
+}
+
+// checkRegionRows 检查区域位图的前rows行与期望位图一致, 其余行为0
+// 入参: img 区域位图, want 期望位图, rows 已完成的行数
+// 返回: error 不一致时的说明
+func checkRegionRows(img, want *Image, rows uint32) error {
+	for y := int32(0); y < img.Height(); y++ {
+		for x := int32(0); x < img.Width(); x++ {
+			v := 0
+			if y < int32(rows) {
+				v = want.GetPixel(x, y)
+			}
+			if img.GetPixel(x, y) != v {
+				return fmt.Errorf("pixel (%d,%d) wrong with %d rows decoded", x, y, rows)
+			}
+		}
+	}
+	return nil
+}
+
+func TestProgressiveMaxData(t *testing.T) {
+	data := testProgressiveStream()
+	pd := NewProgressiveDecoder(nil)
+	big := make([]byte, progressMaxData+1)
+	if _, err := pd.Write(big); err == nil {
+		t.Fatal("oversized write accepted")
+	}
+	if _, err := pd.Write(data[:100]); err != nil {
+		t.Fatal(err)
+	}
+	// 累计超过上限的写入被拒绝, 已缓存的数据不受影响
+	if n, err := pd.Write(big[:progressMaxData-99]); err == nil || n != 0 {
+		t.Fatalf("write over the limit: n %d, error %v", n, err)
+	}
+	if _, err := pd.Write(data[100:]); err != nil {
+		t.Fatal(err)
+	}
+	pd.Close()
+	if _, err := pd.Write(data[:1]); err == nil {
+		t.Error("write after close accepted")
+	}
+	want := decodeAllPages(t, data)
+	for i := range want {
+		st, err := pd.Poll()
+		if err != nil || st != ProgressPageReady {
+			t.Fatalf("page %d: status %d, error %v", i+1, st, err)
+		}
+		if err := goImagesEqual(pd.Page(), want[i]); err != nil {
+			t.Errorf("page %d: %v", i+1, err)
+		}
+	}
+	if st, err := pd.Poll(); err != nil || st != ProgressEnd {
+		t.Errorf("status %d, error %v, want end", st, err)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTruncatedData 算术编码数据被截断, 已以0xFF填充
+	ErrTruncatedData = errors.New("arithmetic data truncated, padded with 0xff")
+	// ErrMissingSegment 段引用的段不存在
+	ErrMissingSegment = errors.New("referred segment not found")
+	// ErrSegmentFailed 段解码失败
+	ErrSegmentFailed = errors.New("segment decoding failed")
+	// ErrMissingEndOfPage 页面缺少页面结束段
+	ErrMissingEndOfPage = errors.New("page ended without end-of-page segment")
+)
+
+// Warning 恢复模式下修复或跳过的问题
+type Warning struct {
+	// Segment 段编号
+	Segment uint32
+	// Offset 段数据在输入数据中的字节偏移量, 数据流结束时页面未结束则为结束位置
+	Offset uint32
+	// Err 问题原因
+	Err error
+}
+
+// Error 返回警告描述
+// 返回: string 描述
+func (w Warning) Error() string {
+	return fmt.Sprintf("segment %d at offset %d: %v", w.Segment, w.Offset, w.Err)
+}
+
+// Unwrap 返回问题原因
+// 返回: error 原因
+func (w Warning) Unwrap() error {
+	return w.Err
+}
+
+// Warnings 获取最近一次解码页面时产生的警告
+// 数据流结束后仍返回最后一页的警告, 并包含其后产生的警告
+// 返回: []Warning 警告列表
+func (d *Decoder) Warnings() []Warning {
+	if d.doc == nil {
+		return nil
+	}
+	return d.doc.warnings
+}
+
+// setOptions 应用解码器选项
+// 入参: opts 解码器选项
+func (d *Document) setOptions(opts *DecoderOptions) {
+	d.opts = opts
+	d.stream.padArith = opts.Recover
+}
+
+// recovering 是否处于恢复模式
+// 返回: bool 是否恢复
+func (d *Document) recovering() bool {
+	return d.opts != nil && d.opts.Recover
+}
+
+// warn 记录并上报警告
+// 入参: number 段编号, offset 段数据偏移量, err 问题原因
+func (d *Document) warn(number, offset uint32, err error) {
+	w := Warning{Segment: number, Offset: d.baseOffset + offset, Err: err}
+	d.warnings = append(d.warnings, w)
+	if d.opts.Logger != nil {
+		d.opts.Logger.Warn("jbig2: recovered from damaged data", "segment", w.Segment, "offset", w.Offset, "err", err)
+	}
+	if d.opts.OnWarning != nil {
+		d.opts.OnWarning(w)
+	}
+}
+
+// missingReference 查找段引用的第一个不存在的段
+// 入参: segment 段对象
+// 返回: uint32 段编号, bool 是否存在缺失
+func (d *Document) missingReference(segment *Segment) (uint32, bool) {
+	for _, refNum := range segment.ReferredToSegmentNumbers {
+		if d.findSegment(refNum) == nil {
+			return refNum, true
+		}
+	}
+	return 0, false
+}
+
+// skipSegment 跳过当前段并定位到下一段段头
+// 数据长度未知的段无法定位下一段, 此时视为数据流结束
+func (d *Document) skipSegment() {
+	segment := d.segment
+	d.segment = nil
+	if segment.DataLength == 0xFFFFFFFF {
+		d.stream.SetOffset(d.stream.GetLength())
+		return
+	}
+	d.stream.SetOffset(uint32(min(uint64(d.offset)+uint64(segment.DataLength), uint64(d.stream.GetLength()))))
+}
+
+// recoverBefore 恢复模式下在解析段数据前检查段
+// 引用缺失段的段被跳过; 页面未结束时遇到新的页面信息段则结束当前页面, 该段留待下次解码
+// 返回: Result 结果, bool 是否已处理该段
+func (d *Document) recoverBefore() (Result, bool) {
+	segment := d.segment
+	if refNum, ok := d.missingReference(segment); ok {
+		d.warn(segment.Number, segment.DataOffset, fmt.Errorf("%w: %d", ErrMissingSegment, refNum))
+		d.skipSegment()
+		return ResultSuccess, true
+	}
+	if segment.Flags.Type == 48 && d.inPage && d.hasPage() {
+		d.warn(segment.Number, segment.DataOffset, ErrMissingEndOfPage)
+		d.inPage = false
+		return ResultPageCompleted, true
+	}
+	return ResultSuccess, false
+}
+
+// recoverAfter 恢复模式下处理段数据的解析结果
+// 解码失败的段被跳过, 算术数据截断时记录警告, 违反声明的配置不可恢复
+// 入参: ret 解析结果
+// 返回: Result 结果, bool 是否已处理该段
+func (d *Document) recoverAfter(ret Result) (Result, bool) {
+	segment := d.segment
+	truncated := d.stream.exhausted
+	d.stream.exhausted = false
+	if ret == ResultFailure && d.rowErr == nil && d.profileErr == nil {
+		d.warn(segment.Number, segment.DataOffset, d.failureCause())
+		d.skipSegment()
+		return ResultSuccess, true
+	}
+	if truncated && ret != ResultFailure {
+		d.warn(segment.Number, segment.DataOffset, ErrTruncatedData)
+	}
+	return ret, false
+}
+
+// failureCause 获取段解码失败的原因并清除
+// 返回: error 扩展段处理失败时为其原因, 否则为ErrSegmentFailed
+func (d *Document) failureCause() error {
+	if err := d.extErr; err != nil {
+		d.extErr = nil
+		return err
+	}
+	return ErrSegmentFailed
+}
+
+// warnUnterminatedPage 数据流结束时页面未结束, 记录缺失页面结束段的警告
+func (d *Document) warnUnterminatedPage() {
+	var number uint32
+	if n := len(d.segmentList); n > 0 {
+		number = d.segmentList[n-1].Number
+	}
+	d.warn(number, d.stream.GetOffset(), ErrMissingEndOfPage)
+}
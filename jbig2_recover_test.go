@@ -0,0 +1,199 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// recoverDecoder 创建启用恢复模式的解码器, 并收集回调收到的警告
+// 入参: t 测试对象, data 数据流, got 回调收到的警告
+// 返回: *Decoder 解码器
+func recoverDecoder(t *testing.T, data []byte, got *[]Warning) *Decoder {
+	t.Helper()
+	dec, err := NewDecoderWithOptions(bytes.NewReader(data), DecoderOptions{
+		Recover:   true,
+		OnWarning: func(w Warning) { *got = append(*got, w) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+// checkWarnings 比较警告的段编号、偏移量与原因
+// 入参: t 测试对象, got 警告, want 期望的警告
+func checkWarnings(t *testing.T, got, want []Warning) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("warnings %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Segment != want[i].Segment || got[i].Offset != want[i].Offset || !errors.Is(got[i].Err, want[i].Err) {
+			t.Errorf("warning %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// testRecoverStream 构造三页数据流, 每页一个通用区域, 最后一段为第3页的页面结束段
+// 返回: []byte 数据流, uint32 第3页区域的段编号
+func testRecoverStream() ([]byte, uint32) {
+	r := rand.New(rand.NewSource(44))
+	s := newTestStream(3)
+	var last uint32
+	for p := uint32(1); p <= 3; p++ {
+		s.segment(48, p, nil, testPageInfo(64, 32, 0))
+		last = s.segment(38, p, nil, testGenericRegion(randomImage(r, 64, 32, 8), 0, 0, ComposeOr, int(p), false))
+		s.segment(49, p, nil, nil)
+	}
+	return s.out, last
+}
+
+func TestRecoverTruncatedHeader(t *testing.T) {
+	full, last := testRecoverStream()
+	want := decodeAllPages(t, full)
+	// 截去页面结束段段头的后10个字节
+	data := full[:len(full)-10]
+
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := 1; p <= 2; p++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("page %d: %v", p, err)
+		}
+	}
+	if _, err := dec.Decode(); err == nil || err == io.EOF {
+		t.Fatalf("strict decode of the truncated page: error %v", err)
+	}
+
+	var got []Warning
+	dec = recoverDecoder(t, data, &got)
+	pages, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("%d pages, want 3", len(pages))
+	}
+	if err := goImagesEqual(pages[2], want[2]); err != nil {
+		t.Errorf("page 3: %v", err)
+	}
+	expect := []Warning{{Segment: last, Offset: uint32(len(data)), Err: ErrMissingEndOfPage}}
+	checkWarnings(t, got, expect)
+	// 数据流结束后仍保留最后一页的警告
+	checkWarnings(t, dec.Warnings(), expect)
+}
+
+func TestRecoverTruncatedArith(t *testing.T) {
+	r := rand.New(rand.NewSource(45))
+	img := randomImage(r, 96, 64, 16)
+	s := newTestStream(1)
+	s.segment(48, 1, nil, testPageInfo(96, 64, 0))
+	region := testGenericRegion(img, 0, 0, ComposeOr, 0, false)
+	num := s.segment(38, 1, nil, region)
+	offset := uint32(len(s.out) - len(region))
+	// 截去区域算术数据的后半部分, 页面结束段随之丢失
+	data := s.out[:len(s.out)-len(region)/2]
+
+	var got []Warning
+	dec := recoverDecoder(t, data, &got)
+	page, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Bounds() != image.Rect(0, 0, 96, 64) {
+		t.Errorf("bounds %v", page.Bounds())
+	}
+	checkWarnings(t, got, []Warning{
+		{Segment: num, Offset: offset, Err: ErrTruncatedData},
+		{Segment: num, Offset: uint32(len(data)), Err: ErrMissingEndOfPage},
+	})
+	checkWarnings(t, dec.Warnings(), got)
+}
+
+func TestRecoverMissingSegment(t *testing.T) {
+	r := rand.New(rand.NewSource(46))
+	img := randomImage(r, 64, 32, 8)
+	// 段编号从10开始, 文本区域引用不存在的段4
+	s := newTestStream(1)
+	s.num = 10
+	s.segment(48, 1, nil, testPageInfo(64, 32, 0))
+	s.segment(38, 1, nil, testGenericRegion(img, 0, 0, ComposeOr, 0, false))
+	text := testTextRegion(64, 32, 2, []testPlacement{{id: 1, x: 3, y: 4}})
+	num := s.segment(6, 1, []uint32{4}, text)
+	offset := uint32(len(s.out) - len(text))
+	s.segment(49, 1, nil, nil)
+
+	dec, err := NewDecoder(bytes.NewReader(s.out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err == nil {
+		t.Error("strict decode accepted a missing referred segment")
+	}
+
+	var got []Warning
+	dec = recoverDecoder(t, s.out, &got)
+	page, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 引用缺失段的文本区域被跳过, 页面只含通用区域
+	if !pageEqual(page, img) {
+		t.Error("page differs from the generic region")
+	}
+	checkWarnings(t, got, []Warning{{Segment: num, Offset: offset, Err: ErrMissingSegment}})
+}
+
+func TestRecoverMissingEndOfPage(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	imgs := []*Image{randomImage(r, 64, 32, 8), randomImage(r, 64, 32, 8)}
+	s := newTestStream(2)
+	s.segment(48, 1, nil, testPageInfo(64, 32, 0))
+	s.segment(38, 1, nil, testGenericRegion(imgs[0], 0, 0, ComposeOr, 0, false))
+	// 第1页缺少页面结束段, 第2页的页面信息段结束第1页
+	info := testPageInfo(64, 32, 0)
+	num := s.segment(48, 2, nil, info)
+	offset := uint32(len(s.out) - len(info))
+	s.segment(38, 2, nil, testGenericRegion(imgs[1], 0, 0, ComposeOr, 1, false))
+	s.segment(49, 2, nil, nil)
+
+	var got []Warning
+	dec := recoverDecoder(t, s.out, &got)
+	for p, img := range imgs {
+		page, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("page %d: %v", p+1, err)
+		}
+		if !pageEqual(page, img) {
+			t.Errorf("page %d differs", p+1)
+		}
+		// 警告属于产生它的页面, 开始新的页面时清除
+		if want := 1 - p; len(dec.Warnings()) != want {
+			t.Errorf("page %d: %d warnings, want %d", p+1, len(dec.Warnings()), want)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("error %v, want EOF", err)
+	}
+	checkWarnings(t, got, []Warning{{Segment: num, Offset: offset, Err: ErrMissingEndOfPage}})
+}
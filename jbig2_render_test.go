@@ -0,0 +1,211 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// boxFilter 逐像素计算盒式滤波缩小的灰度图像, 作为按位统计的参照
+// 目标像素覆盖源像素[dx*srcW/dstW, (dx+1)*srcW/dstW), 纵向同理
+// 入参: img 位图, dstW dstH 目标尺寸
+// 返回: *image.Gray 灰度图像
+func boxFilter(img *Image, dstW, dstH int) *image.Gray {
+	srcW, srcH := int(img.Width()), int(img.Height())
+	dstW, dstH = min(dstW, srcW), min(dstH, srcH)
+	dst := image.NewGray(image.Rect(0, 0, dstW, dstH))
+	for dy := 0; dy < dstH; dy++ {
+		y0, y1 := dy*srcH/dstH, (dy+1)*srcH/dstH
+		for dx := 0; dx < dstW; dx++ {
+			x0, x1 := dx*srcW/dstW, (dx+1)*srcW/dstW
+			black := 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					black += img.GetPixel(int32(x), int32(y))
+				}
+			}
+			area := float64((x1 - x0) * (y1 - y0))
+			dst.SetGray(dx, dy, color.Gray{Y: uint8(255 - math.Round(float64(black)*255/area))})
+		}
+	}
+	return dst
+}
+
+// grayEqual 比较两个灰度图像
+// 入参: got want 灰度图像
+// 返回: error 不一致时的说明
+func grayEqual(got, want *image.Gray) error {
+	if got.Bounds() != want.Bounds() {
+		return fmt.Errorf("bounds %v, want %v", got.Bounds(), want.Bounds())
+	}
+	r := want.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if g, w := got.GrayAt(x, y).Y, want.GrayAt(x, y).Y; g != w {
+				return fmt.Errorf("pixel (%d,%d) = %d, want %d", x, y, g, w)
+			}
+		}
+	}
+	return nil
+}
+
+func TestToGrayScaled(t *testing.T) {
+	r := rand.New(rand.NewSource(39))
+	sizes := []struct{ w, h int32 }{{1, 1}, {13, 7}, {100, 37}, {203, 150}}
+	for _, sz := range sizes {
+		img := noiseImage(r, sz.w, sz.h)
+		w, h := int(sz.w), int(sz.h)
+		// 整数倍、非整数倍、不缩放与超出源尺寸的目标
+		targets := [][2]int{{1, 1}, {w, h}, {max(1, w/2), max(1, h/2)}, {max(1, w*2/3), max(1, h*3/7)}, {max(1, w/7), h}, {w * 2, h + 5}}
+		for _, dst := range targets {
+			want := boxFilter(img, dst[0], dst[1])
+			if err := grayEqual(img.ToGrayScaled(dst[0], dst[1]), want); err != nil {
+				t.Errorf("%dx%d -> %dx%d: %v", w, h, dst[0], dst[1], err)
+			}
+		}
+	}
+	if img := NewImage(8, 8); img.ToGrayScaled(0, 4) != nil || img.ToGrayScaled(4, -1) != nil {
+		t.Error("non-positive target size should give nil")
+	}
+}
+
+func TestTiledToGrayScaled(t *testing.T) {
+	r := rand.New(rand.NewSource(40))
+	src := noiseImage(r, 45, 33)
+	for _, def := range []bool{false, true} {
+		for _, op := range []ComposeOp{ComposeOr, ComposeXor} {
+			// 区域只覆盖部分块, 其余块保持未分配
+			tiled := NewTiledImage(150, 110, 32, def)
+			tiled.ComposeFrom(70, 40, src, op)
+			flat := NewImage(150, 110)
+			flat.Fill(def)
+			flat.ComposeFrom(70, 40, src, op)
+			for _, dst := range [][2]int{{150, 110}, {75, 55}, {41, 29}, {17, 110}, {1, 1}} {
+				got, err := tiled.ToGrayScaled(dst[0], dst[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := grayEqual(got, boxFilter(flat, dst[0], dst[1])); err != nil {
+					t.Errorf("default %v op %d -> %dx%d: %v", def, op, dst[0], dst[1], err)
+				}
+			}
+		}
+	}
+}
+
+// testRenderStream 构造由若干通用区域组成的单页数据流
+// 返回: []byte 数据流
+func testRenderStream() []byte {
+	r := rand.New(rand.NewSource(41))
+	s := newTestStream(1)
+	s.segment(48, 1, nil, testPageInfo(150, 97, 0))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 150, 97, 30), 0, 0, ComposeOr, 0, false))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 60, 40, 8), 70, 50, ComposeXor, 1, true))
+	s.segment(49, 1, nil, nil)
+	return s.out
+}
+
+func TestDecodeScaled(t *testing.T) {
+	data := testRenderStream()
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	page := dec.doc.page.Duplicate()
+	tests := []struct {
+		name       string
+		maxW, maxH int
+		scale      float64
+		w, h       int
+	}{
+		// 150x97缩小到宽60时比例为0.4, 高度取整为38
+		{"width bound", 60, 0, 0, 60, 38},
+		// 高度比例40/97更小, 宽度取整为61
+		{"height bound", 100, 40, 0, 61, 40},
+		{"scale 0.3", 0, 0, 0.3, 45, 29},
+		{"scale 0.77", 0, 0, 0.77, 116, 75},
+		{"scale 0.01", 0, 0, 0.01, 2, 1},
+	}
+	for _, tiled := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s tiled %v", tt.name, tiled), func(t *testing.T) {
+				dec, err := NewDecoder(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tiled {
+					dec.SetTileSize(32)
+				}
+				var img image.Image
+				if tt.scale > 0 {
+					img, err = dec.DecodeWithOptions(RenderOptions{Scale: tt.scale})
+				} else {
+					img, err = dec.DecodeScaled(tt.maxW, tt.maxH)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				gray, ok := img.(*image.Gray)
+				if !ok {
+					t.Fatalf("got %T, want *image.Gray", img)
+				}
+				if err := grayEqual(gray, boxFilter(page, tt.w, tt.h)); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeScaledNoop(t *testing.T) {
+	data := testRenderStream()
+	want := decodeAllPages(t, data)[0]
+	for _, decode := range []func(*Decoder) (image.Image, error){
+		func(d *Decoder) (image.Image, error) { return d.DecodeScaled(200, 0) },
+		func(d *Decoder) (image.Image, error) { return d.DecodeScaled(0, 0) },
+		func(d *Decoder) (image.Image, error) { return d.DecodeWithOptions(RenderOptions{}) },
+		func(d *Decoder) (image.Image, error) { return d.DecodeWithOptions(RenderOptions{Scale: 1}) },
+	} {
+		dec, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := decode(dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := goImagesEqual(img, want); err != nil {
+			t.Error(err)
+		}
+	}
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scale := range []float64{-0.5, math.NaN()} {
+		if _, err := dec.DecodeWithOptions(RenderOptions{Scale: scale}); err == nil {
+			t.Errorf("scale %v: expected error", scale)
+		}
+	}
+}
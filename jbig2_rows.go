@@ -0,0 +1,192 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import "errors"
+
+// ErrRefinedRowsFlushed 行输出模式下细化区域以已输出的页面行为参考
+// 页面信息未标记可能含有细化时, 条带结束即丢弃已输出的行, 无法再作为细化参考
+var ErrRefinedRowsFlushed = errors.New("refinement region refers to page rows already written")
+
+// RowFormat 行数据格式
+type RowFormat int
+
+const (
+	// RowPacked 每像素1位, 高位在前, 1为黑色, 行尾填充位为0
+	RowPacked RowFormat = iota
+	// RowGray 每像素8位灰度, 0为黑色, 255为白色
+	RowGray
+)
+
+// RowWriter 接收已完成的页面行
+type RowWriter interface {
+	// WriteRow 写入一行, row仅在调用期间有效
+	WriteRow(y int, row []byte) error
+}
+
+// RowWriterFunc 函数形式的RowWriter
+type RowWriterFunc func(y int, row []byte) error
+
+// WriteRow 写入一行
+// 入参: y 行号, row 行数据
+// 返回: error 错误信息
+func (f RowWriterFunc) WriteRow(y int, row []byte) error {
+	return f(y, row)
+}
+
+// DecodeRows 解码下一页并按行输出
+// 条带页面在每个条带结束段到达时输出该条带以上的行并丢弃, 页面缓冲区只保留未完成的条带
+// 非条带页面与标记可能含有细化的页面在页面结束时一次输出全部行
+// 未标记可能含有细化的页面若细化已输出的行, 返回ErrRefinedRowsFlushed
+// 行数据不保存颜色, 页面使用颜色扩展区域时在下一次输出前返回ErrColourUnsupported
+// 入参: w 行输出, format 行数据格式
+// 返回: error 错误信息, 包含w返回的错误
+func (d *Decoder) DecodeRows(w RowWriter, format RowFormat) error {
+	if d.doc == nil {
+		return errors.New("decoder not initialized")
+	}
+	if w == nil {
+		return errors.New("nil row writer")
+	}
+	if format != RowPacked && format != RowGray {
+		return errors.New("invalid row format")
+	}
+	d.doc.rowSink, d.doc.rowFormat, d.doc.rowErr = w, format, nil
+	defer func() { d.doc.rowSink = nil }()
+	err := d.decodePage()
+	if d.doc.rowErr != nil {
+		return d.doc.rowErr
+	}
+	if err != nil {
+		return err
+	}
+	if d.doc.page == nil {
+		return errors.New("page not decoded into rows")
+	}
+	if err := d.doc.colourErr(); err != nil {
+		return err
+	}
+	return d.doc.flushRows(d.doc.pageHeight)
+}
+
+// parseEndOfStripe 解析条带结束段
+// 入参: segment 段对象
+// 返回: Result 解析结果
+func (d *Document) parseEndOfStripe(segment *Segment) Result {
+	if segment.DataLength < 4 {
+		d.stream.AddOffset(segment.DataLength)
+		return ResultSuccess
+	}
+	var endRow uint32
+	if val, err := d.stream.ReadInteger(); err != nil {
+		return ResultFailure
+	} else {
+		endRow = val
+	}
+	d.stream.AddOffset(segment.DataLength - 4)
+	if d.rowSink == nil || !d.inPage || d.page == nil {
+		return ResultSuccess
+	}
+	// 可能含有细化的页面保留全部行, 以便细化区域引用之前的条带
+	if pi := d.currentPageInfo(); pi == nil || pi.MightContainRefinement {
+		return ResultSuccess
+	}
+	if err := d.colourErr(); err != nil {
+		d.rowErr = err
+		return ResultFailure
+	}
+	upTo := min(uint64(endRow)+1, uint64(d.pageHeight))
+	if err := d.flushRows(uint32(upTo)); err != nil {
+		d.rowErr = err
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// flushRows 输出页面缓冲区中位于upTo以上的行并从缓冲区丢弃
+// 入参: upTo 结束行(不含)
+// 返回: error 错误信息
+func (d *Document) flushRows(upTo uint32) error {
+	pi := d.currentPageInfo()
+	origin := uint32(d.pageOrigin.Y)
+	if pi == nil || upTo <= origin {
+		return nil
+	}
+	width := int(pi.Width)
+	stride := int(d.page.stride)
+	var fill byte
+	if pi.DefaultPixelValue {
+		fill = 0xff
+	}
+	out := make([]byte, max(stride, width))
+	for y := origin; y < upTo; y++ {
+		local := int(y - origin)
+		var src []byte
+		if local < int(d.page.height) {
+			src = d.page.data[local*stride : (local+1)*stride]
+		}
+		var row []byte
+		if d.rowFormat == RowGray {
+			row = out[:width]
+			for x := range row {
+				bit := fill
+				if src != nil {
+					bit = src[x>>3] << (x & 7) & 0x80
+				}
+				if bit != 0 {
+					row[x] = 0
+				} else {
+					row[x] = 255
+				}
+			}
+		} else {
+			row = out[:stride]
+			if src != nil {
+				copy(row, src)
+			} else {
+				for x := range row {
+					row[x] = fill
+				}
+			}
+			if rem := width & 7; rem != 0 {
+				row[stride-1] &= 0xff << (8 - rem)
+			}
+		}
+		if err := d.rowSink.WriteRow(int(y), row); err != nil {
+			return err
+		}
+	}
+	d.page.dropRows(int32(min(upTo-origin, uint32(d.page.height))), pi.DefaultPixelValue)
+	d.pageOrigin.Y = int(upTo)
+	return nil
+}
+
+// dropRows 丢弃顶部若干行, 其余行上移, 底部以默认像素补齐
+// 入参: n 行数, defaultPixel 默认像素
+func (i *Image) dropRows(n int32, defaultPixel bool) {
+	if n <= 0 {
+		return
+	}
+	n = min(n, i.height)
+	copy(i.data, i.data[n*i.stride:i.height*i.stride])
+	tail := i.data[(i.height-n)*i.stride : i.height*i.stride]
+	fill := byte(0)
+	if defaultPixel {
+		fill = 0xff
+	}
+	for j := range tail {
+		tail[j] = fill
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// testStripedStream 构造宽70、条带高16、共三个条带的单页数据流
+// 三个条带分别以第15、31、40行结束, refine为真时第三个条带先细化第一个条带中的页面区域
+// 入参: height 页面高度(0xFFFFFFFF表示未知), flags 页面标志, refine 是否细化
+// 返回: []byte 数据流, []int 各条带结束段与页面结束段之后的段数据偏移(不含文件头)
+func testStripedStream(height uint32, flags byte, refine bool) ([]byte, []int) {
+	r := rand.New(rand.NewSource(42))
+	s := newTestStream(1)
+	header := len(s.out)
+	info := testPageInfo(70, height, flags)
+	binary.BigEndian.PutUint16(info[len(info)-2:], 0x8000|16)
+	s.segment(48, 1, nil, info)
+	var ends []int
+	endOfStripe := func(row uint32) {
+		s.segment(50, 1, nil, binary.BigEndian.AppendUint32(nil, row))
+		ends = append(ends, len(s.out)-header)
+	}
+	first := randomImage(r, 70, 16, 10)
+	s.segment(38, 1, nil, testGenericRegion(first, 0, 0, ComposeOr, 0, false))
+	endOfStripe(15)
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 70, 16, 10), 0, 16, ComposeOr, 1, false))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 50, 16, 6), 10, 16, ComposeXor, 2, true))
+	endOfStripe(31)
+	if refine {
+		ref := first.SubImage(5, 4, 30, 8)
+		img := ref.Duplicate()
+		for i := 0; i < 20; i++ {
+			img.SetPixel(r.Int31n(30), r.Int31n(8), r.Intn(2))
+		}
+		s.segment(42, 1, nil, testRefinementRegion(img, ref, 5, 4, false, false))
+	}
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 70, 9, 6), 0, 32, ComposeOr, 3, false))
+	endOfStripe(40)
+	s.segment(49, 1, nil, nil)
+	ends = append(ends, len(s.out)-header)
+	return s.out, ends
+}
+
+// testRow 行输出记录
+type testRow struct {
+	y      int
+	data   []byte
+	offset uint32
+}
+
+// decodeRowsRecorded 按行解码第一页, 并记录每行输出时的数据流偏移
+// 入参: data 数据流, format 行数据格式
+// 返回: []testRow 按输出顺序的行, error 错误信息
+func decodeRowsRecorded(data []byte, format RowFormat) ([]testRow, error) {
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var rows []testRow
+	err = dec.DecodeRows(RowWriterFunc(func(y int, row []byte) error {
+		rows = append(rows, testRow{y: y, data: bytes.Clone(row), offset: dec.doc.stream.GetOffset()})
+		return nil
+	}), format)
+	return rows, err
+}
+
+// checkRows 将输出的行与完整解码的页面比较
+// 入参: rows 输出的行, page 页面, format 行数据格式
+// 返回: error 不一致时的说明
+func checkRows(rows []testRow, page *Image, format RowFormat) error {
+	if len(rows) != int(page.Height()) {
+		return fmt.Errorf("%d rows, want %d", len(rows), page.Height())
+	}
+	gray := page.ToGoImage().(*image.Gray)
+	for i, row := range rows {
+		if row.y != i {
+			return fmt.Errorf("row %d written as row %d", i, row.y)
+		}
+		var want []byte
+		if format == RowGray {
+			want = gray.Pix[i*gray.Stride : i*gray.Stride+int(page.Width())]
+		} else {
+			want = page.data[i*int(page.stride) : (i+1)*int(page.stride)]
+		}
+		if !bytes.Equal(row.data, want) {
+			return fmt.Errorf("row %d differs", i)
+		}
+	}
+	return nil
+}
+
+func TestDecodeRowsStriped(t *testing.T) {
+	for _, height := range []uint32{0xFFFFFFFF, 41} {
+		for _, format := range []RowFormat{RowPacked, RowGray} {
+			data, ends := testStripedStream(height, 0, false)
+			want := decodeFirstPage(t, data)
+			rows, err := decodeRowsRecorded(data, format)
+			if err != nil {
+				t.Fatalf("height %#x format %d: %v", height, format, err)
+			}
+			if err := checkRows(rows, want, format); err != nil {
+				t.Fatalf("height %#x format %d: %v", height, format, err)
+			}
+			// 条带结束段输出到结束行(含)为止的行, 之后的条带尚未解码
+			for _, row := range rows {
+				stripe := min(row.y/16, 2)
+				if row.offset != uint32(ends[stripe]) {
+					t.Errorf("height %#x format %d: row %d written at offset %d, want %d after stripe %d", height, format, row.y, row.offset, ends[stripe], stripe)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeRowsRefinement(t *testing.T) {
+	// 标记可能含有细化的页面保留全部行, 在页面结束时输出
+	data, ends := testStripedStream(0xFFFFFFFF, 0x02, true)
+	want := decodeFirstPage(t, data)
+	rows, err := decodeRowsRecorded(data, RowPacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRows(rows, want, RowPacked); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if row.offset != uint32(ends[len(ends)-1]) {
+			t.Fatalf("row %d written at offset %d before the page ended", row.y, row.offset)
+		}
+	}
+
+	// 未标记时细化区域引用的行已经输出并丢弃
+	data, _ = testStripedStream(0xFFFFFFFF, 0, true)
+	rows, err = decodeRowsRecorded(data, RowPacked)
+	if !errors.Is(err, ErrRefinedRowsFlushed) {
+		t.Fatalf("error = %v, want %v", err, ErrRefinedRowsFlushed)
+	}
+	if len(rows) != 32 {
+		t.Errorf("%d rows written before the error, want 32", len(rows))
+	}
+}
+
+func TestDecodeRowsWriterError(t *testing.T) {
+	data, _ := testStripedStream(0xFFFFFFFF, 0, false)
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	last := -1
+	err = dec.DecodeRows(RowWriterFunc(func(y int, row []byte) error {
+		last = y
+		if y == 20 {
+			return stop
+		}
+		return nil
+	}), RowGray)
+	if err != stop || last != 20 {
+		t.Errorf("error %v after row %d, want %v after row 20", err, last, stop)
+	}
+}
+
+// decodeFirstPage 以新建的解码器解码第一页并复制页面位图
+// 入参: t 测试对象, data 数据流
+// 返回: *Image 页面位图
+func decodeFirstPage(t *testing.T, data []byte) *Image {
+	t.Helper()
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return dec.doc.page.Duplicate()
+}
@@ -47,24 +47,21 @@ func NewSDDProc() *SDDProc {
 // 入参: arithDecoder 算术解码器, gbContexts 通用上下文, grContexts 细化上下文
 // 返回: *SymbolDict 符号字典, error 错误信息
 func (s *SDDProc) DecodeArith(arithDecoder *ArithDecoder, gbContexts, grContexts []ArithCtx) (*SymbolDict, error) {
-	IADH := NewArithIntDecoder()
-	IADW := NewArithIntDecoder()
-	IAAI := NewArithIntDecoder()
-	IARDX := NewArithIntDecoder()
-	IARDY := NewArithIntDecoder()
-	IAEX := NewArithIntDecoder()
-	IADT := NewArithIntDecoder()
-	IAFS := NewArithIntDecoder()
-	IADS := NewArithIntDecoder()
-	IAIT := NewArithIntDecoder()
-	IARI := NewArithIntDecoder()
-	IARDW := NewArithIntDecoder()
-	IARDH := NewArithIntDecoder()
+	IADH := getArithIntDecoder()
+	defer putArithIntDecoder(IADH)
+	IADW := getArithIntDecoder()
+	defer putArithIntDecoder(IADW)
+	IAAI := getArithIntDecoder()
+	defer putArithIntDecoder(IAAI)
+	IAEX := getArithIntDecoder()
+	defer putArithIntDecoder(IAEX)
 	SBSYMCODELENA := uint8(0)
 	for (uint32(1) << SBSYMCODELENA) < (s.SDNUMINSYMS + s.SDNUMNEWSYMS) {
 		SBSYMCODELENA++
 	}
-	IAID := NewArithIaidDecoder(SBSYMCODELENA)
+	ids := getIntDecoderState(SBSYMCODELENA)
+	defer putIntDecoderState(ids)
+	IARDX, IARDY, IAID := ids.IARDX, ids.IARDY, ids.IAID
 	SDNEWSYMS := make([]*Image, s.SDNUMNEWSYMS)
 	HCHEIGHT := uint32(0)
 	NSYMSDECODED := uint32(0)
@@ -140,11 +137,6 @@ func (s *SDDProc) DecodeArith(arithDecoder *ArithDecoder, gbContexts, grContexts
 					pDecoder.SBDSOFFSET = 0
 					pDecoder.SBRTEMPLATE = s.SDRTEMPLATE
 					pDecoder.SBRAT = s.SDRAT
-					ids := &IntDecoderState{
-						IADT: IADT, IAFS: IAFS, IADS: IADS, IAIT: IAIT,
-						IARI: IARI, IARDW: IARDW, IARDH: IARDH, IARDX: IARDX, IARDY: IARDY,
-						IAID: IAID,
-					}
 					var err error
 					BS, err = pDecoder.DecodeArith(arithDecoder, grContexts, ids)
 					if err != nil {
@@ -421,10 +413,11 @@ func (s *SDDProc) DecodeHuffman(stream *BitStream, gbContexts, grContexts []Arit
 				pGRD.GBH = HCHEIGHT
 				if !pGRD.MMR {
 					pGRD.GBAT = [8]int8{0, 0, 0, 0, 0, 0, 0, 0}
-					gbContexts := make([]ArithCtx, 65536)
+					gbContexts := getContexts(65536)
 					arithDecoder := NewArithDecoder(stream)
 					var err error
 					BHC, err = pGRD.DecodeArith(arithDecoder, gbContexts)
+					putContexts(gbContexts)
 					if err != nil {
 						return nil, err
 					}
@@ -452,6 +445,7 @@ func (s *SDDProc) DecodeHuffman(stream *BitStream, gbContexts, grContexts []Arit
 					SDNEWSYMS[idx] = BHC.SubImage(int32(nTmp), 0, int32(SDNEWSYMWIDTHS[idx]), int32(HCHEIGHT))
 					nTmp += SDNEWSYMWIDTHS[idx]
 				}
+				BHC.release()
 			}
 		}
 	}
@@ -40,7 +40,8 @@ const (
 type SegmentFlags struct {
 	Type                uint8
 	PageAssociationSize bool
-	DeferredNonRetain   bool
+	// DeferredNonRetain 延迟不保留标志(T.88 7.2.3段头标志第7位), 被释放时推迟到当前页面结束
+	DeferredNonRetain bool
 }
 
 // Segment 段结构
@@ -49,10 +50,13 @@ type Segment struct {
 	Flags                    SegmentFlags
 	ReferredToSegmentCount   int32
 	ReferredToSegmentNumbers []uint32
+	Retain                   bool
+	ReferredRetain           []bool
 	PageAssociation          uint32
 	DataLength               uint32
 	HeaderLength             uint32
 	DataOffset               uint32
+	RegionInfo               RegionInfo
 	Key                      uint64
 	State                    JBig2SegmentState
 	ResultType               JBig2ResultType
@@ -60,8 +64,6 @@ type Segment struct {
 	PatternDict              *PatternDict
 	Image                    *Image
 	HuffmanTable             *HuffmanTable
-	GBContexts               []ArithCtx
-	GRContexts               []ArithCtx
 }
 
 // NewSegment 创建段对象
@@ -72,3 +74,27 @@ func NewSegment() *Segment {
 		ResultType: JBig2VoidPointer,
 	}
 }
+
+// setRetentionFlags 设置段保留标志
+// 入参: retention 保留标志字节, 第0位为本段, 其后依次为各被引用段
+func (s *Segment) setRetentionFlags(retention []byte) {
+	bit := func(i int32) bool {
+		if int(i>>3) >= len(retention) {
+			return false
+		}
+		return (retention[i>>3]>>(i&7))&1 != 0
+	}
+	s.Retain = bit(0)
+	s.ReferredRetain = make([]bool, s.ReferredToSegmentCount)
+	for i := int32(0); i < s.ReferredToSegmentCount; i++ {
+		s.ReferredRetain[i] = bit(i + 1)
+	}
+}
+
+// releaseData 释放段解码结果
+func (s *Segment) releaseData() {
+	s.Image = nil
+	s.PatternDict = nil
+	s.SymbolDict = nil
+	s.HuffmanTable = nil
+}
@@ -0,0 +1,779 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image/color"
+	"slices"
+)
+
+// kSnapshotMagic 快照标识
+var kSnapshotMagic = []byte("JB2SNAP")
+
+// kSnapshotVersion 快照格式版本
+const kSnapshotVersion = 5
+
+// kSnapshotMaxContexts 快照中单个上下文集合的最大长度, 与通用区域模板0的上下文数相同
+const kSnapshotMaxContexts = 65536
+
+// Snapshot 将解码器状态序列化为快照
+// 快照只能在页面之间生成, 包含数据流位置、保留段及其符号字典、模式字典、霍夫曼表与保留的算术上下文, 以及引用的全局段
+// 快照不包含页面位图、日志记录器、警告回调与区域并发设置
+// 返回: []byte 快照数据, error 错误信息
+func (d *Decoder) Snapshot() ([]byte, error) {
+	if d.doc == nil {
+		return nil, errors.New("decoder not initialized")
+	}
+	doc := d.doc
+	if doc.inPage {
+		return nil, errors.New("snapshot only available between pages")
+	}
+	consumed := max(doc.stream.GetOffset(), doc.offset, doc.groupedOffset)
+	if consumed > doc.stream.GetLength() {
+		return nil, errors.New("stream position out of range")
+	}
+	w := &snapshotWriter{buf: append([]byte(nil), kSnapshotMagic...)}
+	w.uint(kSnapshotVersion)
+	w.uint(uint64(consumed))
+	w.uint(uint64(crc32.ChecksumIEEE(doc.stream.data[:consumed])))
+	w.config(d.config)
+	w.options(&d.opts)
+	w.probe(&d.probe)
+	w.uint(uint64(d.pageIndex))
+	w.uint(uint64(doc.stream.GetOffset()))
+	w.uint(uint64(doc.offset))
+	w.uint(uint64(len(doc.pageInfoList)))
+	for _, pi := range doc.pageInfoList {
+		w.pageInfo(pi)
+	}
+	w.segments(doc)
+	w.bool(doc.groupedParsed)
+	w.uint(uint64(doc.groupedOffset))
+	w.bool(d.opts.Globals != nil)
+	if d.opts.Globals != nil {
+		w.segments(d.opts.Globals.doc)
+	}
+	return w.buf, nil
+}
+
+// RestoreDecoder 从快照恢复解码器
+// data须为生成快照时的同一数据流, 已解码部分的校验和不符时返回错误
+// 恢复的解码器从快照所在页面之后继续解码, 快照中的全局段替代创建时引用的全局段
+// 函数类型的选项(Logger、OnWarning与Extensions)不保存在快照中, 恢复的解码器只使用RegisterExtension注册的处理函数
+// 入参: data 数据, snapshot 快照数据
+// 返回: *Decoder 解码器, error 错误信息
+func RestoreDecoder(data, snapshot []byte) (*Decoder, error) {
+	if !bytes.HasPrefix(snapshot, kSnapshotMagic) {
+		return nil, errors.New("invalid snapshot")
+	}
+	r := &snapshotReader{data: snapshot[len(kSnapshotMagic):]}
+	if r.uint() != kSnapshotVersion {
+		return nil, errors.New("unsupported snapshot version")
+	}
+	consumed, sum := r.uint(), r.uint()
+	dec := &Decoder{}
+	dec.config = r.config()
+	dec.opts = r.options()
+	dec.probe = r.probe()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if dec.config.offset > len(data) || consumed > uint64(len(data)-dec.config.offset) ||
+		uint64(crc32.ChecksumIEEE(data[dec.config.offset:][:consumed])) != sum {
+		return nil, errors.New("snapshot does not match data")
+	}
+	cfg := dec.config
+	doc := NewDocument(data[cfg.offset:], nil, cfg.randomAccess, cfg.littleEndian)
+	doc.OrgMode = cfg.orgMode
+	doc.Grouped = cfg.grouped
+	doc.baseOffset = uint32(cfg.offset)
+	dec.doc = doc
+	dec.pageIndex = uint32(r.uint())
+	streamOffset := uint32(r.uint())
+	doc.offset = uint32(r.uint())
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		doc.pageInfoList = append(doc.pageInfoList, r.pageInfo())
+	}
+	r.segments(doc)
+	doc.groupedParsed = r.bool()
+	doc.groupedOffset = uint32(r.uint())
+	if r.bool() {
+		globals := &Globals{doc: &Document{stream: NewBitStream(nil, 0), isGlobal: true}}
+		r.segments(globals.doc)
+		dec.opts.Globals = globals
+		doc.globalContext = globals.doc
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if uint64(max(streamOffset, doc.offset, doc.groupedOffset)) != consumed {
+		return nil, errors.New("invalid snapshot")
+	}
+	doc.stream.SetOffset(streamOffset)
+	doc.setOptions(&dec.opts)
+	return dec, nil
+}
+
+// snapshotWriter 快照写入器
+type snapshotWriter struct {
+	buf []byte
+}
+
+// uint 写入无符号整数
+// 入参: v 值
+func (w *snapshotWriter) uint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+// int 写入有符号整数
+// 入参: v 值
+func (w *snapshotWriter) int(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+// bool 写入布尔值
+// 入参: v 值
+func (w *snapshotWriter) bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// bytes 写入字节序列
+// 入参: b 字节序列
+func (w *snapshotWriter) bytes(b []byte) {
+	w.uint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// config 写入数据流配置
+// 入参: cfg 数据流配置
+func (w *snapshotWriter) config(cfg streamConfig) {
+	w.uint(uint64(cfg.offset))
+	w.bool(cfg.randomAccess)
+	w.bool(cfg.littleEndian)
+	w.int(int64(cfg.orgMode))
+	w.bool(cfg.grouped)
+	w.uint(uint64(cfg.pageCount))
+	w.bool(cfg.hasPageCount)
+}
+
+// options 写入可序列化的解码器选项
+// 入参: opts 解码器选项
+func (w *snapshotWriter) options(opts *DecoderOptions) {
+	w.bool(opts.Recover)
+	w.int(int64(opts.Organization))
+	w.byteOrder(opts.ByteOrder)
+	w.int(int64(opts.Header))
+	w.int(int64(opts.PageCount))
+	w.bool(opts.Strict)
+	w.bool(opts.EnforceProfiles)
+	keys := make([]uint32, 0, len(opts.ProfileFeatures))
+	for k := range opts.ProfileFeatures {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	w.uint(uint64(len(keys)))
+	for _, k := range keys {
+		w.uint(uint64(k))
+		w.uint(uint64(opts.ProfileFeatures[k]))
+	}
+	w.uint(uint64(len(opts.Palette)))
+	for _, c := range opts.Palette {
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		w.buf = append(w.buf, rgba.R, rgba.G, rgba.B, rgba.A)
+	}
+}
+
+// probe 写入探测报告
+// 入参: p 探测报告
+func (w *snapshotWriter) probe(p *ProbeReport) {
+	w.bool(p.Explicit)
+	w.uint(uint64(len(p.Candidates)))
+	for _, c := range p.Candidates {
+		w.int(int64(c.Offset))
+		w.bool(c.RandomAccess)
+		w.bool(c.LittleEndian)
+		w.int(int64(c.OrgMode))
+		w.bool(c.Grouped)
+		w.int(int64(c.Score))
+		w.bool(c.Skipped)
+	}
+	w.int(int64(p.Chosen))
+	w.bool(p.Fallback)
+	w.int(int64(p.Organization))
+	w.byteOrder(p.ByteOrder)
+	w.int(int64(p.Offset))
+}
+
+// byteOrder 写入字节序
+// 入参: order 字节序, 可为nil
+func (w *snapshotWriter) byteOrder(order binary.ByteOrder) {
+	switch {
+	case order == nil:
+		w.uint(0)
+	case isLittleEndian(order):
+		w.uint(2)
+	default:
+		w.uint(1)
+	}
+}
+
+// pageInfo 写入页面信息
+// 入参: pi 页面信息
+func (w *snapshotWriter) pageInfo(pi *PageInfo) {
+	w.uint(uint64(pi.Width))
+	w.uint(uint64(pi.Height))
+	w.uint(uint64(pi.ResolutionX))
+	w.uint(uint64(pi.ResolutionY))
+	w.bool(pi.EventuallyLossless)
+	w.bool(pi.MightContainRefinement)
+	w.bool(pi.DefaultPixelValue)
+	w.int(int64(pi.DefaultComposeOp))
+	w.bool(pi.RequiresAuxBuffers)
+	w.bool(pi.ComposeOpOverridden)
+	w.bool(pi.IsStriped)
+	w.uint(uint64(pi.MaxStripeSize))
+}
+
+// segments 写入文档的段列表、待解析段、分组解码队列、声明的配置与文档注释
+// 同一段只写入一次, 列表与队列以段序号引用
+// 入参: doc 文档对象
+func (w *snapshotWriter) segments(doc *Document) {
+	index := make(map[*Segment]int)
+	var all []*Segment
+	add := func(seg *Segment) {
+		if _, ok := index[seg]; !ok {
+			index[seg] = len(all)
+			all = append(all, seg)
+		}
+	}
+	for _, seg := range doc.segmentList {
+		add(seg)
+	}
+	for _, seg := range doc.groupedQueue {
+		add(seg)
+	}
+	if doc.segment != nil {
+		add(doc.segment)
+	}
+	w.uint(uint64(len(all)))
+	for _, seg := range all {
+		w.segment(seg)
+	}
+	w.uint(uint64(len(doc.segmentList)))
+	for _, seg := range doc.segmentList {
+		w.uint(uint64(index[seg]))
+	}
+	w.uint(uint64(len(doc.groupedQueue)))
+	for _, seg := range doc.groupedQueue {
+		w.uint(uint64(index[seg]))
+	}
+	w.bool(doc.segment != nil)
+	if doc.segment != nil {
+		w.uint(uint64(index[doc.segment]))
+	}
+	w.uint(uint64(len(doc.profiles)))
+	for _, p := range doc.profiles {
+		w.uint(uint64(p))
+	}
+	w.uint(uint64(len(doc.comments)))
+	for _, c := range doc.comments {
+		w.bytes([]byte(c.Name))
+		w.bytes([]byte(c.Value))
+	}
+}
+
+// segment 写入段头与段结果
+// 入参: seg 段对象
+func (w *snapshotWriter) segment(seg *Segment) {
+	w.uint(uint64(seg.Number))
+	w.uint(uint64(seg.Flags.Type))
+	w.bool(seg.Flags.PageAssociationSize)
+	w.bool(seg.Flags.DeferredNonRetain)
+	w.uint(uint64(len(seg.ReferredToSegmentNumbers)))
+	for _, n := range seg.ReferredToSegmentNumbers {
+		w.uint(uint64(n))
+	}
+	w.bool(seg.Retain)
+	w.uint(uint64(len(seg.ReferredRetain)))
+	for _, r := range seg.ReferredRetain {
+		w.bool(r)
+	}
+	w.uint(uint64(seg.PageAssociation))
+	w.uint(uint64(seg.DataLength))
+	w.uint(uint64(seg.HeaderLength))
+	w.uint(uint64(seg.DataOffset))
+	w.int(int64(seg.RegionInfo.Width))
+	w.int(int64(seg.RegionInfo.Height))
+	w.int(int64(seg.RegionInfo.X))
+	w.int(int64(seg.RegionInfo.Y))
+	w.uint(uint64(seg.RegionInfo.Flags))
+	w.uint(uint64(seg.RegionInfo.Colour))
+	w.int(int64(seg.State))
+	w.int(int64(seg.ResultType))
+	switch seg.ResultType {
+	case JBig2ImagePointer:
+		w.image(seg.Image)
+	case JBig2SymbolDictPointer:
+		w.bool(seg.SymbolDict != nil)
+		if sd := seg.SymbolDict; sd != nil {
+			w.uint(uint64(len(sd.Images)))
+			for _, img := range sd.Images {
+				w.image(img)
+			}
+			w.contexts(sd.gbContexts)
+			w.contexts(sd.grContexts)
+		}
+	case JBig2PatternDictPointer:
+		w.bool(seg.PatternDict != nil)
+		if pd := seg.PatternDict; pd != nil {
+			w.uint(uint64(len(pd.HDPATS)))
+			for _, img := range pd.HDPATS {
+				w.image(img)
+			}
+		}
+	case JBig2HuffmanTablePointer:
+		w.bool(seg.HuffmanTable != nil)
+		if ht := seg.HuffmanTable; ht != nil {
+			w.bool(ht.HTOOB)
+			w.uint(uint64(ht.NTEMP))
+			w.uint(uint64(len(ht.CODES)))
+			for _, c := range ht.CODES {
+				w.int(int64(c.Codelen))
+				w.int(int64(c.Code))
+				w.int(int64(c.Val1))
+				w.int(int64(c.Val2))
+			}
+			w.uint(uint64(len(ht.RANGELEN)))
+			for _, v := range ht.RANGELEN {
+				w.int(int64(v))
+			}
+			w.uint(uint64(len(ht.RANGELOW)))
+			for _, v := range ht.RANGELOW {
+				w.int(int64(v))
+			}
+			w.bool(ht.Ok)
+		}
+	}
+}
+
+// image 写入图像
+// 入参: img 图像, 可为nil
+func (w *snapshotWriter) image(img *Image) {
+	w.bool(img != nil && img.data != nil)
+	if img == nil || img.data == nil {
+		return
+	}
+	w.int(int64(img.width))
+	w.int(int64(img.height))
+	w.bytes(img.data[:img.stride*img.height])
+}
+
+// contexts 写入算术上下文
+// 上下文大多处于初始状态, 只写入非零上下文的间隔与状态
+// 入参: ctx 上下文集合
+func (w *snapshotWriter) contexts(ctx []ArithCtx) {
+	w.uint(uint64(len(ctx)))
+	n := 0
+	for _, c := range ctx {
+		if c != (ArithCtx{}) {
+			n++
+		}
+	}
+	w.uint(uint64(n))
+	last := -1
+	for i, c := range ctx {
+		if c == (ArithCtx{}) {
+			continue
+		}
+		b := c.i << 1
+		if c.mps {
+			b |= 1
+		}
+		w.uint(uint64(i - last - 1))
+		w.buf = append(w.buf, b)
+		last = i
+	}
+}
+
+// snapshotReader 快照读取器, 出错后后续读取均返回零值
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+// fail 记录第一个错误
+func (r *snapshotReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("invalid snapshot")
+	}
+	r.data = nil
+}
+
+// uint 读取无符号整数
+// 返回: uint64 值
+func (r *snapshotReader) uint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// int 读取有符号整数
+// 返回: int64 值
+func (r *snapshotReader) int() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// bool 读取布尔值
+// 返回: bool 值
+func (r *snapshotReader) bool() bool {
+	if len(r.data) == 0 || r.data[0] > 1 {
+		r.fail()
+		return false
+	}
+	v := r.data[0] == 1
+	r.data = r.data[1:]
+	return v
+}
+
+// count 读取元素个数, 个数不得超过剩余字节数
+// 返回: int 个数
+func (r *snapshotReader) count() int {
+	n := r.uint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+// bytes 读取字节序列
+// 返回: []byte 字节序列
+func (r *snapshotReader) bytes() []byte {
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// uint32 读取32位无符号整数
+// 返回: uint32 值
+func (r *snapshotReader) uint32() uint32 {
+	v := r.uint()
+	if v > 0xFFFFFFFF {
+		r.fail()
+	}
+	return uint32(v)
+}
+
+// int32 读取32位有符号整数
+// 返回: int32 值
+func (r *snapshotReader) int32() int32 {
+	v := r.int()
+	if v < -0x80000000 || v > 0x7FFFFFFF {
+		r.fail()
+	}
+	return int32(v)
+}
+
+// config 读取数据流配置
+// 返回: streamConfig 数据流配置
+func (r *snapshotReader) config() streamConfig {
+	var cfg streamConfig
+	cfg.offset = int(r.uint32())
+	cfg.randomAccess = r.bool()
+	cfg.littleEndian = r.bool()
+	cfg.orgMode = int(r.int32())
+	cfg.grouped = r.bool()
+	cfg.pageCount = r.uint32()
+	cfg.hasPageCount = r.bool()
+	return cfg
+}
+
+// options 读取解码器选项
+// 返回: DecoderOptions 解码器选项
+func (r *snapshotReader) options() DecoderOptions {
+	var opts DecoderOptions
+	opts.Recover = r.bool()
+	opts.Organization = Organization(r.int32())
+	opts.ByteOrder = r.byteOrder()
+	opts.Header = HeaderMode(r.int32())
+	opts.PageCount = int(r.int32())
+	opts.Strict = r.bool()
+	opts.EnforceProfiles = r.bool()
+	if n := r.count(); n > 0 {
+		opts.ProfileFeatures = make(map[uint32]Feature, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			opts.ProfileFeatures[r.uint32()] = Feature(r.uint32())
+		}
+	}
+	if n := r.count(); n > kMaxPaletteColours || len(r.data) < n*4 {
+		r.fail()
+	} else if n > 0 {
+		opts.Palette = make(color.Palette, n)
+		for i := range opts.Palette {
+			c := r.data[i*4 : i*4+4]
+			opts.Palette[i] = color.RGBA{R: c[0], G: c[1], B: c[2], A: c[3]}
+		}
+		r.data = r.data[n*4:]
+	}
+	return opts
+}
+
+// probe 读取探测报告
+// 返回: ProbeReport 探测报告
+func (r *snapshotReader) probe() ProbeReport {
+	var p ProbeReport
+	p.Explicit = r.bool()
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		var c ProbeCandidate
+		c.Offset = int(r.int32())
+		c.RandomAccess = r.bool()
+		c.LittleEndian = r.bool()
+		c.OrgMode = int(r.int32())
+		c.Grouped = r.bool()
+		c.Score = int(r.int32())
+		c.Skipped = r.bool()
+		p.Candidates = append(p.Candidates, c)
+	}
+	p.Chosen = int(r.int32())
+	p.Fallback = r.bool()
+	p.Organization = Organization(r.int32())
+	p.ByteOrder = r.byteOrder()
+	p.Offset = int(r.int32())
+	return p
+}
+
+// byteOrder 读取字节序
+// 返回: binary.ByteOrder 字节序, 可为nil
+func (r *snapshotReader) byteOrder() binary.ByteOrder {
+	switch r.uint() {
+	case 0:
+		return nil
+	case 1:
+		return binary.BigEndian
+	case 2:
+		return binary.LittleEndian
+	}
+	r.fail()
+	return nil
+}
+
+// pageInfo 读取页面信息
+// 返回: *PageInfo 页面信息
+func (r *snapshotReader) pageInfo() *PageInfo {
+	pi := &PageInfo{}
+	pi.Width = r.uint32()
+	pi.Height = r.uint32()
+	pi.ResolutionX = r.uint32()
+	pi.ResolutionY = r.uint32()
+	pi.EventuallyLossless = r.bool()
+	pi.MightContainRefinement = r.bool()
+	pi.DefaultPixelValue = r.bool()
+	pi.DefaultComposeOp = ComposeOp(r.int32())
+	pi.RequiresAuxBuffers = r.bool()
+	pi.ComposeOpOverridden = r.bool()
+	pi.IsStriped = r.bool()
+	pi.MaxStripeSize = uint16(r.uint32())
+	return pi
+}
+
+// segments 读取文档的段列表、待解析段、分组解码队列、声明的配置与文档注释
+// 入参: doc 文档对象
+func (r *snapshotReader) segments(doc *Document) {
+	n := r.count()
+	all := make([]*Segment, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		all = append(all, r.segment())
+	}
+	ref := func() *Segment {
+		i := r.uint()
+		if i >= uint64(len(all)) {
+			r.fail()
+			return nil
+		}
+		return all[i]
+	}
+	n = r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		doc.segmentList = append(doc.segmentList, ref())
+	}
+	n = r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		doc.groupedQueue = append(doc.groupedQueue, ref())
+	}
+	if r.bool() {
+		doc.segment = ref()
+	}
+	n = r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		doc.profiles = append(doc.profiles, r.uint32())
+	}
+	n = r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		doc.comments = append(doc.comments, Comment{Name: string(r.bytes()), Value: string(r.bytes())})
+	}
+}
+
+// segment 读取段头与段结果
+// 返回: *Segment 段对象
+func (r *snapshotReader) segment() *Segment {
+	seg := NewSegment()
+	seg.Number = r.uint32()
+	seg.Flags.Type = uint8(r.uint32())
+	seg.Flags.PageAssociationSize = r.bool()
+	seg.Flags.DeferredNonRetain = r.bool()
+	n := r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		seg.ReferredToSegmentNumbers = append(seg.ReferredToSegmentNumbers, r.uint32())
+	}
+	seg.ReferredToSegmentCount = int32(n)
+	seg.Retain = r.bool()
+	n = r.count()
+	for i := 0; i < n && r.err == nil; i++ {
+		seg.ReferredRetain = append(seg.ReferredRetain, r.bool())
+	}
+	seg.PageAssociation = r.uint32()
+	seg.DataLength = r.uint32()
+	seg.HeaderLength = r.uint32()
+	seg.DataOffset = r.uint32()
+	seg.RegionInfo.Width = r.int32()
+	seg.RegionInfo.Height = r.int32()
+	seg.RegionInfo.X = r.int32()
+	seg.RegionInfo.Y = r.int32()
+	seg.RegionInfo.Flags = uint8(r.uint32())
+	seg.RegionInfo.Colour = uint16(r.uint32())
+	seg.State = JBig2SegmentState(r.int32())
+	seg.ResultType = JBig2ResultType(r.int32())
+	switch seg.ResultType {
+	case JBig2ImagePointer:
+		seg.Image = r.image()
+	case JBig2SymbolDictPointer:
+		if r.bool() {
+			sd := NewSymbolDict()
+			n := r.count()
+			for i := 0; i < n && r.err == nil; i++ {
+				sd.AddImage(r.image())
+			}
+			sd.gbContexts = r.contexts()
+			sd.grContexts = r.contexts()
+			seg.SymbolDict = sd
+		}
+	case JBig2PatternDictPointer:
+		if r.bool() {
+			n := r.count()
+			pd := NewPatternDict(uint32(n))
+			for i := 0; i < n && r.err == nil; i++ {
+				pd.HDPATS[i] = r.image()
+			}
+			seg.PatternDict = pd
+		}
+	case JBig2HuffmanTablePointer:
+		if r.bool() {
+			ht := &HuffmanTable{}
+			ht.HTOOB = r.bool()
+			ht.NTEMP = r.uint32()
+			n := r.count()
+			for i := 0; i < n && r.err == nil; i++ {
+				ht.CODES = append(ht.CODES, HuffmanCode{Codelen: r.int32(), Code: r.int32(), Val1: r.int32(), Val2: r.int32()})
+			}
+			n = r.count()
+			for i := 0; i < n && r.err == nil; i++ {
+				ht.RANGELEN = append(ht.RANGELEN, r.int32())
+			}
+			n = r.count()
+			for i := 0; i < n && r.err == nil; i++ {
+				ht.RANGELOW = append(ht.RANGELOW, r.int32())
+			}
+			ht.Ok = r.bool()
+			seg.HuffmanTable = ht
+		}
+	}
+	return seg
+}
+
+// image 读取图像
+// 返回: *Image 图像, 可为nil
+func (r *snapshotReader) image() *Image {
+	if !r.bool() {
+		return nil
+	}
+	width, height := r.int32(), r.int32()
+	data := r.bytes()
+	if r.err != nil {
+		return nil
+	}
+	// 先按数据长度校验大小, 避免为损坏的快照分配图像
+	if width <= 0 || height <= 0 || (int64(width)+7)/8*int64(height) != int64(len(data)) {
+		r.fail()
+		return nil
+	}
+	img := NewImage(width, height)
+	if img == nil {
+		r.fail()
+		return nil
+	}
+	copy(img.data, data)
+	return img
+}
+
+// contexts 读取算术上下文
+// 返回: []ArithCtx 上下文集合
+func (r *snapshotReader) contexts() []ArithCtx {
+	size := r.uint()
+	if size > kSnapshotMaxContexts {
+		r.fail()
+		return nil
+	}
+	n := r.count()
+	if r.err != nil {
+		return nil
+	}
+	ctx := make([]ArithCtx, size)
+	idx := uint64(0)
+	for j := 0; j < n && r.err == nil; j++ {
+		idx += r.uint()
+		if idx >= size || len(r.data) == 0 || r.data[0]>>1 >= uint8(len(kQeTable)) {
+			r.fail()
+			return nil
+		}
+		ctx[idx] = ArithCtx{mps: r.data[0]&1 != 0, i: r.data[0] >> 1}
+		r.data = r.data[1:]
+		idx++
+	}
+	return ctx
+}
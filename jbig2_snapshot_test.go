@@ -0,0 +1,143 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// testSnapshotStream 构造三页数据流, 各页引用第一页之前的符号字典
+// 返回: []byte 数据流
+func testSnapshotStream() []byte {
+	r := rand.New(rand.NewSource(14))
+	var syms []*Image
+	for i := 0; i < 5; i++ {
+		syms = append(syms, randomImage(r, 4+r.Int31n(8), 6+r.Int31n(6), 3))
+	}
+	dict, order := testSymbolDict(syms, 1)
+	s := newTestStream(3)
+	s.segment(0, 0, nil, dict)
+	for page := uint32(1); page <= 3; page++ {
+		var ps []testPlacement
+		for j := 0; j < 8; j++ {
+			ps = append(ps, testPlacement{id: uint32(r.Intn(len(order))), x: r.Int31n(60), y: r.Int31n(30)})
+		}
+		s.segment(48, page, nil, testPageInfo(72, 40, 0))
+		s.segment(6, page, []uint32{0}, testTextRegion(72, 40, len(order), ps))
+		if page > 1 {
+			s.segment(38, page, nil, testGenericRegion(randomImage(r, 40, 16, 6), 8, 20, ComposeXor, 0, true))
+		}
+		s.segment(49, page, nil, nil)
+	}
+	return s.out
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	data := testSnapshotStream()
+	full, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := full.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 3 {
+		t.Fatalf("decoded %d pages, want 3", len(want))
+	}
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := dec.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreDecoder(data, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 3; i++ {
+		got, err := restored.Decode()
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		if !bytes.Equal(got.(*image.Gray).Pix, want[i].(*image.Gray).Pix) {
+			t.Errorf("page %d differs from uninterrupted decode", i+1)
+		}
+	}
+}
+
+func TestSnapshotImageSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int64
+		size          int
+	}{
+		{"short", 9, 2, 3},
+		{"long", 8, 2, 3},
+		{"huge", 1 << 30, 1 << 30, 4},
+		{"negative", -8, 1, 1},
+	}
+	for _, tt := range tests {
+		w := &snapshotWriter{}
+		w.bool(true)
+		w.int(tt.width)
+		w.int(tt.height)
+		w.bytes(make([]byte, tt.size))
+		r := &snapshotReader{data: w.buf}
+		if img := r.image(); img != nil || r.err == nil {
+			t.Errorf("%s: image accepted", tt.name)
+		}
+	}
+	w := &snapshotWriter{}
+	w.byteOrder(binary.NativeEndian)
+	r := &snapshotReader{data: w.buf}
+	if got := r.byteOrder(); isLittleEndian(got) != isLittleEndian(binary.NativeEndian) {
+		t.Errorf("native byte order restored as %v", got)
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	data := testSnapshotStream()
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := dec.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 版本号在魔数之后, 以变长整数写入
+	n := len(kSnapshotMagic)
+	if v, _ := binary.Uvarint(snap[n:]); v != kSnapshotVersion {
+		t.Fatalf("snapshot version %d, want %d", v, kSnapshotVersion)
+	}
+	old := append([]byte(nil), snap...)
+	old[n] = kSnapshotVersion - 1
+	if _, err := RestoreDecoder(data, old); err == nil {
+		t.Error("snapshot from an older version restored")
+	}
+}
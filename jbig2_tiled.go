@@ -0,0 +1,394 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"iter"
+	"math"
+	"slices"
+)
+
+// defaultTileSize 默认分块边长
+const defaultTileSize = 1024
+
+// TiledImage 分块稀疏页面位图
+// 块按需分配, 未分配的块视为全部为默认像素并共享该状态, 首次写入时才复制出独立缓冲区
+// 实现image.Image接口, 像素1为黑色
+type TiledImage struct {
+	width        uint32
+	height       uint32
+	tileSize     uint32
+	defaultPixel bool
+	tiles        map[uint64]*Image
+}
+
+// Tile 已分配的页面块
+type Tile struct {
+	// Rect 块在页面坐标系中的范围
+	Rect image.Rectangle
+	// Bitmap 块位图, 尺寸与Rect一致
+	Bitmap *Image
+}
+
+// NewTiledImage 创建分块页面位图
+// 入参: width 宽度, height 高度, tileSize 块边长(向上取整为8的倍数, <=0时使用默认值), defaultPixel 默认像素
+// 返回: *TiledImage 分块位图
+func NewTiledImage(width, height uint32, tileSize int, defaultPixel bool) *TiledImage {
+	if tileSize <= 0 {
+		tileSize = defaultTileSize
+	}
+	tileSize = min((tileSize+7)&^7, JBig2MaxImageSize&^7)
+	return &TiledImage{
+		width:        width,
+		height:       height,
+		tileSize:     uint32(tileSize),
+		defaultPixel: defaultPixel,
+		tiles:        make(map[uint64]*Image),
+	}
+}
+
+// fitsImage 判断页面能否使用连续缓冲区表示
+// 入参: width 宽度, height 高度
+// 返回: bool 是否可以
+func fitsImage(width, height uint32) bool {
+	if width == 0 || height == 0 || width > math.MaxInt32 || height > math.MaxInt32 {
+		return false
+	}
+	stride := (uint64(width) + 7) / 8
+	return stride*uint64(height) <= math.MaxInt32
+}
+
+// Width 获取宽度
+// 返回: uint32 宽度
+func (t *TiledImage) Width() uint32 {
+	return t.width
+}
+
+// Height 获取高度
+// 返回: uint32 高度
+func (t *TiledImage) Height() uint32 {
+	return t.height
+}
+
+// TileSize 获取块边长
+// 返回: int 块边长
+func (t *TiledImage) TileSize() int {
+	return int(t.tileSize)
+}
+
+// DefaultPixel 获取未分配块的像素值
+// 返回: bool 默认像素
+func (t *TiledImage) DefaultPixel() bool {
+	return t.defaultPixel
+}
+
+// tileKey 计算块索引键
+// 入参: col 块列, row 块行
+// 返回: uint64 键
+func tileKey(col, row uint32) uint64 {
+	return uint64(row)<<32 | uint64(col)
+}
+
+// tileRect 计算块在页面中的范围
+// 入参: col 块列, row 块行
+// 返回: image.Rectangle 范围
+func (t *TiledImage) tileRect(col, row uint32) image.Rectangle {
+	x0, y0 := int64(col)*int64(t.tileSize), int64(row)*int64(t.tileSize)
+	x1 := min(x0+int64(t.tileSize), int64(t.width))
+	y1 := min(y0+int64(t.tileSize), int64(t.height))
+	return image.Rect(int(x0), int(y0), int(x1), int(y1))
+}
+
+// Tile 获取已分配的块
+// 入参: col 块列, row 块行
+// 返回: *Image 块位图(只读), bool 是否已分配
+func (t *TiledImage) Tile(col, row uint32) (*Image, bool) {
+	tile, ok := t.tiles[tileKey(col, row)]
+	return tile, ok
+}
+
+// writableTile 获取可写的块, 未分配时以默认像素复制出新块
+// 入参: col 块列, row 块行
+// 返回: *Image 块位图
+func (t *TiledImage) writableTile(col, row uint32) *Image {
+	key := tileKey(col, row)
+	if tile, ok := t.tiles[key]; ok {
+		if r := t.tileRect(col, row); tile.Height() < int32(r.Dy()) {
+			tile.Expand(int32(r.Dy()), t.defaultPixel)
+		}
+		return tile
+	}
+	r := t.tileRect(col, row)
+	tile := NewImage(int32(r.Dx()), int32(r.Dy()))
+	tile.Fill(t.defaultPixel)
+	t.tiles[key] = tile
+	return tile
+}
+
+// Tiles 按行优先顺序遍历已分配的块
+// 未出现的块全部为默认像素
+// 返回: iter.Seq[Tile] 块迭代器
+func (t *TiledImage) Tiles() iter.Seq[Tile] {
+	return func(yield func(Tile) bool) {
+		keys := make([]uint64, 0, len(t.tiles))
+		for key := range t.tiles {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			col, row := uint32(key), uint32(key>>32)
+			r := t.tileRect(col, row)
+			tile := t.tiles[key]
+			if tile.Height() < int32(r.Dy()) {
+				tile.Expand(int32(r.Dy()), t.defaultPixel)
+			}
+			if !yield(Tile{Rect: r, Bitmap: tile}) {
+				return
+			}
+		}
+	}
+}
+
+// composeSkips 判断组合到未分配块是否不会改变像素
+// 入参: src 源图像, r 源图像中与块相交的范围, op 组合操作
+// 返回: bool 是否可以跳过
+func (t *TiledImage) composeSkips(src *Image, r image.Rectangle, op ComposeOp) bool {
+	switch {
+	case t.defaultPixel && op == ComposeOr, !t.defaultPixel && op == ComposeAnd:
+		return true
+	case !t.defaultPixel && (op == ComposeOr || op == ComposeXor):
+		stride := int(src.stride)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if countBits(src.data[y*stride:(y+1)*stride], r.Min.X, r.Max.X) != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ComposeFrom 将源图像组合到分块位图
+// 入参: x 轴坐标, y 轴坐标, src 源图像, op 组合操作
+func (t *TiledImage) ComposeFrom(x, y int64, src *Image, op ComposeOp) {
+	if src == nil {
+		return
+	}
+	dst := image.Rect(0, 0, int(t.width), int(t.height)).Intersect(
+		image.Rect(int(x), int(y), int(x)+int(src.width), int(y)+int(src.height)))
+	if dst.Empty() {
+		return
+	}
+	ts := int(t.tileSize)
+	for row := dst.Min.Y / ts; row <= (dst.Max.Y-1)/ts; row++ {
+		for col := dst.Min.X / ts; col <= (dst.Max.X-1)/ts; col++ {
+			r := t.tileRect(uint32(col), uint32(row))
+			if _, ok := t.tiles[tileKey(uint32(col), uint32(row))]; !ok {
+				part := r.Intersect(dst).Sub(image.Pt(int(x), int(y)))
+				if t.composeSkips(src, part, op) {
+					continue
+				}
+			}
+			tile := t.writableTile(uint32(col), uint32(row))
+			blit(tile, src, x-int64(r.Min.X), y-int64(r.Min.Y), op)
+		}
+	}
+}
+
+// SubImage 复制指定范围为连续位图
+// 页面以外的部分为0, 与Image.SubImage一致
+// 入参: x 轴坐标, y 轴坐标, w 宽度, h 高度
+// 返回: *Image 子图像对象
+func (t *TiledImage) SubImage(x, y, w, h int32) *Image {
+	sub := NewImage(w, h)
+	if sub == nil {
+		return nil
+	}
+	want := image.Rect(int(x), int(y), int(x)+int(w), int(y)+int(h))
+	vis := want.Intersect(t.Bounds())
+	if vis.Empty() {
+		return sub
+	}
+	if t.defaultPixel {
+		if vis == want {
+			sub.Fill(true)
+		} else {
+			fill := NewImage(int32(vis.Dx()), int32(vis.Dy()))
+			fill.Fill(true)
+			blit(sub, fill, int64(vis.Min.X)-int64(x), int64(vis.Min.Y)-int64(y), ComposeReplace)
+			fill.release()
+		}
+	}
+	ts := int(t.tileSize)
+	for row := vis.Min.Y / ts; row <= (vis.Max.Y-1)/ts; row++ {
+		for col := vis.Min.X / ts; col <= (vis.Max.X-1)/ts; col++ {
+			if tile, ok := t.tiles[tileKey(uint32(col), uint32(row))]; ok {
+				blit(sub, tile, int64(col*ts)-int64(x), int64(row*ts)-int64(y), ComposeReplace)
+			}
+		}
+	}
+	return sub
+}
+
+// Expand 扩展高度, 新增部分为默认像素且不分配内存
+// 入参: height 新高度
+func (t *TiledImage) Expand(height uint32) {
+	if height > t.height {
+		t.height = height
+	}
+}
+
+// ColorModel 实现image.Image接口
+// 返回: color.Model 颜色模型
+func (t *TiledImage) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+// Bounds 实现image.Image接口
+// 返回: image.Rectangle 范围
+func (t *TiledImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(t.width), int(t.height))
+}
+
+// At 实现image.Image接口
+// 入参: x 轴坐标, y 轴坐标
+// 返回: color.Color 颜色
+func (t *TiledImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(t.Bounds())) {
+		return color.Gray{}
+	}
+	ts := int(t.tileSize)
+	black := t.defaultPixel
+	if tile, ok := t.tiles[tileKey(uint32(x/ts), uint32(y/ts))]; ok {
+		ty := int32(y % ts)
+		if ty < tile.Height() {
+			black = tile.GetPixel(int32(x%ts), ty) != 0
+		}
+	}
+	if black {
+		return color.Gray{Y: 0}
+	}
+	return color.Gray{Y: 255}
+}
+
+// ToGrayScaled 使用盒式滤波将分块位图缩小为灰度图像
+// 仅遍历已分配的块, 未分配的块按默认像素计入
+// 入参: dstW 目标宽度, dstH 目标高度
+// 返回: *image.Gray 灰度图像, error 错误信息
+func (t *TiledImage) ToGrayScaled(dstW, dstH int) (*image.Gray, error) {
+	if dstW <= 0 || dstH <= 0 || t.width == 0 || t.height == 0 {
+		return nil, errors.New("invalid scaled size")
+	}
+	srcW, srcH := int64(t.width), int64(t.height)
+	dstW, dstH = int(min(int64(dstW), srcW)), int(min(int64(dstH), srcH))
+	if int64(dstW)*int64(dstH) > math.MaxInt32 {
+		return nil, errors.New("scaled image too large")
+	}
+	xb := make([]int64, dstW+1)
+	for dx := range xb {
+		xb[dx] = int64(dx) * srcW / int64(dstW)
+	}
+	yb := make([]int64, dstH+1)
+	for dy := range yb {
+		yb[dy] = int64(dy) * srcH / int64(dstH)
+	}
+	acc := make([]int64, dstW*dstH)
+	for key, tile := range t.tiles {
+		r := t.tileRect(uint32(key), uint32(key>>32))
+		stride := int(tile.stride)
+		dx0 := (int64(r.Min.X+1)*int64(dstW) - 1) / srcW
+		for y := r.Min.Y; y < r.Min.Y+int(tile.height) && y < r.Max.Y; y++ {
+			dy := (int64(y+1)*int64(dstH) - 1) / srcH
+			row := tile.data[(y-r.Min.Y)*stride : (y-r.Min.Y+1)*stride]
+			out := acc[int(dy)*dstW:]
+			for dx := dx0; dx < int64(dstW) && xb[dx] < int64(r.Max.X); dx++ {
+				x0 := max(xb[dx], int64(r.Min.X)) - int64(r.Min.X)
+				x1 := min(xb[dx+1], int64(r.Max.X)) - int64(r.Min.X)
+				n := int64(countBits(row, int(x0), int(x1)))
+				if t.defaultPixel {
+					n -= x1 - x0
+				}
+				out[dx] += n
+			}
+		}
+	}
+	dst := image.NewGray(image.Rect(0, 0, dstW, dstH))
+	for dy := 0; dy < dstH; dy++ {
+		rows := yb[dy+1] - yb[dy]
+		for dx := 0; dx < dstW; dx++ {
+			area := (xb[dx+1] - xb[dx]) * rows
+			black := acc[dy*dstW+dx]
+			if t.defaultPixel {
+				black += area
+			}
+			dst.Pix[dy*dst.Stride+dx] = uint8(255 - (black*255+area/2)/area)
+		}
+	}
+	return dst, nil
+}
+
+// SetTileSize 设置分块页面的块边长
+// 大于0时所有页面使用分块缓冲区, 超出连续缓冲区上限的页面总是使用分块缓冲区
+// 分块页面由Decode以*TiledImage形式返回, 分块位图不保存颜色, 使用颜色扩展区域的分块页面返回ErrColourUnsupported
+// 入参: tileSize 块边长(0关闭强制分块)
+func (d *Decoder) SetTileSize(tileSize int) {
+	if d.doc == nil {
+		return
+	}
+	d.doc.tileSize = max(tileSize, 0)
+}
+
+// DecodeTiled 以分块缓冲区解码下一页
+// 使用颜色扩展区域的页面返回ErrColourUnsupported
+// 入参: 无
+// 返回: *TiledImage 分块位图, error 错误信息
+func (d *Decoder) DecodeTiled() (*TiledImage, error) {
+	if d.doc == nil {
+		return nil, errors.New("decoder not initialized")
+	}
+	if d.doc.tileSize == 0 {
+		d.doc.tileSize = defaultTileSize
+		defer func() { d.doc.tileSize = 0 }()
+	}
+	if err := d.decodePage(); err != nil {
+		return nil, err
+	}
+	if d.doc.tiledPage == nil {
+		return nil, errors.New("page not decoded into tiles")
+	}
+	return d.doc.tiledPage, nil
+}
+
+// hasPage 是否已建立页面缓冲区
+// 返回: bool 是否已建立
+func (d *Document) hasPage() bool {
+	return d.page != nil || d.tiledPage != nil
+}
+
+// pageImage 将当前页面转换为Go标准库图像
+// 分块页面直接返回分块位图, 不构建全尺寸图像
+// 返回: image.Image 图像
+func (d *Document) pageImage() image.Image {
+	if d.tiledPage != nil {
+		return d.tiledPage
+	}
+	if img := d.colourPage(); img != nil {
+		return img
+	}
+	return d.page.ToGoImage()
+}
@@ -0,0 +1,199 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// tiledEqual 逐像素比较分块位图与连续位图, 并检查由Tiles重建的位图
+// 入参: tiled 分块位图, flat 连续位图
+// 返回: error 不一致时的说明
+func tiledEqual(tiled *TiledImage, flat *Image) error {
+	if int32(tiled.Width()) != flat.Width() || int32(tiled.Height()) != flat.Height() {
+		return fmt.Errorf("size %dx%d, want %dx%d", tiled.Width(), tiled.Height(), flat.Width(), flat.Height())
+	}
+	for y := 0; y < int(flat.Height()); y++ {
+		for x := 0; x < int(flat.Width()); x++ {
+			want := uint8(255)
+			if flat.GetPixel(int32(x), int32(y)) != 0 {
+				want = 0
+			}
+			if uint8(rgbaGray(tiled.At(x, y))) != want {
+				return fmt.Errorf("At(%d,%d) differs", x, y)
+			}
+		}
+	}
+	rebuilt := NewImage(flat.Width(), flat.Height())
+	rebuilt.Fill(tiled.DefaultPixel())
+	for tile := range tiled.Tiles() {
+		if tile.Rect.Dx() != int(tile.Bitmap.Width()) || tile.Rect.Dy() != int(tile.Bitmap.Height()) {
+			return fmt.Errorf("tile %v has bitmap %dx%d", tile.Rect, tile.Bitmap.Width(), tile.Bitmap.Height())
+		}
+		rebuilt.ComposeFrom(int32(tile.Rect.Min.X), int32(tile.Rect.Min.Y), tile.Bitmap, ComposeReplace)
+	}
+	if !imageEqual(rebuilt, flat) {
+		return fmt.Errorf("bitmap rebuilt from tiles differs")
+	}
+	return nil
+}
+
+// rgbaGray 获取颜色的灰度分量
+// 入参: c 颜色
+// 返回: uint32 8位灰度
+func rgbaGray(c color.Color) uint32 {
+	r, _, _, _ := c.RGBA()
+	return r >> 8
+}
+
+func TestTiledComposeMatchesFlat(t *testing.T) {
+	r := rand.New(rand.NewSource(41))
+	ops := []ComposeOp{ComposeOr, ComposeAnd, ComposeXor, ComposeXnor, ComposeReplace}
+	for _, tileSize := range []int{8, 13, 24, 64} {
+		for _, def := range []bool{false, true} {
+			tiled := NewTiledImage(150, 93, tileSize, def)
+			flat := NewImage(150, 93)
+			flat.Fill(def)
+			for i := 0; i < 40; i++ {
+				src := noiseImage(r, 1+r.Int31n(60), 1+r.Int31n(40))
+				x, y := r.Int31n(180)-30, r.Int31n(120)-30
+				op := ops[r.Intn(len(ops))]
+				tiled.ComposeFrom(int64(x), int64(y), src, op)
+				flat.ComposeFrom(x, y, src, op)
+			}
+			if err := tiledEqual(tiled, flat); err != nil {
+				t.Fatalf("tile %d default %v: %v", tileSize, def, err)
+			}
+			for i := 0; i < 20; i++ {
+				x, y := r.Int31n(180)-15, r.Int31n(120)-15
+				w, h := 1+r.Int31n(70), 1+r.Int31n(50)
+				if !imageEqual(tiled.SubImage(x, y, w, h), flat.SubImage(x, y, w, h)) {
+					t.Fatalf("tile %d default %v: SubImage(%d,%d,%d,%d) differs", tileSize, def, x, y, w, h)
+				}
+			}
+		}
+	}
+}
+
+func TestTiledSparse(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	blank := NewImage(200, 200)
+	blank.Fill(false)
+	tests := []struct {
+		name string
+		def  bool
+		src  *Image
+		op   ComposeOp
+	}{
+		{"or blank", false, blank, ComposeOr},
+		{"xor blank", false, blank, ComposeXor},
+		{"and anything", false, noiseImage(r, 200, 200), ComposeAnd},
+		{"or onto black", true, noiseImage(r, 200, 200), ComposeOr},
+	}
+	for _, tt := range tests {
+		// 组合结果与默认像素相同的块不分配
+		tiled := NewTiledImage(1<<20, 1<<20, 64, tt.def)
+		tiled.ComposeFrom(1000, 3000, tt.src, tt.op)
+		for tile := range tiled.Tiles() {
+			t.Errorf("%s: tile %v allocated", tt.name, tile.Rect)
+		}
+	}
+
+	tiled := NewTiledImage(1<<20, 1<<20, 64, false)
+	dot := NewImage(200, 200)
+	dot.Fill(false)
+	dot.SetPixel(150, 10, 1)
+	tiled.ComposeFrom(1000, 3000, dot, ComposeOr)
+	var tiles []Tile
+	for tile := range tiled.Tiles() {
+		tiles = append(tiles, tile)
+	}
+	// 像素(1150,3010)位于第17列第47行的块
+	if len(tiles) != 1 || tiles[0].Rect != image.Rect(1088, 3008, 1152, 3072) {
+		t.Fatalf("tiles %v, want the single tile holding the set pixel", tiles)
+	}
+	if tiles[0].Bitmap.GetPixel(62, 2) != 1 {
+		t.Error("set pixel missing from its tile")
+	}
+	if _, ok := tiled.Tile(17, 47); !ok {
+		t.Error("Tile(17, 47) not allocated")
+	}
+	if _, ok := tiled.Tile(16, 47); ok {
+		t.Error("Tile(16, 47) allocated")
+	}
+	for _, p := range []image.Point{{1150, 3010}, {0, 0}, {1<<20 - 1, 1<<20 - 1}} {
+		want := uint32(255)
+		if p == (image.Point{1150, 3010}) {
+			want = 0
+		}
+		if got := rgbaGray(tiled.At(p.X, p.Y)); got != want {
+			t.Errorf("At(%v) = %d, want %d", p, got, want)
+		}
+	}
+	// 未分配的块在缩放时按默认像素计入
+	gray, err := tiled.ToGrayScaled(1024, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gray.GrayAt(1, 2).Y != 255 || gray.GrayAt(1023, 1023).Y != 255 {
+		t.Error("scaled background is not white")
+	}
+}
+
+func TestDecodeTiledMatchesFlat(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		pages int
+	}{
+		{"viewport", testViewportStream(), 3},
+		{"render", testRenderStream(), 1},
+	}
+	for _, tt := range tests {
+		name, data := tt.name, tt.data
+		dec, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []*Image
+		for range tt.pages {
+			if _, err := dec.Decode(); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			want = append(want, dec.doc.page.Duplicate())
+		}
+		for _, tileSize := range []int{0, 16, 40, 128} {
+			dec, err := NewDecoder(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.SetTileSize(tileSize)
+			for p, flat := range want {
+				tiled, err := dec.DecodeTiled()
+				if err != nil {
+					t.Fatalf("%s tile %d page %d: %v", name, tileSize, p+1, err)
+				}
+				if err := tiledEqual(tiled, flat); err != nil {
+					t.Errorf("%s tile %d page %d: %v", name, tileSize, p+1, err)
+				}
+			}
+		}
+	}
+}
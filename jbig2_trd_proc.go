@@ -70,6 +70,27 @@ type IntDecoderState struct {
 	IAID                         *ArithIaidDecoder
 }
 
+// NewIntDecoderState 创建整数解码器状态
+// 入参: sbsymCodeLen 符号编码长度
+// 返回: *IntDecoderState 解码器状态
+func NewIntDecoderState(sbsymCodeLen uint8) *IntDecoderState {
+	iax := new([9]ArithIntDecoder)
+	return &IntDecoderState{
+		IADT: &iax[0], IAFS: &iax[1], IADS: &iax[2], IAIT: &iax[3], IARI: &iax[4],
+		IARDW: &iax[5], IARDH: &iax[6], IARDX: &iax[7], IARDY: &iax[8],
+		IAID: NewArithIaidDecoder(sbsymCodeLen),
+	}
+}
+
+// Reset 重置全部解码器上下文以便复用
+// 入参: sbsymCodeLen 符号编码长度
+func (s *IntDecoderState) Reset(sbsymCodeLen uint8) {
+	for _, iax := range []*ArithIntDecoder{s.IADT, s.IAFS, s.IADS, s.IAIT, s.IARI, s.IARDW, s.IARDH, s.IARDX, s.IARDY} {
+		iax.Reset()
+	}
+	s.IAID.Reset(sbsymCodeLen)
+}
+
 // NewTRDProc 创建文本区域解码过程对象
 // 返回: *TRDProc 对象
 func NewTRDProc() *TRDProc {
@@ -153,6 +174,7 @@ func (t *TRDProc) DecodeHuffman(stream *BitStream, grContexts []ArithCtx) (*Imag
 	}
 	sbReg.Fill(t.SBDEFPIXEL)
 	decoder := NewHuffmanDecoder(stream)
+	symCodes := compileHuffmanCodes(t.SBSYMCODES[:min(uint32(len(t.SBSYMCODES)), t.SBNUMSYMS)])
 	var initialStript int32
 	if res := decoder.DecodeAValue(t.SBHUFFDT, &initialStript); res != 0 {
 		return nil, errors.New("huffman decode failed for sbhuffdt")
@@ -206,27 +228,11 @@ func (t *TRDProc) DecodeHuffman(stream *BitStream, grContexts []ArithCtx) (*Imag
 				CURT = int32(val)
 			}
 			TI := int32(STRIPT + int64(CURT))
-			nSafeVal := int32(0)
-			nBits := 0
-			IDI := uint32(0)
-			for {
-				var nTmp uint32
-				val, err := stream.Read1Bit()
-				if err != nil {
-					return nil, errors.New("read 1 bit failed")
-				}
-				nTmp = val
-				nSafeVal = (nSafeVal << 1) | int32(nTmp)
-				nBits++
-				for IDI = 0; IDI < t.SBNUMSYMS; IDI++ {
-					if int32(nBits) == t.SBSYMCODES[IDI].Codelen && nSafeVal == int32(t.SBSYMCODES[IDI].Code) {
-						break
-					}
-				}
-				if IDI < t.SBNUMSYMS {
-					break
-				}
+			idx, err := symCodes.decode(stream)
+			if err != nil {
+				return nil, errors.New("read symbol id failed")
 			}
+			IDI := uint32(idx)
 			var RI uint32 = 0
 			if t.SBREFINE {
 				val, err := stream.Read1Bit()
@@ -310,50 +316,12 @@ func (t *TRDProc) DecodeHuffman(stream *BitStream, grContexts []ArithCtx) (*Imag
 // 入参: arithDecoder 算术解码器, grContexts 细化上下文集, ids 整数解码器状态
 // 返回: *Image 图像对象, error 错误信息
 func (t *TRDProc) DecodeArith(arithDecoder *ArithDecoder, grContexts []ArithCtx, ids *IntDecoderState) (*Image, error) {
-	var pIADT, pIAFS, pIADS, pIAIT, pIARI, pIARDW, pIARDH, pIARDX, pIARDY *ArithIntDecoder
-	var pIAID *ArithIaidDecoder
-	if ids != nil {
-		pIADT = ids.IADT
-		pIAFS = ids.IAFS
-		pIADS = ids.IADS
-		pIAIT = ids.IAIT
-		pIARI = ids.IARI
-		pIARDW = ids.IARDW
-		pIARDH = ids.IARDH
-		pIARDX = ids.IARDX
-		pIARDY = ids.IARDY
-		pIAID = ids.IAID
-	}
-	if pIADT == nil {
-		pIADT = NewArithIntDecoder()
-	}
-	if pIAFS == nil {
-		pIAFS = NewArithIntDecoder()
-	}
-	if pIADS == nil {
-		pIADS = NewArithIntDecoder()
-	}
-	if pIAIT == nil {
-		pIAIT = NewArithIntDecoder()
-	}
-	if pIARI == nil {
-		pIARI = NewArithIntDecoder()
-	}
-	if pIARDW == nil {
-		pIARDW = NewArithIntDecoder()
-	}
-	if pIARDH == nil {
-		pIARDH = NewArithIntDecoder()
-	}
-	if pIARDX == nil {
-		pIARDX = NewArithIntDecoder()
-	}
-	if pIARDY == nil {
-		pIARDY = NewArithIntDecoder()
-	}
-	if pIAID == nil {
-		pIAID = NewArithIaidDecoder(t.SBSYMCODELEN)
+	if ids == nil {
+		ids = NewIntDecoderState(t.SBSYMCODELEN)
 	}
+	pIADT, pIAFS, pIADS, pIAIT, pIARI := ids.IADT, ids.IAFS, ids.IADS, ids.IAIT, ids.IARI
+	pIARDW, pIARDH, pIARDX, pIARDY := ids.IARDW, ids.IARDH, ids.IARDX, ids.IARDY
+	pIAID := ids.IAID
 	sbReg := NewImage(int32(t.SBW), int32(t.SBH))
 	if sbReg == nil {
 		return nil, nil
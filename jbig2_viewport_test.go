@@ -0,0 +1,149 @@
+// Copyright 2026 肖其顿 (XIAO QI DUN)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jbig2
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// testViewportStream 构造三页数据流
+// 第1页为偏移放置的通用区域与文本区域, 第2页标记可能含有细化并细化页面的一部分, 第3页默认像素为1
+// 返回: []byte 数据流
+func testViewportStream() []byte {
+	r := rand.New(rand.NewSource(40))
+	syms := []*Image{randomImage(r, 9, 12, 4), randomImage(r, 6, 8, 3), randomImage(r, 11, 10, 4)}
+	dictData, order := testSymbolDict(syms, 1)
+	s := newTestStream(3)
+	dict := s.segment(0, 0, nil, dictData)
+
+	s.segment(48, 1, nil, testPageInfo(101, 67, 0))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 60, 40, 10), 30, 20, ComposeOr, 0, false))
+	var ps []testPlacement
+	for j := 0; j < 16; j++ {
+		ps = append(ps, testPlacement{id: uint32(r.Intn(len(order))), x: r.Int31n(90), y: r.Int31n(55)})
+	}
+	s.segment(6, 1, []uint32{dict}, testTextRegion(101, 67, len(order), ps))
+	s.segment(38, 1, nil, testGenericRegion(randomImage(r, 33, 17, 5), 80, 55, ComposeXor, 2, true))
+	s.segment(49, 1, nil, nil)
+
+	base := randomImage(r, 88, 50, 14)
+	ref := base.SubImage(20, 10, 40, 24)
+	refined := ref.Duplicate()
+	for i := 0; i < 30; i++ {
+		refined.SetPixel(r.Int31n(40), r.Int31n(24), r.Intn(2))
+	}
+	s.segment(48, 2, nil, testPageInfo(88, 50, 0x02))
+	s.segment(38, 2, nil, testGenericRegion(base, 0, 0, ComposeOr, 1, false))
+	s.segment(42, 2, nil, testRefinementRegion(refined, ref, 20, 10, false, false))
+	s.segment(49, 2, nil, nil)
+
+	s.segment(48, 3, nil, testPageInfo(120, 90, 0x04))
+	s.segment(38, 3, nil, testGenericRegion(randomImage(r, 50, 30, 8), 64, 58, ComposeAnd, 0, false))
+	s.segment(38, 3, nil, testGenericRegion(randomImage(r, 40, 40, 8), 5, 5, ComposeXnor, 3, false))
+	s.segment(49, 3, nil, nil)
+	return s.out
+}
+
+func TestDecodeRegionMatchesFullPage(t *testing.T) {
+	data := testViewportStream()
+	full := decodeAllPages(t, data)
+	for _, tileSize := range []int{0, 32} {
+		for p, page := range full {
+			w, h := page.Bounds().Dx(), page.Bounds().Dy()
+			rects := []image.Rectangle{
+				image.Rect(0, 0, w, h),
+				image.Rect(13, 7, 45, 39),
+				image.Rect(w-9, h-5, w, h),
+				image.Rect(0, 0, 1, 1),
+				// 跨越页面边界的窗口被裁剪到与页面的交集
+				image.Rect(-10, -5, 20, 30),
+				image.Rect(w-3, h-3, w+10, h+10),
+				image.Rect(-100, 10, w+100, 11),
+				// 未规范化的窗口按规范化处理
+				image.Rect(40, 30, 10, 5),
+			}
+			for _, rect := range rects {
+				t.Run(fmt.Sprintf("tile %d page %d %v", tileSize, p+1, rect), func(t *testing.T) {
+					dec, err := NewDecoder(bytes.NewReader(data))
+					if err != nil {
+						t.Fatal(err)
+					}
+					dec.SetTileSize(tileSize)
+					got, err := dec.DecodeRegion(p+1, rect)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want := page.(*image.Gray).SubImage(rect.Canon())
+					if err := goImagesEqual(got, want); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDecodeRegionOutside(t *testing.T) {
+	data := testViewportStream()
+	for _, rect := range []image.Rectangle{
+		image.Rect(101, 0, 150, 10),
+		image.Rect(-20, -20, 0, 0),
+		image.Rect(0, 67, 101, 80),
+		image.Rect(10, 10, 10, 40),
+	} {
+		dec, err := NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := dec.DecodeRegion(1, rect)
+		if err != nil {
+			t.Fatalf("%v: %v", rect, err)
+		}
+		if !got.Bounds().Empty() {
+			t.Errorf("%v: bounds %v, want empty", rect, got.Bounds())
+		}
+		// 窗口外的页面不影响后续页面的解码
+		img, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := goImagesEqual(img, decodeAllPages(t, data)[1]); err != nil {
+			t.Errorf("%v: next page: %v", rect, err)
+		}
+	}
+}
+
+func TestDecodeRegionPageOrder(t *testing.T) {
+	data := testViewportStream()
+	dec, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.DecodeRegion(2, image.Rect(0, 0, 10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []int{1, 2} {
+		if _, err := dec.DecodeRegion(page, image.Rect(0, 0, 10, 10)); err == nil {
+			t.Errorf("page %d: expected already decoded error", page)
+		}
+	}
+	if _, err := dec.DecodeRegion(5, image.Rect(0, 0, 10, 10)); err == nil {
+		t.Error("page 5: expected not found error")
+	}
+}